@@ -15,76 +15,141 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/errors"
 	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/diagnostics"
 	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/log"
 	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/opentelemetry"
 	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/process"
 )
 
+// shutdownTimeout bounds draining the events of the probes and exporting the
+// remaining spans on exit.
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 	err := log.Init()
 	if err != nil {
 		fmt.Printf("could not init logger: %s\n", err)
 		os.Exit(1)
 	}
+	logger := log.Component(log.ComponentAgent)
+
+	logger.V(0).Info("starting Go OpenTelemetry Agent ...")
+	controllerOpts, err := opentelemetry.OptionsFromEnv()
+	if err != nil {
+		log.Error(logger, log.ErrInvalidConfig, err, "invalid OpenTelemetry configuration")
+		return
+	}
+	if addr, exists := os.LookupEnv(diagnostics.AddrEnvVar); exists {
+		recentTraces, err := diagnostics.RecentTraces()
+		if err != nil {
+			log.Error(logger, log.ErrInvalidConfig, err, "invalid diagnostics configuration")
+			return
+		}
+		controllerOpts = append(controllerOpts, opentelemetry.WithRecentTraces(recentTraces))
+
+		server, err := diagnostics.StartServer(addr)
+		if err != nil {
+			log.Error(logger, log.ErrDiagnostics, err, "could not start diagnostics server", "addr", addr)
+			return
+		}
+		defer server.Close()
+	}
+	if endpoint, exists := os.LookupEnv(diagnostics.TracesEndpointEnvVar); exists {
+		tp, err := diagnostics.StartTracing(context.Background(), endpoint)
+		if err != nil {
+			log.Error(logger, log.ErrDiagnostics, err, "could not start self-diagnostics traces", "endpoint", endpoint)
+			return
+		}
+		defer tp.Shutdown(context.Background())
+	}
 
-	log.Logger.V(0).Info("starting Go OpenTelemetry Agent ...")
 	target := process.ParseTargetArgs()
 	if err = target.Validate(); err != nil {
-		log.Logger.Error(err, "invalid target args")
+		log.Error(logger, log.ErrInvalidConfig, err, "invalid target args")
 		return
 	}
 
 	processAnalyzer := process.NewAnalyzer()
-	otelController, err := opentelemetry.NewController()
+	instManager, err := instrumentors.NewManager()
 	if err != nil {
-		log.Logger.Error(err, "unable to create OpenTelemetry controller")
+		log.Error(logger, log.ErrInvalidConfig, err, "error creating instrumetors manager")
 		return
 	}
 
-	instManager, err := instrumentors.NewManager(otelController)
+	instManager.SetStopOnTargetExit(target.ShortLived)
+
+	forks, err := instrumentors.ModuleForks()
 	if err != nil {
-		log.Logger.Error(err, "error creating instrumetors manager")
+		log.Error(logger, log.ErrInvalidConfig, err, "error reading forks of instrumented modules")
 		return
 	}
+	processAnalyzer.SetModuleForks(forks)
 
+	loadCtx, cancelLoad := context.WithCancel(context.Background())
+	defer cancelLoad()
 	stopper := make(chan os.Signal, 1)
 	signal.Notify(stopper, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		<-stopper
-		log.Logger.V(0).Info("Got SIGTERM, cleaning up..")
+		logger.V(0).Info("Got SIGTERM, cleaning up..")
 		processAnalyzer.Close()
+		cancelLoad()
 		instManager.Close()
 	}()
 
 	pid, err := processAnalyzer.DiscoverProcessID(target)
 	if err != nil {
 		if err != errors.ErrInterrupted {
-			log.Logger.Error(err, "error while discovering process id")
+			log.Error(logger, log.ErrTargetDiscovery, err, "error while discovering process id")
 		}
 		return
 	}
+	log.SetTargetPID(pid)
+	logger = log.Component(log.ComponentAgent)
 
-	targetDetails, err := processAnalyzer.Analyze(pid, instManager.GetRelevantFuncs())
+	targetDetails, err := processAnalyzer.Analyze(pid, instManager.GetRelevantFuncs(), target.ModuleAliases)
 	if err != nil {
-		log.Logger.Error(err, "error while analyzing target process")
+		log.Error(logger, log.ErrTargetAnalysis, err, "error while analyzing target process")
 		return
 	}
-	log.Logger.V(0).Info("target process analysis completed", "pid", targetDetails.PID,
+	logger.V(0).Info("target process analysis completed", "pid", targetDetails.PID,
 		"go_version", targetDetails.GoVersion, "dependencies", targetDetails.Libraries,
-		"total_functions_found", len(targetDetails.Functions))
+		"total_functions_found", len(targetDetails.Functions), "cgo_enabled", targetDetails.CgoEnabled)
+
+	otelController, err := opentelemetry.NewController(targetDetails, controllerOpts...)
+	if err != nil {
+		log.Error(logger, log.ErrExporterConnect, err, "unable to create OpenTelemetry controller")
+		return
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := instManager.Shutdown(ctx); err != nil {
+			log.Error(logger, log.ErrExport, err, "could not shut down instrumentors cleanly")
+		}
+	}()
 
 	instManager.FilterUnusedInstrumentors(targetDetails)
+	logger.V(0).Info("matched instrumentors", "instrumentors", instManager.TargetInfo().Instrumentors)
 
-	log.Logger.V(0).Info("invoking instrumentors")
-	err = instManager.Run(targetDetails)
-	if err != nil && err != errors.ErrInterrupted {
-		log.Logger.Error(err, "error while running instrumentors")
+	logger.V(0).Info("invoking instrumentors")
+	err = instManager.Load(loadCtx, targetDetails, otelController)
+	if err != nil {
+		if err != errors.ErrInterrupted {
+			log.Error(logger, log.ErrProbeLoad, err, "error while loading instrumentors")
+		}
+		return
 	}
+
+	instManager.Start()
+	instManager.Wait()
 }
@@ -0,0 +1,64 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+
+#include "bpf_helpers.h"
+
+// Stop-the-world pause of the runtime, most of them are garbage collections.
+struct gc_pause_t
+{
+    u64 start_time;
+    u64 end_time;
+};
+
+// Start time of the ongoing pause, the world is stopped by one thread at a time.
+struct
+{
+    __uint(type, BPF_MAP_TYPE_ARRAY);
+    __type(key, u32);
+    __type(value, u64);
+    __uint(max_entries, 1);
+} stw_start_time SEC(".maps");
+
+struct
+{
+    __uint(type, BPF_MAP_TYPE_PERF_EVENT_ARRAY);
+} gc_pause_events SEC(".maps");
+
+// func stopTheWorldWithSema()
+static __always_inline int track_stop_the_world(struct pt_regs *ctx)
+{
+    u32 key = 0;
+    u64 now = bpf_ktime_get_boot_ns();
+    bpf_map_update_elem(&stw_start_time, &key, &now, 0);
+    return 0;
+}
+
+// func startTheWorldWithSema(emitTraceEvent bool) int64
+static __always_inline int track_start_the_world(struct pt_regs *ctx)
+{
+    u32 key = 0;
+    u64 *start_time = bpf_map_lookup_elem(&stw_start_time, &key);
+    if (start_time == NULL || *start_time == 0)
+    {
+        return 0;
+    }
+
+    struct gc_pause_t pause = {};
+    pause.start_time = *start_time;
+    pause.end_time = bpf_ktime_get_boot_ns();
+    *start_time = 0;
+    bpf_perf_event_output(ctx, &gc_pause_events, BPF_F_CURRENT_CPU, &pause, sizeof(pause));
+    return 0;
+}
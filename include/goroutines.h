@@ -0,0 +1,152 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include "bpf_helpers.h"
+
+#define MAX_GOROUTINE_SPANS 1000
+
+// Span of the request handler a goroutine was spawned from, directly or not.
+struct goroutine_span_t
+{
+    struct span_context sc;
+    u32 depth;
+    u32 padding;
+};
+
+struct
+{
+    __uint(type, BPF_MAP_TYPE_LRU_HASH);
+    __type(key, void *);
+    __type(value, struct goroutine_span_t);
+    __uint(max_entries, MAX_GOROUTINE_SPANS);
+    __uint(pinning, LIBBPF_PIN_BY_NAME);
+} goroutine_spans SEC(".maps");
+
+// Goroutine calling newproc1, keyed by the system goroutine running it.
+struct
+{
+    __uint(type, BPF_MAP_TYPE_LRU_HASH);
+    __type(key, void *);
+    __type(value, void *);
+    __uint(max_entries, MAX_GOROUTINE_SPANS);
+} newproc1_callers SEC(".maps");
+
+// Injected in init
+volatile const u64 max_goroutine_depth;
+volatile const bool newproc1_takes_args;
+
+// The current goroutine is held in R14 by the register based ABI
+static __always_inline void *current_goroutine(struct pt_regs *ctx)
+{
+    return (void *)(ctx->r14);
+}
+
+// Makes sc the span of the goroutines spawned by the current goroutine.
+static __always_inline void set_goroutine_span(struct pt_regs *ctx, struct span_context *sc)
+{
+    if (!is_registers_abi)
+    {
+        return;
+    }
+
+    void *goroutine = current_goroutine(ctx);
+    struct goroutine_span_t gs = {};
+    gs.sc = *sc;
+    bpf_map_update_elem(&goroutine_spans, &goroutine, &gs, 0);
+}
+
+static __always_inline void delete_goroutine_span(struct pt_regs *ctx)
+{
+    if (!is_registers_abi)
+    {
+        return;
+    }
+
+    void *goroutine = current_goroutine(ctx);
+    bpf_map_delete_elem(&goroutine_spans, &goroutine);
+}
+
+// Returns the span inherited by the current goroutine, if any.
+static __always_inline struct span_context *find_goroutine_span(struct pt_regs *ctx)
+{
+    if (!is_registers_abi)
+    {
+        return NULL;
+    }
+
+    void *goroutine = current_goroutine(ctx);
+    struct goroutine_span_t *gs = bpf_map_lookup_elem(&goroutine_spans, &goroutine);
+    if (gs == NULL || gs->depth == 0)
+    {
+        // Handler goroutines already get their span from the request context
+        return NULL;
+    }
+
+    return &gs->sc;
+}
+
+// func newproc1(fn *funcval, callergp *g, callerpc uintptr) *g
+// newproc1 runs on the system goroutine, the caller is passed explicitly.
+static __always_inline int track_newproc1(struct pt_regs *ctx)
+{
+    void *system_goroutine = current_goroutine(ctx);
+    // The caller goroutine follows the function arguments until Go 1.18.
+    // Registers can only be read at constant offsets of ctx.
+    void *callergp = NULL;
+    if (newproc1_takes_args)
+    {
+        callergp = get_argument(ctx, 4);
+    }
+    else
+    {
+        callergp = get_argument(ctx, 2);
+    }
+    bpf_map_update_elem(&newproc1_callers, &system_goroutine, &callergp, 0);
+    return 0;
+}
+
+static __always_inline int track_newproc1_returns(struct pt_regs *ctx)
+{
+    void *system_goroutine = current_goroutine(ctx);
+    void **callergp = bpf_map_lookup_elem(&newproc1_callers, &system_goroutine);
+    if (callergp == NULL)
+    {
+        return 0;
+    }
+
+    void *caller = *callergp;
+    bpf_map_delete_elem(&newproc1_callers, &system_goroutine);
+    struct goroutine_span_t *parent = bpf_map_lookup_elem(&goroutine_spans, &caller);
+    if (parent == NULL || parent->depth >= max_goroutine_depth)
+    {
+        return 0;
+    }
+
+    // The new goroutine is returned in RAX
+    void *newg = (void *)(ctx->rax);
+    struct goroutine_span_t gs = {};
+    gs.sc = parent->sc;
+    gs.depth = parent->depth + 1;
+    bpf_map_update_elem(&goroutine_spans, &newg, &gs, 0);
+    return 0;
+}
+
+// func goexit1()
+// Goroutines are reused once they exit, forget their span.
+static __always_inline int track_goexit1(struct pt_regs *ctx)
+{
+    void *goroutine = current_goroutine(ctx);
+    bpf_map_delete_elem(&goroutine_spans, &goroutine);
+    return 0;
+}
@@ -0,0 +1,91 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include "bpf_helpers.h"
+
+#define MAX_MESSAGES 4
+#define MESSAGE_TYPE_SENT 1
+#define MESSAGE_TYPE_RECEIVED 2
+#define MESSAGE_HEADER_SIZE 5
+
+struct grpc_message_t
+{
+    u64 time;
+    u32 size;
+    u8 type;
+    u8 compressed;
+    u8 padding[2];
+};
+
+// Messages of a gRPC request, only the first MAX_MESSAGES are kept.
+struct grpc_messages_t
+{
+    // Header buffer of the last message header read on the stream, the
+    // message is recorded once its body is read.
+    u64 pending_header;
+    u32 count;
+    u32 padding;
+    struct grpc_message_t messages[MAX_MESSAGES];
+};
+
+static __always_inline void grpc_record_message(struct grpc_messages_t *m, u8 type, u32 size, u8 compressed)
+{
+    u32 index = m->count;
+    m->count++;
+    if (index >= MAX_MESSAGES)
+    {
+        return;
+    }
+
+    m->messages[index].time = bpf_ktime_get_boot_ns();
+    m->messages[index].size = size;
+    m->messages[index].type = type;
+    m->messages[index].compressed = compressed;
+}
+
+// Records a message written by the transport:
+// func (t *http2Client) Write(s *Stream, hdr []byte, data []byte, opts *Options) error
+// func (t *http2Server) Write(s *Stream, hdr []byte, data []byte, opts *Options) error
+static __always_inline void grpc_record_sent_message(struct grpc_messages_t *m, void *hdr_ptr, u64 data_len)
+{
+    u8 compressed = 0;
+    bpf_probe_read(&compressed, sizeof(compressed), hdr_ptr);
+    grpc_record_message(m, MESSAGE_TYPE_SENT, data_len, compressed);
+}
+
+// Records messages read from the stream. The parser reads the 5 bytes
+// message header, then the message body if it is not empty:
+// func (s *Stream) Read(p []byte) (n int, err error)
+static __always_inline void grpc_record_received_message(struct grpc_messages_t *m, void *p_ptr, u64 p_len)
+{
+    if (m->pending_header != 0)
+    {
+        unsigned char header[MESSAGE_HEADER_SIZE] = {};
+        bpf_probe_read(header, sizeof(header), (void *)m->pending_header);
+        m->pending_header = 0;
+
+        u32 length = ((u32)header[1] << 24) | ((u32)header[2] << 16) | ((u32)header[3] << 8) | (u32)header[4];
+        grpc_record_message(m, MESSAGE_TYPE_RECEIVED, length, header[0]);
+        if (length != 0)
+        {
+            // Reading the body of the pending message
+            return;
+        }
+    }
+
+    if (p_len == MESSAGE_HEADER_SIZE)
+    {
+        m->pending_header = (u64)p_ptr;
+    }
+}
@@ -0,0 +1,100 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+
+#include "bpf_helpers.h"
+
+#define MAX_MUTEX_WAITERS 1000
+
+// Time a goroutine of a request spent waiting for a contended sync.Mutex.
+struct mutex_wait_t
+{
+    struct span_context sc;
+    u64 start_time;
+    u64 end_time;
+};
+
+// Time the goroutines of requests started waiting for a mutex, keyed by
+// goroutine.
+struct
+{
+    __uint(type, BPF_MAP_TYPE_LRU_HASH);
+    __type(key, void *);
+    __type(value, u64);
+    __uint(max_entries, MAX_MUTEX_WAITERS);
+} mutex_wait_start SEC(".maps");
+
+struct
+{
+    __uint(type, BPF_MAP_TYPE_PERF_EVENT_ARRAY);
+} mutex_wait_events SEC(".maps");
+
+// Injected in init
+volatile const u64 min_mutex_wait_ns;
+
+// func (m *Mutex) lockSlow()
+// lockSlow is only called when the mutex is already locked.
+static __always_inline int track_mutex_wait(struct pt_regs *ctx)
+{
+    if (!is_registers_abi)
+    {
+        return 0;
+    }
+
+    void *goroutine = current_goroutine(ctx);
+    if (bpf_map_lookup_elem(&goroutine_spans, &goroutine) == NULL)
+    {
+        return 0;
+    }
+
+    u64 now = bpf_ktime_get_boot_ns();
+    bpf_map_update_elem(&mutex_wait_start, &goroutine, &now, 0);
+    return 0;
+}
+
+// Reports the wait of the current goroutine once it holds the mutex, if it
+// lasted at least min_mutex_wait_ns.
+static __always_inline int track_mutex_acquired(struct pt_regs *ctx)
+{
+    if (!is_registers_abi)
+    {
+        return 0;
+    }
+
+    void *goroutine = current_goroutine(ctx);
+    u64 *start_time = bpf_map_lookup_elem(&mutex_wait_start, &goroutine);
+    if (start_time == NULL)
+    {
+        return 0;
+    }
+
+    struct mutex_wait_t wait = {};
+    wait.start_time = *start_time;
+    wait.end_time = bpf_ktime_get_boot_ns();
+    bpf_map_delete_elem(&mutex_wait_start, &goroutine);
+    if (wait.end_time - wait.start_time < min_mutex_wait_ns)
+    {
+        return 0;
+    }
+
+    struct goroutine_span_t *gs = bpf_map_lookup_elem(&goroutine_spans, &goroutine);
+    if (gs == NULL)
+    {
+        return 0;
+    }
+
+    wait.sc = gs->sc;
+    bpf_perf_event_output(ctx, &mutex_wait_events, BPF_F_CURRENT_CPU, &wait, sizeof(wait));
+    return 0;
+}
@@ -0,0 +1,59 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include "bpf_helpers.h"
+
+// Requests not traced because the map of in-flight requests was full.
+// Requests evicted from LRU maps by newer ones are not counted: their insert
+// does not fail.
+#define DROPPED_NEW_REQUESTS 0
+#define DROPPED_REQUESTS_REASONS 1
+
+struct
+{
+    __uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+    __type(key, u32);
+    __type(value, u64);
+    __uint(max_entries, DROPPED_REQUESTS_REASONS);
+} dropped_requests SEC(".maps");
+
+static __always_inline void count_dropped_request(u32 reason)
+{
+    u64 *count = bpf_map_lookup_elem(&dropped_requests, &reason);
+    if (count != NULL)
+    {
+        *count += 1;
+    }
+}
+
+// Tracks a new in-flight request in map, counting it as dropped if map is
+// full. Maps are full when their size is reached, unless they evict their
+// least recently used entries instead.
+static __always_inline long start_request(void *map, void *key, void *request)
+{
+    long res = bpf_map_update_elem(map, key, request, 0);
+    if (res != 0)
+    {
+        count_dropped_request(DROPPED_NEW_REQUESTS);
+    }
+    return res;
+}
+
+// Returns the in-flight request of key in map. Requests are missing when
+// their start was dropped or evicted, or ended by a nested call, which are
+// not counted here.
+static __always_inline void *end_request(void *map, void *key)
+{
+    return bpf_map_lookup_elem(map, key);
+}
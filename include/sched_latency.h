@@ -0,0 +1,113 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+
+#include "bpf_helpers.h"
+
+#define MAX_SCHED_GOROUTINES 1000
+
+// Time spent runnable but not running by the goroutines of requests, keyed by
+// goroutine.
+struct
+{
+    __uint(type, BPF_MAP_TYPE_LRU_HASH);
+    __type(key, void *);
+    __type(value, u64);
+    __uint(max_entries, MAX_SCHED_GOROUTINES);
+    __uint(pinning, LIBBPF_PIN_BY_NAME);
+} goroutine_sched_latency SEC(".maps");
+
+// Time the goroutines of requests were made runnable at, keyed by goroutine.
+struct
+{
+    __uint(type, BPF_MAP_TYPE_LRU_HASH);
+    __type(key, void *);
+    __type(value, u64);
+    __uint(max_entries, MAX_SCHED_GOROUTINES);
+    __uint(pinning, LIBBPF_PIN_BY_NAME);
+} goroutine_runnable_since SEC(".maps");
+
+// Injected in init
+volatile const bool track_sched_latency;
+
+// Starts measuring the scheduling latency of the current goroutine.
+static __always_inline void start_sched_latency(struct pt_regs *ctx)
+{
+    if (!track_sched_latency || !is_registers_abi)
+    {
+        return;
+    }
+
+    void *goroutine = current_goroutine(ctx);
+    u64 latency = 0;
+    bpf_map_update_elem(&goroutine_sched_latency, &goroutine, &latency, 0);
+}
+
+// Returns the scheduling latency of the current goroutine since
+// start_sched_latency, and stops measuring it.
+static __always_inline u64 end_sched_latency(struct pt_regs *ctx)
+{
+    if (!track_sched_latency || !is_registers_abi)
+    {
+        return 0;
+    }
+
+    void *goroutine = current_goroutine(ctx);
+    u64 *latency = bpf_map_lookup_elem(&goroutine_sched_latency, &goroutine);
+    if (latency == NULL)
+    {
+        return 0;
+    }
+
+    u64 result = *latency;
+    bpf_map_delete_elem(&goroutine_sched_latency, &goroutine);
+    bpf_map_delete_elem(&goroutine_runnable_since, &goroutine);
+    return result;
+}
+
+// func ready(gp *g, traceskip int, next bool)
+// func goschedImpl(gp *g)
+// gp is made runnable, either woken up or preempted.
+static __always_inline int track_runnable(struct pt_regs *ctx)
+{
+    void *gp = get_argument(ctx, 1);
+    if (bpf_map_lookup_elem(&goroutine_sched_latency, &gp) == NULL)
+    {
+        return 0;
+    }
+
+    u64 now = bpf_ktime_get_boot_ns();
+    bpf_map_update_elem(&goroutine_runnable_since, &gp, &now, 0);
+    return 0;
+}
+
+// func execute(gp *g, inheritTime bool)
+// gp starts running.
+static __always_inline int track_execute(struct pt_regs *ctx)
+{
+    void *gp = get_argument(ctx, 1);
+    u64 *since = bpf_map_lookup_elem(&goroutine_runnable_since, &gp);
+    if (since == NULL)
+    {
+        return 0;
+    }
+
+    u64 *latency = bpf_map_lookup_elem(&goroutine_sched_latency, &gp);
+    if (latency != NULL)
+    {
+        __sync_fetch_and_add(latency, bpf_ktime_get_boot_ns() - *since);
+    }
+    bpf_map_delete_elem(&goroutine_runnable_since, &gp);
+    return 0;
+}
@@ -0,0 +1,108 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command app serves HTTP requests on an unbounded number of paths: every
+// path below /items/ is served by the same handler, and other paths are not
+// found. It generates load against itself cycling through a configurable
+// number of unique paths, so the agent must name the spans of the requests
+// without their path to keep span names low cardinality.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+func main() {
+	httpAddr := flag.String("http-addr", "localhost:8080", "address the HTTP server listens on")
+	rps := flag.Int("rps", 200, "requests per second sent to the HTTP server, 0 to only serve")
+	paths := flag.Int("paths", 10000, "number of unique paths requested")
+	duration := flag.Duration("duration", 0, "duration of the load, 0 to run until interrupted")
+	flag.Parse()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	if *duration > 0 {
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+
+	if err := run(ctx, *httpAddr, *rps, *paths); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(ctx context.Context, httpAddr string, rps int, paths int) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/items/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, r.URL.Path)
+	})
+	httpServer := &http.Server{Addr: httpAddr, Handler: mux}
+	go func() {
+		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+	defer httpServer.Close()
+
+	log.Printf("serving HTTP on %s, sending %d requests per second across %d paths", httpAddr, rps, paths)
+	var sent, failed uint64
+	if rps > 0 && paths > 0 {
+		ticker := time.NewTicker(time.Second / time.Duration(rps))
+		defer ticker.Stop()
+		for i := 0; ; i++ {
+			select {
+			case <-ctx.Done():
+				log.Printf("done, sent %d requests, %d failed", atomic.LoadUint64(&sent), atomic.LoadUint64(&failed))
+				return nil
+			case <-ticker.C:
+				url, want := requestURL(httpAddr, i%paths)
+				go func() {
+					atomic.AddUint64(&sent, 1)
+					resp, err := http.Get(url)
+					if err != nil {
+						atomic.AddUint64(&failed, 1)
+						return
+					}
+					resp.Body.Close()
+					if resp.StatusCode != want {
+						atomic.AddUint64(&failed, 1)
+					}
+				}()
+			}
+		}
+	}
+
+	<-ctx.Done()
+	return nil
+}
+
+// requestURL returns the URL of the i-th unique path and the status code it
+// is served with. Even paths match the /items/ pattern, odd paths are not
+// found.
+func requestURL(httpAddr string, i int) (string, int) {
+	if i%2 == 0 {
+		return fmt.Sprintf("http://%s/items/%d", httpAddr, i), http.StatusOK
+	}
+
+	return fmt.Sprintf("http://%s/unknown/%d", httpAddr, i), http.StatusNotFound
+}
@@ -0,0 +1,157 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command app is a cgo target. Each HTTP request it serves computes a
+// checksum of the request path in C, on the system stack, then calls a gRPC
+// service, so instrumented functions run between cgo calls on the same
+// goroutines. It generates load against itself at a configurable rate.
+package main
+
+/*
+#include <stdint.h>
+#include <stdlib.h>
+
+static uint32_t checksum(const char *s, int n) {
+	uint32_t sum = 2166136261u;
+	for (int i = 0; i < n; i++) {
+		sum = (sum ^ (unsigned char)s[i]) * 16777619u;
+	}
+	return sum;
+}
+*/
+import "C"
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func main() {
+	httpAddr := flag.String("http-addr", "localhost:8080", "address the HTTP server listens on")
+	grpcAddr := flag.String("grpc-addr", "localhost:8081", "address the gRPC server listens on")
+	rps := flag.Int("rps", 50, "requests per second sent to the HTTP server, 0 to only serve")
+	duration := flag.Duration("duration", 0, "duration of the load, 0 to run until interrupted")
+	flag.Parse()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	if *duration > 0 {
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+
+	if err := run(ctx, *httpAddr, *grpcAddr, *rps); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(ctx context.Context, httpAddr string, grpcAddr string, rps int) error {
+	grpcLis, err := net.Listen("tcp", grpcAddr)
+	if err != nil {
+		return err
+	}
+	grpcServer := grpc.NewServer()
+	healthpb.RegisterHealthServer(grpcServer, &healthService{})
+	go grpcServer.Serve(grpcLis)
+	defer grpcServer.Stop()
+
+	conn, err := grpc.Dial(grpcAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	mux := http.NewServeMux()
+	mux.Handle("/checksum", &checksumHandler{client: healthpb.NewHealthClient(conn)})
+	httpServer := &http.Server{Addr: httpAddr, Handler: mux}
+	go func() {
+		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+	defer httpServer.Close()
+
+	log.Printf("serving HTTP on %s and gRPC on %s, sending %d requests per second", httpAddr, grpcAddr, rps)
+	var sent, failed uint64
+	if rps > 0 {
+		url := fmt.Sprintf("http://%s/checksum", httpAddr)
+		ticker := time.NewTicker(time.Second / time.Duration(rps))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				log.Printf("done, sent %d requests, %d failed", atomic.LoadUint64(&sent), atomic.LoadUint64(&failed))
+				return nil
+			case <-ticker.C:
+				go func() {
+					atomic.AddUint64(&sent, 1)
+					resp, err := http.Get(url)
+					if err != nil {
+						atomic.AddUint64(&failed, 1)
+						return
+					}
+					resp.Body.Close()
+					if resp.StatusCode != http.StatusOK {
+						atomic.AddUint64(&failed, 1)
+					}
+				}()
+			}
+		}
+	}
+
+	<-ctx.Done()
+	return nil
+}
+
+// checksumHandler computes the checksum of the request path in C before
+// calling the health service.
+type checksumHandler struct {
+	client healthpb.HealthClient
+}
+
+func (h *checksumHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := C.CString(r.URL.Path)
+	sum := C.checksum(path, C.int(len(r.URL.Path)))
+	C.free(unsafe.Pointer(path))
+
+	_, err := h.client.Check(r.Context(), &healthpb.HealthCheckRequest{Service: "checksum"})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	fmt.Fprintf(w, "%08x\n", uint32(sum))
+}
+
+type healthService struct {
+	healthpb.UnimplementedHealthServer
+}
+
+func (s *healthService) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
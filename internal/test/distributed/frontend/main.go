@@ -0,0 +1,119 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command frontend is the HTTP service of the distributed test. Each request
+// it serves calls the backend gRPC service, running in another process, so
+// the trace of the request spans both processes. It generates load against
+// itself at a configurable rate.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func main() {
+	httpAddr := flag.String("http-addr", "localhost:8080", "address the HTTP server listens on")
+	backendAddr := flag.String("backend-addr", "localhost:8081", "address of the backend gRPC server")
+	rps := flag.Int("rps", 50, "requests per second sent to the HTTP server, 0 to only serve")
+	duration := flag.Duration("duration", 0, "duration of the load, 0 to run until interrupted")
+	flag.Parse()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	if *duration > 0 {
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+
+	if err := run(ctx, *httpAddr, *backendAddr, *rps); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(ctx context.Context, httpAddr string, backendAddr string, rps int) error {
+	conn, err := grpc.Dial(backendAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	mux := http.NewServeMux()
+	mux.Handle("/status", &statusHandler{client: healthpb.NewHealthClient(conn)})
+	httpServer := &http.Server{Addr: httpAddr, Handler: mux}
+	go func() {
+		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+	defer httpServer.Close()
+
+	log.Printf("serving HTTP on %s, calling %s, sending %d requests per second", httpAddr, backendAddr, rps)
+	var sent, failed uint64
+	if rps > 0 {
+		url := fmt.Sprintf("http://%s/status", httpAddr)
+		ticker := time.NewTicker(time.Second / time.Duration(rps))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				log.Printf("done, sent %d requests, %d failed", atomic.LoadUint64(&sent), atomic.LoadUint64(&failed))
+				return nil
+			case <-ticker.C:
+				go func() {
+					atomic.AddUint64(&sent, 1)
+					resp, err := http.Get(url)
+					if err != nil {
+						atomic.AddUint64(&failed, 1)
+						return
+					}
+					resp.Body.Close()
+					if resp.StatusCode != http.StatusOK {
+						atomic.AddUint64(&failed, 1)
+					}
+				}()
+			}
+		}
+	}
+
+	<-ctx.Done()
+	return nil
+}
+
+// statusHandler reports the status of the backend.
+type statusHandler struct {
+	client healthpb.HealthClient
+}
+
+func (h *statusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.client.Check(r.Context(), &healthpb.HealthCheckRequest{Service: "frontend"})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	fmt.Fprintln(w, resp.Status)
+}
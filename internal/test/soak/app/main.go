@@ -0,0 +1,173 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command app is the target of soak tests. Each HTTP request it serves calls
+// a gRPC service, which publishes an order to a queue whose consumer writes it
+// to a store. It generates load against itself at a configurable rate.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const statsInterval = time.Minute
+
+func main() {
+	httpAddr := flag.String("http-addr", "localhost:8080", "address the HTTP server listens on")
+	grpcAddr := flag.String("grpc-addr", "localhost:8081", "address the gRPC server listens on")
+	rps := flag.Int("rps", 50, "requests per second sent to the HTTP server, 0 to only serve")
+	duration := flag.Duration("duration", 0, "duration of the load, 0 to run until interrupted")
+	flag.Parse()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	if *duration > 0 {
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+
+	if err := run(ctx, *httpAddr, *grpcAddr, *rps); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(ctx context.Context, httpAddr string, grpcAddr string, rps int) error {
+	queue := newQueue()
+	store := newStore()
+	go queue.consume(ctx, store)
+
+	grpcLis, err := net.Listen("tcp", grpcAddr)
+	if err != nil {
+		return err
+	}
+	grpcServer := grpc.NewServer()
+	healthpb.RegisterHealthServer(grpcServer, &orderService{queue: queue})
+	go grpcServer.Serve(grpcLis)
+	defer grpcServer.Stop()
+
+	conn, err := grpc.Dial(grpcAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	mux := http.NewServeMux()
+	mux.Handle("/order", &orderHandler{client: healthpb.NewHealthClient(conn)})
+	httpServer := &http.Server{Addr: httpAddr, Handler: mux}
+	go func() {
+		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+	defer httpServer.Close()
+
+	log.Printf("serving HTTP on %s and gRPC on %s, sending %d requests per second", httpAddr, grpcAddr, rps)
+	var gen *loadGenerator
+	if rps > 0 {
+		gen = &loadGenerator{url: fmt.Sprintf("http://%s/order", httpAddr)}
+		go gen.run(ctx, rps)
+	}
+
+	ticker := time.NewTicker(statsInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("done, %d orders stored", store.count())
+			return nil
+		case <-ticker.C:
+			if gen != nil {
+				log.Printf("sent %d requests, %d failed, %d orders stored",
+					atomic.LoadUint64(&gen.sent), atomic.LoadUint64(&gen.failed), store.count())
+			}
+		}
+	}
+}
+
+// orderHandler serves HTTP requests by calling the order service.
+type orderHandler struct {
+	client healthpb.HealthClient
+}
+
+func (h *orderHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, err := h.client.Check(r.Context(), &healthpb.HealthCheckRequest{Service: "order"})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// orderService is a gRPC service publishing an order for each call. It
+// implements the health service to avoid generating code for a dedicated
+// one.
+type orderService struct {
+	healthpb.UnimplementedHealthServer
+	queue *queue
+}
+
+func (s *orderService) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	s.queue.publish(order{service: req.Service, created: time.Now()})
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
+
+// loadGenerator sends requests to url at a fixed rate.
+type loadGenerator struct {
+	url    string
+	sent   uint64
+	failed uint64
+}
+
+func (g *loadGenerator) run(ctx context.Context, rps int) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	ticker := time.NewTicker(time.Second / time.Duration(rps))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			go g.send(client)
+		}
+	}
+}
+
+func (g *loadGenerator) send(client *http.Client) {
+	atomic.AddUint64(&g.sent, 1)
+	resp, err := client.Get(g.url)
+	if err != nil {
+		atomic.AddUint64(&g.failed, 1)
+		return
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		atomic.AddUint64(&g.failed, 1)
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// queueSize bounds the orders waiting to be consumed, publishing blocks when
+// it is reached.
+const queueSize = 1024
+
+// storeSize bounds the orders kept by the store so its memory use is stable.
+const storeSize = 10000
+
+type order struct {
+	service string
+	created time.Time
+}
+
+// queue stands in for a message broker between the order service and its
+// consumer.
+type queue struct {
+	orders chan order
+}
+
+func newQueue() *queue {
+	return &queue{orders: make(chan order, queueSize)}
+}
+
+func (q *queue) publish(o order) {
+	q.orders <- o
+}
+
+func (q *queue) consume(ctx context.Context, s *store) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case o := <-q.orders:
+			s.insert(o)
+		}
+	}
+}
+
+// store stands in for a database keeping the last storeSize orders.
+type store struct {
+	mu     sync.Mutex
+	orders []order
+	next   int
+	total  int
+}
+
+func newStore() *store {
+	return &store{orders: make([]order, storeSize)}
+}
+
+func (s *store) insert(o order) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.orders[s.next] = o
+	s.next = (s.next + 1) % storeSize
+	s.total++
+}
+
+func (s *store) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.total
+}
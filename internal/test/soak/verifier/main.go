@@ -0,0 +1,143 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command verifier receives the spans exported by the agent instrumenting
+// the soak app, checks that traces are complete and that the memory of the
+// agent stays stable. A complete trace holds the expected number of spans,
+// exported by the expected number of services. It can also check that span
+// names stay low cardinality, and that services export the spans of the same
+// traces. It exits with a non zero status if a check
+// fails.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+const checkInterval = time.Minute
+
+func main() {
+	otlpAddr := flag.String("otlp-addr", "localhost:4317", "address the OTLP/gRPC trace receiver listens on")
+	agentPID := flag.Int("agent-pid", 0, "PID of the agent whose memory is monitored, 0 to skip the check")
+	duration := flag.Duration("duration", time.Hour, "duration of the soak test")
+	expectedSpans := flag.Int("expected-spans", 3, "number of spans of a complete trace")
+	expectedServices := flag.Int("expected-services", 1, "number of services exporting the spans of a complete trace")
+	settle := flag.Duration("settle", 30*time.Second, "time given to the spans of a trace to be received")
+	minComplete := flag.Float64("min-complete", 0.99, "minimum ratio of complete traces")
+	maxRSSGrowth := flag.Float64("max-rss-growth", 0.2, "maximum growth ratio of the agent RSS after warm up")
+	maxSpanNames := flag.Int("max-span-names", 0, "maximum number of distinct span names, 0 to skip the check")
+	sharedTraceServices := flag.String("shared-trace-services", "", "comma separated services expected to export spans under the same trace IDs, empty to skip the check")
+	flag.Parse()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	ctx, cancel = context.WithTimeout(ctx, *duration)
+	defer cancel()
+
+	lis, err := net.Listen("tcp", *otlpAddr)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var sharedServices []string
+	if *sharedTraceServices != "" {
+		sharedServices = strings.Split(*sharedTraceServices, ",")
+	}
+	traces := newTraceTracker(*expectedSpans, *expectedServices, sharedServices, *settle)
+	server := grpc.NewServer()
+	var names *nameTracker
+	if *maxSpanNames > 0 {
+		names = newNameTracker(*maxSpanNames)
+	}
+	registerReceiver(server, traces, names)
+	go server.Serve(lis)
+	defer server.Stop()
+
+	var memory *memoryMonitor
+	if *agentPID != 0 {
+		memory = newMemoryMonitor(*agentPID, *settle)
+	}
+
+	log.Printf("receiving spans on %s for %s", *otlpAddr, *duration)
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for done := false; !done; {
+		select {
+		case <-ctx.Done():
+			done = true
+		case <-ticker.C:
+		}
+
+		traces.evaluate(time.Now())
+		log.Print(traces)
+		if names != nil {
+			log.Print(names)
+		}
+		if memory != nil {
+			if err := memory.sample(time.Now()); err != nil {
+				log.Fatal(err)
+			}
+			log.Print(memory)
+		}
+	}
+
+	// Give the spans of the last traces time to be received.
+	time.Sleep(*settle)
+	traces.evaluate(time.Now().Add(*settle))
+
+	var failures []string
+	if ratio := traces.completeRatio(); ratio < *minComplete {
+		failures = append(failures, fmt.Sprintf("%.4f of traces complete, expected at least %.4f", ratio, *minComplete))
+	}
+	if len(sharedServices) > 0 {
+		if ratio := traces.sharedRatio(); ratio < *minComplete {
+			failures = append(failures, fmt.Sprintf("%.4f of traces of %s shared by all of them, expected at least %.4f",
+				ratio, *sharedTraceServices, *minComplete))
+		}
+	}
+	if names != nil && names.exceeded() {
+		failures = append(failures, fmt.Sprintf("more than %d distinct span names", *maxSpanNames))
+	}
+	if memory != nil {
+		if growth := memory.growth(); growth > *maxRSSGrowth {
+			failures = append(failures, fmt.Sprintf("agent RSS grew by %.2f, expected at most %.2f", growth, *maxRSSGrowth))
+		}
+	}
+
+	log.Print(traces)
+	if names != nil {
+		log.Print(names)
+	}
+	if memory != nil {
+		log.Print(memory)
+	}
+	for _, f := range failures {
+		log.Print("FAIL: ", f)
+	}
+	if len(failures) > 0 {
+		os.Exit(1)
+	}
+	log.Print("PASS")
+}
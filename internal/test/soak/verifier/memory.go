@@ -0,0 +1,74 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/prometheus/procfs"
+)
+
+// memoryMonitor samples the resident memory of a process. The baseline is
+// taken once warmUp elapsed, after caches and buffers have been allocated.
+type memoryMonitor struct {
+	pid    int
+	start  time.Time
+	warmUp time.Duration
+
+	baseline uint64
+	last     uint64
+	max      uint64
+}
+
+func newMemoryMonitor(pid int, warmUp time.Duration) *memoryMonitor {
+	return &memoryMonitor{pid: pid, start: time.Now(), warmUp: warmUp}
+}
+
+func (m *memoryMonitor) sample(now time.Time) error {
+	proc, err := procfs.NewProc(m.pid)
+	if err != nil {
+		return err
+	}
+
+	status, err := proc.NewStatus()
+	if err != nil {
+		return err
+	}
+
+	m.last = status.VmRSS
+	if m.baseline == 0 && now.Sub(m.start) >= m.warmUp {
+		m.baseline = m.last
+	}
+	if m.last > m.max {
+		m.max = m.last
+	}
+
+	return nil
+}
+
+// growth returns the growth ratio of the last sample over the baseline.
+func (m *memoryMonitor) growth() float64 {
+	if m.baseline == 0 {
+		return 0
+	}
+
+	return float64(m.last)/float64(m.baseline) - 1
+}
+
+func (m *memoryMonitor) String() string {
+	return fmt.Sprintf("agent RSS: %d bytes, baseline: %d bytes, max: %d bytes, growth: %.2f",
+		m.last, m.baseline, m.max, m.growth())
+}
@@ -0,0 +1,61 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// nameTracker counts the distinct span names received. Names are only
+// tracked up to a bound, as a cardinality explosion is what it checks for.
+type nameTracker struct {
+	max int
+
+	mu    sync.Mutex
+	names map[string]bool
+}
+
+func newNameTracker(max int) *nameTracker {
+	return &nameTracker{max: max, names: make(map[string]bool)}
+}
+
+func (t *nameTracker) add(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.names) <= t.max {
+		t.names[name] = true
+	}
+}
+
+// exceeded reports whether more than max distinct names were received.
+func (t *nameTracker) exceeded() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return len(t.names) > t.max
+}
+
+func (t *nameTracker) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.names) > t.max {
+		return fmt.Sprintf("span names: more than %d", t.max)
+	}
+
+	return fmt.Sprintf("span names: %d", len(t.names))
+}
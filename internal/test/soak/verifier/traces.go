@@ -0,0 +1,201 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/grpc"
+)
+
+// receiver implements the OTLP/gRPC trace service.
+type receiver struct {
+	collectortrace.UnimplementedTraceServiceServer
+	traces *traceTracker
+	names  *nameTracker
+}
+
+func registerReceiver(s *grpc.Server, traces *traceTracker, names *nameTracker) {
+	collectortrace.RegisterTraceServiceServer(s, &receiver{traces: traces, names: names})
+}
+
+func (r *receiver) Export(ctx context.Context, req *collectortrace.ExportTraceServiceRequest) (*collectortrace.ExportTraceServiceResponse, error) {
+	now := time.Now()
+	for _, rs := range req.ResourceSpans {
+		service := serviceName(rs.Resource)
+		for _, ss := range rs.ScopeSpans {
+			for _, s := range ss.Spans {
+				r.traces.add(string(s.TraceId), service, now)
+				if r.names != nil {
+					r.names.add(s.Name)
+				}
+			}
+		}
+	}
+
+	return &collectortrace.ExportTraceServiceResponse{}, nil
+}
+
+// serviceName returns the service.name attribute of res.
+func serviceName(res *resourcepb.Resource) string {
+	for _, attr := range res.GetAttributes() {
+		if attr.Key == string(semconv.ServiceNameKey) {
+			return attr.Value.GetStringValue()
+		}
+	}
+
+	return ""
+}
+
+type pendingTrace struct {
+	spans     int
+	services  map[string]bool
+	firstSeen time.Time
+}
+
+// traceTracker counts the spans, and the services exporting them, received
+// per trace. Traces are evaluated once settle elapsed since their first span,
+// then forgotten so memory use does not grow with the duration of the test.
+type traceTracker struct {
+	expectedSpans    int
+	expectedServices int
+	settle           time.Duration
+	// sharedServices are the services expected to export the spans of the
+	// same traces. A trace holding spans of only some of them is split, as
+	// when trace context is not propagated between them.
+	sharedServices []string
+
+	mu         sync.Mutex
+	pending    map[string]*pendingTrace
+	spans      uint64
+	complete   uint64
+	incomplete uint64
+	// late counts spans received for traces already evaluated.
+	late      uint64
+	evaluated map[string]bool
+	// shared and split count the traces holding spans of all and of only
+	// some of sharedServices.
+	shared uint64
+	split  uint64
+}
+
+func newTraceTracker(expectedSpans int, expectedServices int, sharedServices []string, settle time.Duration) *traceTracker {
+	return &traceTracker{
+		expectedSpans:    expectedSpans,
+		expectedServices: expectedServices,
+		sharedServices:   sharedServices,
+		settle:           settle,
+		pending:          make(map[string]*pendingTrace),
+		evaluated:        make(map[string]bool),
+	}
+}
+
+func (t *traceTracker) add(traceID string, service string, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.spans++
+	if t.evaluated[traceID] {
+		t.late++
+		return
+	}
+
+	p, exists := t.pending[traceID]
+	if !exists {
+		p = &pendingTrace{services: make(map[string]bool), firstSeen: now}
+		t.pending[traceID] = p
+	}
+	p.spans++
+	p.services[service] = true
+}
+
+// evaluate classifies the traces first seen at least settle before now.
+func (t *traceTracker) evaluate(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Late spans are only tracked for traces evaluated in the previous round.
+	t.evaluated = make(map[string]bool)
+	for id, p := range t.pending {
+		if now.Sub(p.firstSeen) < t.settle {
+			continue
+		}
+
+		if p.spans >= t.expectedSpans && len(p.services) >= t.expectedServices {
+			t.complete++
+		} else {
+			t.incomplete++
+		}
+
+		var sharing int
+		for _, s := range t.sharedServices {
+			if p.services[s] {
+				sharing++
+			}
+		}
+		if sharing == len(t.sharedServices) && sharing > 0 {
+			t.shared++
+		} else if sharing > 0 {
+			t.split++
+		}
+		t.evaluated[id] = true
+		delete(t.pending, id)
+	}
+}
+
+func (t *traceTracker) completeRatio() float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	total := t.complete + t.incomplete
+	if total == 0 {
+		return 0
+	}
+
+	return float64(t.complete) / float64(total)
+}
+
+// sharedRatio returns the ratio of the traces holding spans of any of
+// sharedServices that hold spans of all of them.
+func (t *traceTracker) sharedRatio() float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	total := t.shared + t.split
+	if total == 0 {
+		return 0
+	}
+
+	return float64(t.shared) / float64(total)
+}
+
+func (t *traceTracker) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := fmt.Sprintf("spans: %d, complete traces: %d, incomplete traces: %d, pending traces: %d, late spans: %d",
+		t.spans, t.complete, t.incomplete, len(t.pending), t.late)
+	if len(t.sharedServices) > 0 {
+		s += fmt.Sprintf(", shared traces: %d, split traces: %d", t.shared, t.split)
+	}
+
+	return s
+}
@@ -0,0 +1,167 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command probedocs generates the compatibility docs of the probes from
+// their registry metadata and the tracked offsets.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/inject"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/registry"
+)
+
+func main() {
+	offsetsPath := flag.String("offsets", "pkg/inject/offset_results.json", "path of the tracked offsets")
+	out := flag.String("out", "docs/probes.md", "path of the generated docs")
+	flag.Parse()
+
+	data, err := ioutil.ReadFile(*offsetsPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var offsets inject.TrackedOffsets
+	if err := json.Unmarshal(data, &offsets); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(*out, render(instrumentors.Probes(), &offsets), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func render(probes []registry.Probe, offsets *inject.TrackedOffsets) []byte {
+	var b bytes.Buffer
+	b.WriteString("<!-- Generated by internal/tools/probedocs, DO NOT EDIT. -->\n\n")
+	b.WriteString("# Probes\n\n")
+	b.WriteString("| Probe | Package | Signals | Semantic conventions | Supported versions |\n")
+	b.WriteString("|---|---|---|---|---|\n")
+	for _, p := range probes {
+		var signals []string
+		for _, s := range p.Signals {
+			signals = append(signals, string(s))
+		}
+
+		var versions []string
+		for _, o := range p.Offsets {
+			if o.Optional {
+				continue
+			}
+			versions = append(versions, fmt.Sprintf("%s %s", moduleName(o.Module), versionRange(offsets, o)))
+		}
+
+		fmt.Fprintf(&b, "| `%s` | `%s` | %s | %s | %s |\n", p.ID, p.Package,
+			strings.Join(signals, ", "), p.SchemaURL, strings.Join(versions, "<br>"))
+	}
+
+	for _, p := range probes {
+		fmt.Fprintf(&b, "\n## %s\n\nFunctions:\n\n", p.ID)
+		for _, f := range p.Functions {
+			fmt.Fprintf(&b, "- `%s`\n", f)
+		}
+		if len(p.OptionalFunctions) > 0 {
+			b.WriteString("\nFunctions of optional and opt-in features:\n\n")
+			for _, f := range p.OptionalFunctions {
+				fmt.Fprintf(&b, "- `%s`\n", f)
+			}
+		}
+
+		for _, o := range p.Offsets {
+			fmt.Fprintf(&b, "\nOffsets looked up at the %s version", moduleName(o.Module))
+			if o.Optional {
+				b.WriteString(", optional")
+			}
+			fmt.Fprintf(&b, " (%s):\n\n", versionRange(offsets, o))
+			for _, f := range o.Fields {
+				fmt.Fprintf(&b, "- `%s.%s`\n", f.StructName, f.Field)
+			}
+			if len(o.Forks) > 0 {
+				fmt.Fprintf(&b, "\nForks matching `%s` are instrumented as %s when these structs have the same layout.\n",
+					strings.Join(o.Forks, "`, `"), moduleName(o.Module))
+			}
+		}
+
+		if len(p.Variants) > 0 {
+			fmt.Fprintf(&b, "\nVariants, the first one matching the %s version is loaded:\n\n", moduleName(p.VariantsModule))
+			for _, v := range p.Variants {
+				constraints := "any version"
+				if v.Constraints != "" {
+					constraints = "`" + v.Constraints + "`"
+				}
+				fmt.Fprintf(&b, "- %s\n", constraints)
+			}
+		}
+	}
+
+	return b.Bytes()
+}
+
+func moduleName(module string) string {
+	if module == "go" {
+		return "Go"
+	}
+	return "`" + module + "`"
+}
+
+// versionRange returns the lowest and highest versions of module at which
+// all the offsets of o are tracked.
+func versionRange(offsets *inject.TrackedOffsets, o registry.Offsets) string {
+	var lib *inject.TrackedLibrary
+	for i := range offsets.Data {
+		if offsets.Data[i].Name == o.Module {
+			lib = &offsets.Data[i]
+		}
+	}
+	if lib == nil {
+		return "untracked"
+	}
+
+	counts := make(map[string]int)
+	for _, f := range o.Fields {
+		for _, dm := range lib.DataMembers {
+			if dm.Struct != f.StructName || dm.Field != f.Field {
+				continue
+			}
+			for _, vo := range dm.Offsets {
+				counts[vo.Version]++
+			}
+		}
+	}
+
+	var versions []*version.Version
+	for v, count := range counts {
+		if count != len(o.Fields) {
+			continue
+		}
+		if parsed, err := version.NewVersion(v); err == nil {
+			versions = append(versions, parsed)
+		}
+	}
+	if len(versions) == 0 {
+		return "untracked"
+	}
+
+	sort.Sort(version.Collection(versions))
+	return fmt.Sprintf("%s to %s", versions[0].Original(), versions[len(versions)-1].Original())
+}
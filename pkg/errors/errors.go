@@ -21,3 +21,9 @@ import "errors"
 var ErrInterrupted = errors.New("interrupted")
 var ErrProcessNotFound = errors.New("process_not_found")
 var ErrABIWrongInstruction = errors.New("could not detect ABI, got wrong instruction")
+var ErrAlreadyInstrumented = errors.New("target already instrumented")
+var ErrLoadTimeout = errors.New("timed out loading instrumentors")
+
+// ErrUnsupportedOS is returned on operating systems other than Linux, where
+// the packages build but cannot instrument processes.
+var ErrUnsupportedOS = errors.New("unsupported operating system, only linux is supported")
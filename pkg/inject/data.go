@@ -14,8 +14,26 @@
 
 package inject
 
+import "time"
+
 type TrackedOffsets struct {
-	Data []TrackedLibrary `json:"data"`
+	// Provenance describes how the offsets were generated, nil if the
+	// generator did not record it.
+	Provenance *Provenance      `json:"provenance,omitempty"`
+	Data       []TrackedLibrary `json:"data"`
+}
+
+// Provenance describes the generation of the tracked offsets, to tell which
+// build produced the offsets embedded in an agent.
+type Provenance struct {
+	// GoVersions are the Go toolchain versions the offsets were built with.
+	GoVersions []string `json:"go_versions"`
+	// TemplateHash is the hash of the program template the offsets were
+	// read from.
+	TemplateHash string    `json:"template_hash"`
+	GeneratedAt  time.Time `json:"generated_at"`
+	// GitCommit is the commit of the generator.
+	GitCommit string `json:"git_commit"`
 }
 
 type TrackedLibrary struct {
@@ -17,7 +17,10 @@ package inject
 import (
 	_ "embed"
 	"encoding/json"
+	"expvar"
 	"runtime"
+	"sync"
+	"time"
 
 	"github.com/cilium/ebpf"
 	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/log"
@@ -29,12 +32,19 @@ var (
 	offsetsData string
 )
 
+// unsupportedLibraries counts, under /debug/vars of the diagnostics server,
+// the probes loaded for each library found in the target at a version
+// without tracked offsets.
+var unsupportedLibraries = expvar.NewMap("unsupported_libraries")
+
 type Injector struct {
-	data      *TrackedOffsets
-	isRegAbi  bool
-	TotalCPUs uint32
-	StartAddr uint64
-	EndAddr   uint64
+	data          *TrackedOffsets
+	unsupportedMu sync.Mutex
+	unsupported   map[string]string
+	isRegAbi      bool
+	TotalCPUs     uint32
+	StartAddr     uint64
+	EndAddr       uint64
 }
 
 func New(target *process.TargetDetails) (*Injector, error) {
@@ -44,15 +54,30 @@ func New(target *process.TargetDetails) (*Injector, error) {
 		return nil, err
 	}
 
+	logProvenance(offsets.Provenance)
 	return &Injector{
-		data:      &offsets,
-		isRegAbi:  target.IsRegistersABI(),
-		TotalCPUs: uint32(runtime.NumCPU()),
-		StartAddr: target.AllocationDetails.Addr,
-		EndAddr:   target.AllocationDetails.EndAddr,
+		data:        &offsets,
+		unsupported: make(map[string]string),
+		isRegAbi:    target.IsRegistersABI(),
+		TotalCPUs:   uint32(runtime.NumCPU()),
+		StartAddr:   target.AllocationDetails.Addr,
+		EndAddr:     target.AllocationDetails.EndAddr,
 	}, nil
 }
 
+// logProvenance logs the provenance of the embedded offsets, to help
+// debugging an agent built with mismatched offsets.
+func logProvenance(p *Provenance) {
+	logger := log.Component(log.ComponentInjector)
+	if p == nil {
+		logger.V(0).Info("offsets database has no provenance")
+		return
+	}
+
+	logger.V(0).Info("loaded offsets database", "go_versions", p.GoVersions, "template_hash", p.TemplateHash,
+		"generated_at", p.GeneratedAt.Format(time.RFC3339), "git_commit", p.GitCommit)
+}
+
 type loadBpfFunc func() (*ebpf.CollectionSpec, error)
 
 type InjectStructField struct {
@@ -69,17 +94,23 @@ func (i *Injector) Inject(loadBpf loadBpfFunc, library string, libVersion string
 
 	injectedVars := make(map[string]interface{})
 
+	missingOffsets := false
 	for _, dm := range fields {
 		offset, found := i.getFieldOffset(library, libVersion, dm.StructName, dm.Field)
 		if !found {
-			log.Logger.V(0).Info("could not find offset", "lib", library, "version", libVersion, "struct", dm.StructName, "field", dm.Field)
+			missingOffsets = true
+			log.Component(log.ComponentInjector).V(0).Info("could not find offset", "lib", library, "version", libVersion, "struct", dm.StructName, "field", dm.Field)
 		} else {
 			injectedVars[dm.VarName] = offset
 		}
 	}
 
+	if missingOffsets {
+		i.reportUnsupported(library, libVersion)
+	}
+
 	i.addCommonInjections(injectedVars, initAlloc)
-	log.Logger.V(0).Info("Injecting variables", "vars", injectedVars)
+	log.Component(log.ComponentInjector).V(0).Info("Injecting variables", "vars", injectedVars)
 	if len(injectedVars) > 0 {
 		err = spec.RewriteConstants(injectedVars)
 		if err != nil {
@@ -90,6 +121,45 @@ func (i *Injector) Inject(loadBpf loadBpfFunc, library string, libVersion string
 	return spec, nil
 }
 
+// HasOffsets reports whether the offsets of all fields are tracked for
+// library at libVersion.
+func (i *Injector) HasOffsets(library string, libVersion string, fields []*InjectStructField) bool {
+	for _, dm := range fields {
+		if _, found := i.getFieldOffset(library, libVersion, dm.StructName, dm.Field); !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (i *Injector) reportUnsupported(library string, libVersion string) {
+	unsupportedLibraries.Add(library+"@"+libVersion, 1)
+
+	i.unsupportedMu.Lock()
+	defer i.unsupportedMu.Unlock()
+	if _, reported := i.unsupported[library]; reported {
+		return
+	}
+
+	i.unsupported[library] = libVersion
+	log.Component(log.ComponentInjector).V(0).Info("library version is not supported, instrumentation may not work",
+		"name", library, "version", libVersion)
+}
+
+// UnsupportedLibraries returns the libraries found in the target at a
+// version offsets are not tracked for, mapped to that version.
+func (i *Injector) UnsupportedLibraries() map[string]string {
+	i.unsupportedMu.Lock()
+	defer i.unsupportedMu.Unlock()
+	result := make(map[string]string, len(i.unsupported))
+	for lib, v := range i.unsupported {
+		result[lib] = v
+	}
+
+	return result
+}
+
 func (i *Injector) addCommonInjections(varsMap map[string]interface{}, initAlloc bool) {
 	varsMap["is_registers_abi"] = i.isRegAbi
 	if initAlloc {
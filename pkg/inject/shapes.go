@@ -0,0 +1,83 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inject
+
+import (
+	"encoding/json"
+
+	"github.com/hashicorp/go-version"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/process"
+)
+
+// StructShapes returns the versions of library the offsets of all fields
+// are tracked for, keyed by the process.ShapeHash of fields at that version.
+// The latest version is kept when several versions share a layout.
+func StructShapes(library string, fields []*InjectStructField) (map[string]string, error) {
+	var offsets TrackedOffsets
+	if err := json.Unmarshal([]byte(offsetsData), &offsets); err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[string][]process.FieldOffset)
+	for _, l := range offsets.Data {
+		if l.Name != library {
+			continue
+		}
+
+		for _, f := range fields {
+			for _, dm := range l.DataMembers {
+				if dm.Struct != f.StructName || dm.Field != f.Field {
+					continue
+				}
+
+				for _, o := range dm.Offsets {
+					byVersion[o.Version] = append(byVersion[o.Version], process.FieldOffset{
+						StructField: process.StructField{Struct: dm.Struct, Field: dm.Field},
+						Offset:      o.Offset,
+					})
+				}
+			}
+		}
+	}
+
+	shapes := make(map[string]string)
+	for v, fieldOffsets := range byVersion {
+		if len(fieldOffsets) != len(fields) {
+			continue
+		}
+
+		hash := process.ShapeHash(library, fieldOffsets)
+		if existing, exists := shapes[hash]; exists && !newer(v, existing) {
+			continue
+		}
+		shapes[hash] = v
+	}
+
+	return shapes, nil
+}
+
+// newer reports whether version a is greater than version b.
+func newer(a string, b string) bool {
+	va, err := version.NewVersion(a)
+	if err != nil {
+		return false
+	}
+	vb, err := version.NewVersion(b)
+	if err != nil {
+		return true
+	}
+
+	return va.GreaterThan(vb)
+}
@@ -30,7 +30,7 @@ func New() *Allocator {
 }
 
 func (a *Allocator) Load(ctx *context.InstrumentorContext) error {
-	logger := log.Logger.WithName("allocator")
+	logger := log.Component(log.ComponentAllocator)
 	logger.V(0).Info("Loading allocator", "start_addr",
 		ctx.TargetDetails.AllocationDetails.Addr, "end_addr", ctx.TargetDetails.AllocationDetails.EndAddr)
 
@@ -54,5 +54,11 @@ func (a *Allocator) mountBpfFS() error {
 		}
 	}
 
+	// Mounting over an existing bpffs would hide the maps pinned to it.
+	var fs unix.Statfs_t
+	if err := unix.Statfs(bpffs.BpfFsPath, &fs); err == nil && fs.Type == unix.BPF_FS_MAGIC {
+		return nil
+	}
+
 	return unix.Mount(bpffs.BpfFsPath, bpffs.BpfFsPath, "bpf", 0, "")
 }
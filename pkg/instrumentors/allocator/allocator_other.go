@@ -0,0 +1,36 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux
+
+package allocator
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/errors"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/context"
+)
+
+type Allocator struct{}
+
+func New() *Allocator {
+	return &Allocator{}
+}
+
+// Load fails, eBPF programs can only be loaded on Linux.
+func (a *Allocator) Load(ctx *context.InstrumentorContext) error {
+	return fmt.Errorf("%w: %s", errors.ErrUnsupportedOS, runtime.GOOS)
+}
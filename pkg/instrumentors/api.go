@@ -26,3 +26,12 @@ type Instrumentor interface {
 	Run(eventsChan chan<- *events.Event)
 	Close()
 }
+
+// OptionalFuncsInstrumentor is implemented by instrumentors attaching to the
+// functions of optional or opt-in features. They are resolved in the target like
+// FuncNames, but the instrumentor is not filtered out when some are missing:
+// the feature is disabled instead.
+type OptionalFuncsInstrumentor interface {
+	Instrumentor
+	OptionalFuncNames() []string
+}
@@ -15,6 +15,7 @@
 #include "arguments.h"
 #include "span_context.h"
 #include "go_context.h"
+#include "requests.h"
 
 char __license[] SEC("license") = "Dual MIT/GPL";
 
@@ -27,6 +28,7 @@ struct http_request_t {
     char method[MAX_SIZE];
     char path[MAX_SIZE];
     struct span_context sc;
+    char route[MAX_SIZE];
 };
 
 struct {
@@ -36,6 +38,15 @@ struct {
 	__uint(max_entries, MAX_CONCURRENT);
 } context_to_http_events SEC(".maps");
 
+// Route matches of the requests being served. LRU, as ServeHTTP does not
+// return on panic.
+struct {
+	__uint(type, BPF_MAP_TYPE_LRU_HASH);
+	__type(key, void*);
+	__type(value, void*);
+	__uint(max_entries, MAX_CONCURRENT);
+} context_to_route_match SEC(".maps");
+
 struct {
 	__uint(type, BPF_MAP_TYPE_PERF_EVENT_ARRAY);
 } events SEC(".maps");
@@ -45,6 +56,36 @@ volatile const u64 method_ptr_pos;
 volatile const u64 url_ptr_pos;
 volatile const u64 path_ptr_pos;
 volatile const u64 ctx_ptr_pos;
+volatile const bool read_route_template;
+volatile const u64 route_match_route_pos;
+volatile const u64 route_conf_pos;
+volatile const u64 route_conf_regexp_pos;
+volatile const u64 regexp_group_path_pos;
+volatile const u64 route_regexp_template_pos;
+volatile const u64 max_url_size;
+
+// Reads RouteMatch.Route.regexp.path.template, the path template of the matched route
+static __always_inline void read_route_template_from_match(void *match_ptr, char *route) {
+    void *route_ptr = 0;
+    bpf_probe_read(&route_ptr, sizeof(route_ptr), (void *)(match_ptr+route_match_route_pos));
+    if (route_ptr == NULL) {
+        return;
+    }
+
+    void *regexp_ptr = 0;
+    bpf_probe_read(&regexp_ptr, sizeof(regexp_ptr), (void *)(route_ptr+route_conf_pos+route_conf_regexp_pos+regexp_group_path_pos));
+    if (regexp_ptr == NULL) {
+        return;
+    }
+
+    void *template_ptr = 0;
+    bpf_probe_read(&template_ptr, sizeof(template_ptr), (void *)(regexp_ptr+route_regexp_template_pos));
+    u64 template_len = 0;
+    bpf_probe_read(&template_len, sizeof(template_len), (void *)(regexp_ptr+(route_regexp_template_pos+8)));
+    u64 template_size = MAX_SIZE;
+    template_size = template_size < template_len ? template_size : template_len;
+    bpf_probe_read(route, template_size, template_ptr);
+}
 
 // This instrumentation attaches uprobe to the following function:
 // func (mux *ServeMux) ServeHTTP(w ResponseWriter, r *Request)
@@ -73,7 +114,9 @@ int uprobe_GorillaMux_ServeHTTP(struct pt_regs *ctx) {
     bpf_probe_read(&path_ptr, sizeof(path_ptr), (void *)(url_ptr+path_ptr_pos));
     u64 path_len = 0;
     bpf_probe_read(&path_len, sizeof(path_len), (void *)(url_ptr+(path_ptr_pos+8)));
-    u64 path_size = sizeof(httpReq.path);
+    // Read the volatile limit once so the verifier sees the bounded value
+    u64 url_size_limit = max_url_size;
+    u64 path_size = url_size_limit < sizeof(httpReq.path) ? url_size_limit : sizeof(httpReq.path);
     path_size = path_size < path_len ? path_size : path_len;
     bpf_probe_read(&httpReq.path, path_size, path_ptr);
 
@@ -83,7 +126,9 @@ int uprobe_GorillaMux_ServeHTTP(struct pt_regs *ctx) {
 
     // Write event
     httpReq.sc = generate_span_context();
-    bpf_map_update_elem(&context_to_http_events, &ctx_iface, &httpReq, 0);
+    if (start_request(&context_to_http_events, &ctx_iface, &httpReq) != 0) {
+        return 0;
+    }
     long res = bpf_map_update_elem(&spans_in_progress, &ctx_iface, &httpReq.sc, 0);
     return 0;
 }
@@ -95,12 +140,49 @@ int uprobe_GorillaMux_ServeHTTP_Returns(struct pt_regs *ctx) {
     void *ctx_iface = 0;
     bpf_probe_read(&ctx_iface, sizeof(ctx_iface), (void *)(req_ptr+ctx_ptr_pos+8));
 
-    void* httpReq_ptr = bpf_map_lookup_elem(&context_to_http_events, &ctx_iface);
+    void* httpReq_ptr = end_request(&context_to_http_events, &ctx_iface);
+    if (httpReq_ptr == NULL) {
+        bpf_map_delete_elem(&context_to_route_match, &ctx_iface);
+        return 0;
+    }
     struct http_request_t httpReq = {};
     bpf_probe_read(&httpReq, sizeof(httpReq), httpReq_ptr);
     httpReq.end_time = bpf_ktime_get_boot_ns();
+
+    void **match_ptr = bpf_map_lookup_elem(&context_to_route_match, &ctx_iface);
+    if (match_ptr != NULL) {
+        read_route_template_from_match(*match_ptr, httpReq.route);
+        bpf_map_delete_elem(&context_to_route_match, &ctx_iface);
+    }
+
     bpf_perf_event_output(ctx, &events, BPF_F_CURRENT_CPU, &httpReq, sizeof(httpReq));
     bpf_map_delete_elem(&context_to_http_events, &ctx_iface);
     bpf_map_delete_elem(&spans_in_progress, &ctx_iface);
     return 0;
-}
\ No newline at end of file
+}
+// This instrumentation attaches uprobe to the following function:
+// func (r *Router) Match(req *http.Request, match *RouteMatch) bool
+// The RouteMatch escapes to the heap, its route is read once ServeHTTP returns.
+SEC("uprobe/Router_Match")
+int uprobe_Router_Match(struct pt_regs *ctx) {
+    if (!read_route_template) {
+        return 0;
+    }
+
+    u64 request_pos = 2;
+    u64 match_pos = 3;
+    void* req_ptr = get_argument(ctx, request_pos);
+    void* match_ptr = get_argument(ctx, match_pos);
+    void *ctx_iface = 0;
+    bpf_probe_read(&ctx_iface, sizeof(ctx_iface), (void *)(req_ptr+ctx_ptr_pos+8));
+
+    // Match is also called outside ServeHTTP, such as by middlewares, only
+    // matches of the requests being served are deleted on return
+    if (bpf_map_lookup_elem(&context_to_http_events, &ctx_iface) == NULL) {
+        return 0;
+    }
+
+    // Subrouters are matched with the same RouteMatch, keep the top level one
+    bpf_map_update_elem(&context_to_route_match, &ctx_iface, &match_ptr, BPF_NOEXIST);
+    return 0;
+}
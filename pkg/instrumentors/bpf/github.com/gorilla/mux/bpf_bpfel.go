@@ -56,6 +56,7 @@ type bpfSpecs struct {
 type bpfProgramSpecs struct {
 	UprobeGorillaMuxServeHTTP         *ebpf.ProgramSpec `ebpf:"uprobe_GorillaMux_ServeHTTP"`
 	UprobeGorillaMuxServeHTTP_Returns *ebpf.ProgramSpec `ebpf:"uprobe_GorillaMux_ServeHTTP_Returns"`
+	UprobeRouterMatch                 *ebpf.ProgramSpec `ebpf:"uprobe_Router_Match"`
 }
 
 // bpfMapSpecs contains maps before they are loaded into the kernel.
@@ -63,6 +64,8 @@ type bpfProgramSpecs struct {
 // It can be passed ebpf.CollectionSpec.Assign.
 type bpfMapSpecs struct {
 	ContextToHttpEvents *ebpf.MapSpec `ebpf:"context_to_http_events"`
+	ContextToRouteMatch *ebpf.MapSpec `ebpf:"context_to_route_match"`
+	DroppedRequests     *ebpf.MapSpec `ebpf:"dropped_requests"`
 	Events              *ebpf.MapSpec `ebpf:"events"`
 	SpansInProgress     *ebpf.MapSpec `ebpf:"spans_in_progress"`
 }
@@ -87,6 +90,8 @@ func (o *bpfObjects) Close() error {
 // It can be passed to loadBpfObjects or ebpf.CollectionSpec.LoadAndAssign.
 type bpfMaps struct {
 	ContextToHttpEvents *ebpf.Map `ebpf:"context_to_http_events"`
+	ContextToRouteMatch *ebpf.Map `ebpf:"context_to_route_match"`
+	DroppedRequests     *ebpf.Map `ebpf:"dropped_requests"`
 	Events              *ebpf.Map `ebpf:"events"`
 	SpansInProgress     *ebpf.Map `ebpf:"spans_in_progress"`
 }
@@ -94,6 +99,8 @@ type bpfMaps struct {
 func (m *bpfMaps) Close() error {
 	return _BpfClose(
 		m.ContextToHttpEvents,
+		m.ContextToRouteMatch,
+		m.DroppedRequests,
 		m.Events,
 		m.SpansInProgress,
 	)
@@ -105,12 +112,14 @@ func (m *bpfMaps) Close() error {
 type bpfPrograms struct {
 	UprobeGorillaMuxServeHTTP         *ebpf.Program `ebpf:"uprobe_GorillaMux_ServeHTTP"`
 	UprobeGorillaMuxServeHTTP_Returns *ebpf.Program `ebpf:"uprobe_GorillaMux_ServeHTTP_Returns"`
+	UprobeRouterMatch                 *ebpf.Program `ebpf:"uprobe_Router_Match"`
 }
 
 func (p *bpfPrograms) Close() error {
 	return _BpfClose(
 		p.UprobeGorillaMuxServeHTTP,
 		p.UprobeGorillaMuxServeHTTP_Returns,
+		p.UprobeRouterMatch,
 	)
 }
 
@@ -18,21 +18,20 @@ import (
 	"bytes"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"os"
 
-	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/bpffs"
-
 	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/link"
 	"github.com/cilium/ebpf/perf"
 	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/inject"
 	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/context"
 	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/events"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/registry"
 	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/log"
 	"go.opentelemetry.io/otel/attribute"
 	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
 	"go.opentelemetry.io/otel/trace"
-	"golang.org/x/sys/unix"
 )
 
 //go:generate go run github.com/cilium/ebpf/cmd/bpf2go -target bpfel -cc clang -cflags $CFLAGS bpf ./bpf/probe.bpf.c
@@ -43,19 +42,119 @@ type HttpEvent struct {
 	Method      [100]byte
 	Path        [100]byte
 	SpanContext context.EbpfSpanContext
+	Route       [100]byte
 }
 
 type gorillaMuxInstrumentor struct {
+	libVersion   string
+	maxURLSize   int
 	bpfObjects   *bpfObjects
 	uprobe       link.Link
 	returnProbs  []link.Link
+	matchProbe   link.Link
 	eventsReader *perf.Reader
 }
 
+// requestOffsets are the net/http struct fields read by the probe.
+var requestOffsets = []*inject.InjectStructField{
+	{
+		VarName:    "method_ptr_pos",
+		StructName: "net/http.Request",
+		Field:      "Method",
+	},
+	{
+		VarName:    "url_ptr_pos",
+		StructName: "net/http.Request",
+		Field:      "URL",
+	},
+	{
+		VarName:    "ctx_ptr_pos",
+		StructName: "net/http.Request",
+		Field:      "ctx",
+	},
+	{
+		VarName:    "path_ptr_pos",
+		StructName: "net/url.URL",
+		Field:      "Path",
+	},
+}
+
+// matchFuncName is the function recording the route a request matched. It
+// may be missing from targets, spans are then named after their method.
+const matchFuncName = "github.com/gorilla/mux.(*Router).Match"
+
+// routeTemplateOffsets are the gorilla/mux struct fields read to name spans
+// after the matched route template.
+var routeTemplateOffsets = []*inject.InjectStructField{
+	{
+		VarName:    "route_match_route_pos",
+		StructName: "github.com/gorilla/mux.RouteMatch",
+		Field:      "Route",
+	},
+	{
+		VarName:    "route_conf_pos",
+		StructName: "github.com/gorilla/mux.Route",
+		Field:      "routeConf",
+	},
+	{
+		VarName:    "route_conf_regexp_pos",
+		StructName: "github.com/gorilla/mux.routeConf",
+		Field:      "regexp",
+	},
+	{
+		VarName:    "regexp_group_path_pos",
+		StructName: "github.com/gorilla/mux.routeRegexpGroup",
+		Field:      "path",
+	},
+	{
+		VarName:    "route_regexp_template_pos",
+		StructName: "github.com/gorilla/mux.routeRegexp",
+		Field:      "template",
+	},
+}
+
 func New() *gorillaMuxInstrumentor {
 	return &gorillaMuxInstrumentor{}
 }
 
+// Probe describes the probe of this package.
+func Probe() registry.Probe {
+	i := New()
+	return registry.Probe{
+		ID:                i.LibraryName(),
+		Package:           "github.com/gorilla/mux",
+		Functions:         i.FuncNames(),
+		OptionalFunctions: i.OptionalFuncNames(),
+		Offsets: []registry.Offsets{
+			{Module: "go", Fields: requestOffsets},
+			{
+				Module:   "github.com/gorilla/mux",
+				Fields:   routeTemplateOffsets,
+				Optional: true,
+				// Forks keeping the upstream package name, such as
+				// maintained forks of the archived repository.
+				Forks: []string{"github.com/*/mux"},
+			},
+		},
+		Signals:        []registry.Signal{registry.SignalTraces},
+		SchemaURL:      semconv.SchemaURL,
+		VariantsModule: "github.com/gorilla/mux",
+		Variants: []registry.Variant{
+			{
+				// The route path template is stored in the embedded
+				// routeConf of mux.Route since v1.7.0.
+				Constraints: ">= v1.7.0",
+				Load:        loadBpf,
+				Offsets: []registry.Offsets{
+					{Module: "github.com/gorilla/mux", Fields: routeTemplateOffsets},
+				},
+				Constants: map[string]interface{}{"read_route_template": true},
+			},
+			{Load: loadBpf},
+		},
+	}
+}
+
 func (g *gorillaMuxInstrumentor) LibraryName() string {
 	return "github.com/gorilla/mux"
 }
@@ -64,50 +163,65 @@ func (g *gorillaMuxInstrumentor) FuncNames() []string {
 	return []string{"github.com/gorilla/mux.(*Router).ServeHTTP"}
 }
 
+// OptionalFuncNames returns the function reading route templates.
+func (g *gorillaMuxInstrumentor) OptionalFuncNames() []string {
+	return []string{matchFuncName}
+}
+
 func (g *gorillaMuxInstrumentor) Load(ctx *context.InstrumentorContext) error {
-	spec, err := ctx.Injector.Inject(loadBpf, "go", ctx.TargetDetails.GoVersion.Original(), []*inject.InjectStructField{
-		{
-			VarName:    "method_ptr_pos",
-			StructName: "net/http.Request",
-			Field:      "Method",
-		},
-		{
-			VarName:    "url_ptr_pos",
-			StructName: "net/http.Request",
-			Field:      "URL",
-		},
-		{
-			VarName:    "ctx_ptr_pos",
-			StructName: "net/http.Request",
-			Field:      "ctx",
-		},
-		{
-			VarName:    "path_ptr_pos",
-			StructName: "net/url.URL",
-			Field:      "Path",
-		},
-	}, false)
+	g.libVersion = ctx.TargetDetails.Libraries[g.LibraryName()]
+	variant := ctx.Variant
+	if variant == nil {
+		return fmt.Errorf("no variant of %s selected", g.LibraryName())
+	}
 
+	spec, err := ctx.Injector.Inject(variant.Load, "go", ctx.TargetDetails.GoVersion.Original(), requestOffsets, false)
+	if err != nil {
+		return err
+	}
+
+	readRouteTemplate := g.readsRouteTemplate(ctx, variant)
+	if readRouteTemplate {
+		for _, o := range variant.Offsets {
+			spec, err = ctx.Injector.Inject(func() (*ebpf.CollectionSpec, error) { return spec, nil },
+				o.Module, ctx.TargetDetails.Libraries[o.Module], o.Fields, false)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(variant.Constants) > 0 {
+			err = spec.RewriteConstants(variant.Constants)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	g.maxURLSize = context.ReadLimit(ctx.ReadLimits.URLSize, len(HttpEvent{}.Path))
+	err = spec.RewriteConstants(map[string]interface{}{"max_url_size": uint64(g.maxURLSize)})
+	if err != nil {
+		return err
+	}
+
+	err = ctx.LimitRequests(spec, "context_to_http_events", "context_to_route_match")
 	if err != nil {
 		return err
 	}
 
 	g.bpfObjects = &bpfObjects{}
-	err = spec.LoadAndAssign(g.bpfObjects, &ebpf.CollectionOptions{
-		Maps: ebpf.MapOptions{
-			PinPath: bpffs.BpfFsPath,
-		},
-	})
+	err = ctx.LoadAndAssign(g.LibraryName(), spec, g.bpfObjects)
 	if err != nil {
 		return err
 	}
+	ctx.TrackDroppedRequests(g.LibraryName(), g.bpfObjects.DroppedRequests)
 
 	offset, err := ctx.TargetDetails.GetFunctionOffset(g.FuncNames()[0])
 	if err != nil {
 		return err
 	}
 
-	up, err := ctx.Executable.Uprobe("", g.bpfObjects.UprobeGorillaMuxServeHTTP, &link.UprobeOptions{
+	up, err := ctx.ExecutableFor(g.FuncNames()[0]).Uprobe("", g.bpfObjects.UprobeGorillaMuxServeHTTP, &link.UprobeOptions{
 		Offset: offset,
 	})
 	if err != nil {
@@ -121,7 +235,7 @@ func (g *gorillaMuxInstrumentor) Load(ctx *context.InstrumentorContext) error {
 	}
 
 	for _, ret := range retOffsets {
-		retProbe, err := ctx.Executable.Uprobe("", g.bpfObjects.UprobeGorillaMuxServeHTTP_Returns, &link.UprobeOptions{
+		retProbe, err := ctx.ExecutableFor(g.FuncNames()[0]).Uprobe("", g.bpfObjects.UprobeGorillaMuxServeHTTP_Returns, &link.UprobeOptions{
 			Offset: ret,
 		})
 		if err != nil {
@@ -130,6 +244,20 @@ func (g *gorillaMuxInstrumentor) Load(ctx *context.InstrumentorContext) error {
 		g.returnProbs = append(g.returnProbs, retProbe)
 	}
 
+	if readRouteTemplate {
+		matchOffset, err := ctx.TargetDetails.GetFunctionOffset(matchFuncName)
+		if err != nil {
+			return err
+		}
+		matchProbe, err := ctx.ExecutableFor(matchFuncName).Uprobe("", g.bpfObjects.UprobeRouterMatch, &link.UprobeOptions{
+			Offset: matchOffset,
+		})
+		if err != nil {
+			return err
+		}
+		g.matchProbe = matchProbe
+	}
+
 	rd, err := perf.NewReader(g.bpfObjects.Events, os.Getpagesize())
 	if err != nil {
 		return err
@@ -139,8 +267,36 @@ func (g *gorillaMuxInstrumentor) Load(ctx *context.InstrumentorContext) error {
 	return nil
 }
 
+// readsRouteTemplate reports whether spans are named after the route template
+// requests matched. It requires a variant reading them, the function
+// recording the matched route, and the offsets of the template at the
+// version of the target, which are only tracked up to the latest release
+// supported.
+func (g *gorillaMuxInstrumentor) readsRouteTemplate(ctx *context.InstrumentorContext, variant *registry.Variant) bool {
+	if len(variant.Offsets) == 0 {
+		return false
+	}
+
+	if !ctx.TargetDetails.HasFunctions(matchFuncName) {
+		log.Probe(g.LibraryName()).V(0).Info("function matching routes not found in target, naming spans after their method",
+			"function", matchFuncName)
+		return false
+	}
+
+	for _, o := range variant.Offsets {
+		version := ctx.TargetDetails.Libraries[o.Module]
+		if !ctx.Injector.HasOffsets(o.Module, version, o.Fields) {
+			log.Probe(g.LibraryName()).V(0).Info("route template offsets not tracked, naming spans after their method",
+				"module", o.Module, "version", version)
+			return false
+		}
+	}
+
+	return true
+}
+
 func (g *gorillaMuxInstrumentor) Run(eventsChan chan<- *events.Event) {
-	logger := log.Logger.WithName("gorilla/mux-instrumentor")
+	logger := log.Probe(g.LibraryName())
 	var event HttpEvent
 	for {
 		record, err := g.eventsReader.Read()
@@ -148,7 +304,7 @@ func (g *gorillaMuxInstrumentor) Run(eventsChan chan<- *events.Event) {
 			if errors.Is(err, perf.ErrClosed) {
 				return
 			}
-			logger.Error(err, "error reading from perf reader")
+			log.Error(logger, log.ErrProbeRead, err, "error reading from perf reader")
 			continue
 		}
 
@@ -158,7 +314,7 @@ func (g *gorillaMuxInstrumentor) Run(eventsChan chan<- *events.Event) {
 		}
 
 		if err := binary.Read(bytes.NewBuffer(record.RawSample), binary.LittleEndian, &event); err != nil {
-			logger.Error(err, "error parsing perf event")
+			log.Error(logger, log.ErrEventDecode, err, "error parsing perf event")
 			continue
 		}
 
@@ -167,8 +323,10 @@ func (g *gorillaMuxInstrumentor) Run(eventsChan chan<- *events.Event) {
 }
 
 func (g *gorillaMuxInstrumentor) convertEvent(e *HttpEvent) *events.Event {
-	method := unix.ByteSliceToString(e.Method[:])
-	path := unix.ByteSliceToString(e.Path[:])
+	var strs events.StringReader
+	method := strs.Read(e.Method[:])
+	path := strs.ReadLimited(e.Path[:], g.maxURLSize)
+	route := strs.Read(e.Route[:])
 
 	sc := trace.NewSpanContext(trace.SpanContextConfig{
 		TraceID:    e.SpanContext.TraceID,
@@ -176,22 +334,36 @@ func (g *gorillaMuxInstrumentor) convertEvent(e *HttpEvent) *events.Event {
 		TraceFlags: trace.FlagsSampled,
 	})
 
+	// Requests no route matched are named after their method, as naming them
+	// after their path would produce an unbounded number of span names.
+	name := "HTTP " + method
+	attrs := []attribute.KeyValue{
+		semconv.HTTPMethodKey.String(method),
+		semconv.HTTPTargetKey.String(path),
+	}
+
+	// Prefer the low cardinality route template, e.g. /users/{id}
+	if route != "" {
+		name = route
+		attrs = append(attrs, semconv.HTTPRouteKey.String(route))
+	}
+
+	attrs = append(attrs, strs.Attributes()...)
+
 	return &events.Event{
-		Library:     g.LibraryName(),
-		Name:        path,
-		Kind:        trace.SpanKindServer,
-		StartTime:   int64(e.StartTime),
-		EndTime:     int64(e.EndTime),
-		SpanContext: &sc,
-		Attributes: []attribute.KeyValue{
-			semconv.HTTPMethodKey.String(method),
-			semconv.HTTPTargetKey.String(path),
-		},
+		Library:        g.LibraryName(),
+		LibraryVersion: g.libVersion,
+		Name:           name,
+		Kind:           trace.SpanKindServer,
+		StartTime:      int64(e.StartTime),
+		EndTime:        int64(e.EndTime),
+		SpanContext:    &sc,
+		Attributes:     attrs,
 	}
 }
 
 func (g *gorillaMuxInstrumentor) Close() {
-	log.Logger.V(0).Info("closing gorilla/mux instrumentor")
+	log.Probe(g.LibraryName()).V(0).Info("closing gorilla/mux instrumentor")
 	if g.eventsReader != nil {
 		g.eventsReader.Close()
 	}
@@ -204,6 +376,10 @@ func (g *gorillaMuxInstrumentor) Close() {
 		r.Close()
 	}
 
+	if g.matchProbe != nil {
+		g.matchProbe.Close()
+	}
+
 	if g.bpfObjects != nil {
 		g.bpfObjects.Close()
 	}
@@ -0,0 +1,62 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mux
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestConvertEventSpanNameCardinality(t *testing.T) {
+	const paths = 10000
+	tests := []struct {
+		name      string
+		route     string
+		wantNames map[string]bool
+	}{
+		{
+			name:      "matched route",
+			route:     "/items/{id}",
+			wantNames: map[string]bool{"/items/{id}": true},
+		},
+		{
+			name:      "no route matched",
+			wantNames: map[string]bool{"HTTP GET": true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &gorillaMuxInstrumentor{maxURLSize: 100}
+			names := make(map[string]bool)
+			for i := 0; i < paths; i++ {
+				var e HttpEvent
+				copy(e.Method[:], "GET")
+				copy(e.Path[:], fmt.Sprintf("/items/%d", i))
+				copy(e.Route[:], tt.route)
+				names[g.convertEvent(&e).Name] = true
+			}
+
+			if len(names) != len(tt.wantNames) {
+				t.Fatalf("%d paths produced %d span names, want %d", paths, len(names), len(tt.wantNames))
+			}
+			for name := range names {
+				if !tt.wantNames[name] {
+					t.Errorf("unexpected span name %q", name)
+				}
+			}
+		})
+	}
+}
@@ -16,12 +16,16 @@
 #include "go_types.h"
 #include "span_context.h"
 #include "go_context.h"
+#include "grpc_messages.h"
+#include "goroutines.h"
+#include "requests.h"
 
 char __license[] SEC("license") = "Dual MIT/GPL";
 
 #define MAX_SIZE 50
 #define MAX_CONCURRENT 50
 #define MAX_HEADERS_BUFF_SIZE 500
+#define MAX_IP_SIZE 16
 
 struct grpc_request_t
 {
@@ -31,6 +35,12 @@ struct grpc_request_t
     char target[MAX_SIZE];
     struct span_context sc;
     struct span_context psc;
+    struct grpc_messages_t messages;
+    u8 peer_ip[MAX_IP_SIZE];
+    u64 peer_ip_len;
+    u64 peer_port;
+    // Goroutine the call is made from, to limit the spans per goroutine
+    u64 goroutine;
 };
 
 struct hpack_header_field
@@ -68,6 +78,12 @@ struct
 
 // Injected in init
 volatile const u64 clientconn_target_ptr_pos;
+volatile const u64 stream_ctx_pos;
+volatile const u64 http2client_remote_addr_pos;
+volatile const u64 tcp_addr_ip_pos;
+volatile const u64 tcp_addr_port_pos;
+volatile const bool read_peer_address;
+volatile const bool record_messages;
 
 // This instrumentation attaches uprobe to the following function:
 // func (cc *ClientConn) Invoke(ctx context.Context, method string, args, reply interface{}, opts ...CallOption) error
@@ -100,9 +116,14 @@ int uprobe_ClientConn_Invoke(struct pt_regs *ctx)
     target_size = target_size < target_len ? target_size : target_len;
     bpf_probe_read(&grpcReq.target, target_size, target_ptr);
 
+    if (is_registers_abi)
+    {
+        grpcReq.goroutine = (u64)current_goroutine(ctx);
+    }
+
     // Write event
     void *context_ptr = get_argument(ctx, context_pos);
-    bpf_map_update_elem(&context_to_grpc_events, &context_ptr, &grpcReq, 0);
+    start_request(&context_to_grpc_events, &context_ptr, &grpcReq);
     return 0;
 }
 
@@ -111,7 +132,11 @@ int uprobe_ClientConn_Invoke_Returns(struct pt_regs *ctx)
 {
     u64 context_pos = 3;
     void *context_ptr = get_argument(ctx, context_pos);
-    void *grpcReq_ptr = bpf_map_lookup_elem(&context_to_grpc_events, &context_ptr);
+    void *grpcReq_ptr = end_request(&context_to_grpc_events, &context_ptr);
+    if (grpcReq_ptr == NULL)
+    {
+        return 0;
+    }
     struct grpc_request_t grpcReq = {};
     bpf_probe_read(&grpcReq, sizeof(grpcReq), grpcReq_ptr);
 
@@ -154,37 +179,134 @@ int uprobe_Http2Client_CreateHeaderFields(struct pt_regs *ctx)
     char key[11] = "traceparent";
     struct go_string key_str = write_user_go_string(key, sizeof(key));
 
-    // Get grpc request struct
+    // Get grpc request struct, it is updated in place as it does not fit
+    // on the stack along with the headers
     void *context_ptr = 0;
     bpf_probe_read(&context_ptr, sizeof(context_ptr), (void *)(ctx->rsp + (context_pointer_pos * 8)));
     void *parent_ctx = find_context_in_map(context_ptr, &context_to_grpc_events);
-    void *grpcReq_ptr = bpf_map_lookup_elem(&context_to_grpc_events, &parent_ctx);
-    struct grpc_request_t grpcReq = {};
-    bpf_probe_read(&grpcReq, sizeof(grpcReq), grpcReq_ptr);
+    struct grpc_request_t *grpcReq = bpf_map_lookup_elem(&context_to_grpc_events, &parent_ctx);
+    if (grpcReq == NULL)
+    {
+        return 0;
+    }
 
     // Get parent if exists
+    // Fall back to the span of the handler the goroutine was spawned from
     void *parent_span_ctx = find_context_in_map(context_ptr, &spans_in_progress);
+    struct span_context *goroutine_sc = NULL;
+    if (parent_span_ctx == NULL)
+    {
+        goroutine_sc = find_goroutine_span(ctx);
+    }
     if (parent_span_ctx != NULL)
     {
         void *psc_ptr = bpf_map_lookup_elem(&spans_in_progress, &parent_span_ctx);
-        bpf_probe_read(&grpcReq.psc, sizeof(grpcReq.psc), psc_ptr);
-        copy_byte_arrays(grpcReq.psc.TraceID, grpcReq.sc.TraceID, TRACE_ID_SIZE);
-        generate_random_bytes(grpcReq.sc.SpanID, SPAN_ID_SIZE);
+        bpf_probe_read(&grpcReq->psc, sizeof(grpcReq->psc), psc_ptr);
+        copy_byte_arrays(grpcReq->psc.TraceID, grpcReq->sc.TraceID, TRACE_ID_SIZE);
+        generate_random_bytes(grpcReq->sc.SpanID, SPAN_ID_SIZE);
+    }
+    else if (goroutine_sc != NULL)
+    {
+        grpcReq->psc = *goroutine_sc;
+        copy_byte_arrays(grpcReq->psc.TraceID, grpcReq->sc.TraceID, TRACE_ID_SIZE);
+        generate_random_bytes(grpcReq->sc.SpanID, SPAN_ID_SIZE);
     }
     else
     {
-        grpcReq.sc = generate_span_context();
+        grpcReq->sc = generate_span_context();
     }
 
     // Write headers
     char val[SPAN_CONTEXT_STRING_SIZE];
-    span_context_to_w3c_string(&grpcReq.sc, val);
+    span_context_to_w3c_string(&grpcReq->sc, val);
     struct go_string val_str = write_user_go_string(val, sizeof(val));
     struct hpack_header_field hf = {};
     hf.name = key_str;
     hf.value = val_str;
     append_item_to_slice(&slice, &hf, sizeof(hf), &slice_user_ptr, &headers_buff_map);
-    bpf_map_update_elem(&context_to_grpc_events, &parent_ctx, &grpcReq, 0);
 
     return 0;
-}
\ No newline at end of file
+}
+static __always_inline struct grpc_request_t *find_request_by_stream(void *stream_ptr)
+{
+    // The stream context is derived from the context passed to Invoke
+    void *context_ptr = 0;
+    bpf_probe_read(&context_ptr, sizeof(context_ptr), (void *)(stream_ptr + (stream_ctx_pos + 8)));
+    void *parent_ctx = find_context_in_map(context_ptr, &context_to_grpc_events);
+    if (parent_ctx == NULL)
+    {
+        return NULL;
+    }
+
+    return bpf_map_lookup_elem(&context_to_grpc_events, &parent_ctx);
+}
+
+// Reads the address of the server the transport is connected to, the
+// subchannel picked by the balancer, from http2Client.remoteAddr. Only TCP
+// addresses are read, the net.Addr of other transports do not hold an IP.
+static __always_inline void read_remote_address(void *http2client_ptr, struct grpc_request_t *grpcReq)
+{
+    // remoteAddr is a net.Addr interface, holding a *net.TCPAddr
+    void *addr_ptr = 0;
+    bpf_probe_read(&addr_ptr, sizeof(addr_ptr), (void *)(http2client_ptr + http2client_remote_addr_pos + 8));
+    if (addr_ptr == NULL)
+    {
+        return;
+    }
+
+    void *ip_ptr = 0;
+    bpf_probe_read(&ip_ptr, sizeof(ip_ptr), (void *)(addr_ptr + tcp_addr_ip_pos));
+    u64 ip_len = 0;
+    bpf_probe_read(&ip_len, sizeof(ip_len), (void *)(addr_ptr + tcp_addr_ip_pos + 8));
+    if (ip_len != 4 && ip_len != MAX_IP_SIZE)
+    {
+        return;
+    }
+
+    u64 ip_size = ip_len < MAX_IP_SIZE ? ip_len : MAX_IP_SIZE;
+    bpf_probe_read(grpcReq->peer_ip, ip_size, ip_ptr);
+    grpcReq->peer_ip_len = ip_len;
+    bpf_probe_read(&grpcReq->peer_port, sizeof(grpcReq->peer_port), (void *)(addr_ptr + tcp_addr_port_pos));
+}
+
+// func (t *http2Client) Write(s *Stream, hdr []byte, data []byte, opts *Options) error
+SEC("uprobe/http2Client_Write")
+int uprobe_http2Client_Write(struct pt_regs *ctx)
+{
+    u64 http2client_pos = 1;
+    u64 stream_pos = 2;
+    u64 hdr_ptr_pos = 3;
+    u64 data_len_pos = 7;
+    struct grpc_request_t *grpcReq = find_request_by_stream(get_argument(ctx, stream_pos));
+    if (grpcReq == NULL)
+    {
+        return 0;
+    }
+
+    if (read_peer_address && grpcReq->peer_ip_len == 0)
+    {
+        read_remote_address(get_argument(ctx, http2client_pos), grpcReq);
+    }
+    if (record_messages)
+    {
+        grpc_record_sent_message(&grpcReq->messages, get_argument(ctx, hdr_ptr_pos), (u64)get_argument(ctx, data_len_pos));
+    }
+    return 0;
+}
+
+// func (s *Stream) Read(p []byte) (n int, err error)
+SEC("uprobe/Stream_Read")
+int uprobe_Stream_Read(struct pt_regs *ctx)
+{
+    u64 stream_pos = 1;
+    u64 p_ptr_pos = 2;
+    u64 p_len_pos = 3;
+    struct grpc_request_t *grpcReq = find_request_by_stream(get_argument(ctx, stream_pos));
+    if (grpcReq == NULL)
+    {
+        return 0;
+    }
+
+    grpc_record_received_message(&grpcReq->messages, get_argument(ctx, p_ptr_pos), (u64)get_argument(ctx, p_len_pos));
+    return 0;
+}
@@ -57,6 +57,8 @@ type bpfProgramSpecs struct {
 	UprobeClientConnInvoke              *ebpf.ProgramSpec `ebpf:"uprobe_ClientConn_Invoke"`
 	UprobeClientConnInvokeReturns       *ebpf.ProgramSpec `ebpf:"uprobe_ClientConn_Invoke_Returns"`
 	UprobeHttp2ClientCreateHeaderFields *ebpf.ProgramSpec `ebpf:"uprobe_Http2Client_CreateHeaderFields"`
+	UprobeStreamRead                    *ebpf.ProgramSpec `ebpf:"uprobe_Stream_Read"`
+	UprobeHttp2ClientWrite              *ebpf.ProgramSpec `ebpf:"uprobe_http2Client_Write"`
 }
 
 // bpfMapSpecs contains maps before they are loaded into the kernel.
@@ -65,8 +67,11 @@ type bpfProgramSpecs struct {
 type bpfMapSpecs struct {
 	AllocMap            *ebpf.MapSpec `ebpf:"alloc_map"`
 	ContextToGrpcEvents *ebpf.MapSpec `ebpf:"context_to_grpc_events"`
+	DroppedRequests     *ebpf.MapSpec `ebpf:"dropped_requests"`
 	Events              *ebpf.MapSpec `ebpf:"events"`
+	GoroutineSpans      *ebpf.MapSpec `ebpf:"goroutine_spans"`
 	HeadersBuffMap      *ebpf.MapSpec `ebpf:"headers_buff_map"`
+	Newproc1Callers     *ebpf.MapSpec `ebpf:"newproc1_callers"`
 	SpansInProgress     *ebpf.MapSpec `ebpf:"spans_in_progress"`
 }
 
@@ -91,8 +96,11 @@ func (o *bpfObjects) Close() error {
 type bpfMaps struct {
 	AllocMap            *ebpf.Map `ebpf:"alloc_map"`
 	ContextToGrpcEvents *ebpf.Map `ebpf:"context_to_grpc_events"`
+	DroppedRequests     *ebpf.Map `ebpf:"dropped_requests"`
 	Events              *ebpf.Map `ebpf:"events"`
+	GoroutineSpans      *ebpf.Map `ebpf:"goroutine_spans"`
 	HeadersBuffMap      *ebpf.Map `ebpf:"headers_buff_map"`
+	Newproc1Callers     *ebpf.Map `ebpf:"newproc1_callers"`
 	SpansInProgress     *ebpf.Map `ebpf:"spans_in_progress"`
 }
 
@@ -100,8 +108,11 @@ func (m *bpfMaps) Close() error {
 	return _BpfClose(
 		m.AllocMap,
 		m.ContextToGrpcEvents,
+		m.DroppedRequests,
 		m.Events,
+		m.GoroutineSpans,
 		m.HeadersBuffMap,
+		m.Newproc1Callers,
 		m.SpansInProgress,
 	)
 }
@@ -113,6 +124,8 @@ type bpfPrograms struct {
 	UprobeClientConnInvoke              *ebpf.Program `ebpf:"uprobe_ClientConn_Invoke"`
 	UprobeClientConnInvokeReturns       *ebpf.Program `ebpf:"uprobe_ClientConn_Invoke_Returns"`
 	UprobeHttp2ClientCreateHeaderFields *ebpf.Program `ebpf:"uprobe_Http2Client_CreateHeaderFields"`
+	UprobeStreamRead                    *ebpf.Program `ebpf:"uprobe_Stream_Read"`
+	UprobeHttp2ClientWrite              *ebpf.Program `ebpf:"uprobe_http2Client_Write"`
 }
 
 func (p *bpfPrograms) Close() error {
@@ -120,6 +133,8 @@ func (p *bpfPrograms) Close() error {
 		p.UprobeClientConnInvoke,
 		p.UprobeClientConnInvokeReturns,
 		p.UprobeHttp2ClientCreateHeaderFields,
+		p.UprobeStreamRead,
+		p.UprobeHttp2ClientWrite,
 	)
 }
 
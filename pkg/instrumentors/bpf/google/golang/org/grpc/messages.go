@@ -0,0 +1,150 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/events"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/log"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/process"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+)
+
+const (
+	// MessageEventsEnvVar enables recording the messages sent and received
+	// as span events on gRPC client and server spans. It is disabled by
+	// default because of the volume of events on streaming RPCs.
+	MessageEventsEnvVar = "OTEL_GO_AUTO_GRPC_MESSAGE_EVENTS"
+
+	// maxMessages is the number of messages recorded per RPC, as
+	// MAX_MESSAGES in grpc_messages.h.
+	maxMessages = 4
+
+	messageTypeSent     = 1
+	messageTypeReceived = 2
+
+	messageEventName = "message"
+
+	// ClientWriteFuncName writes the messages of clients.
+	ClientWriteFuncName = "google.golang.org/grpc/internal/transport.(*http2Client).Write"
+	// ServerWriteFuncName writes the messages of servers.
+	ServerWriteFuncName = "google.golang.org/grpc/internal/transport.(*http2Server).Write"
+	// StreamReadFuncName reads the messages of clients and servers.
+	StreamReadFuncName = "google.golang.org/grpc/internal/transport.(*Stream).Read"
+)
+
+// GrpcMessage is a message sent or received by an RPC.
+type GrpcMessage struct {
+	Time       uint64
+	Size       uint32
+	Type       uint8
+	Compressed uint8
+	_          [2]byte
+}
+
+// GrpcMessages are the first messages sent and received by an RPC.
+type GrpcMessages struct {
+	_        uint64
+	Count    uint32
+	_        uint32
+	Messages [maxMessages]GrpcMessage
+}
+
+// MessageEventsEnabled returns whether MessageEventsEnvVar enables message
+// events.
+func MessageEventsEnabled() (bool, error) {
+	val, exists := os.LookupEnv(MessageEventsEnvVar)
+	if !exists {
+		return false, nil
+	}
+
+	enabled, err := strconv.ParseBool(val)
+	if err != nil {
+		return false, fmt.Errorf("unsupported %s value %q", MessageEventsEnvVar, val)
+	}
+
+	return enabled, nil
+}
+
+// MessageFuncNames returns the functions probes resolve in the target to
+// record messages, with writeFuncName the function writing them on the side
+// of the probe. It returns none unless message events are turned on.
+func MessageFuncNames(writeFuncName string) []string {
+	if enabled, err := MessageEventsEnabled(); err != nil || !enabled {
+		return nil
+	}
+
+	return []string{writeFuncName, StreamReadFuncName}
+}
+
+// RecordMessages reports whether messages are recorded for target, which
+// must have the functions of MessageFuncNames.
+func RecordMessages(target *process.TargetDetails, writeFuncName string) (bool, error) {
+	enabled, err := MessageEventsEnabled()
+	if err != nil || !enabled {
+		return false, err
+	}
+
+	if !target.HasFunctions(writeFuncName, StreamReadFuncName) {
+		log.Component(log.ComponentProbe).V(0).Info("gRPC message functions not found in target, disabling message events",
+			"functions", []string{writeFuncName, StreamReadFuncName})
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// MessageEvents converts the recorded messages to rpc message span events.
+// Message ids are the sequence of the message among the messages of the same
+// type. The uncompressed size is only known for uncompressed messages.
+func MessageEvents(m *GrpcMessages) []events.SpanEvent {
+	count := int(m.Count)
+	if count > maxMessages {
+		count = maxMessages
+	}
+
+	var spanEvents []events.SpanEvent
+	var sent, received int
+	for _, msg := range m.Messages[:count] {
+		var attrs []attribute.KeyValue
+		switch msg.Type {
+		case messageTypeSent:
+			sent++
+			attrs = append(attrs, semconv.MessageTypeSent, semconv.MessageIDKey.Int(sent))
+		case messageTypeReceived:
+			received++
+			attrs = append(attrs, semconv.MessageTypeReceived, semconv.MessageIDKey.Int(received))
+		default:
+			continue
+		}
+
+		attrs = append(attrs, semconv.MessageCompressedSizeKey.Int64(int64(msg.Size)))
+		if msg.Compressed == 0 {
+			attrs = append(attrs, semconv.MessageUncompressedSizeKey.Int64(int64(msg.Size)))
+		}
+
+		spanEvents = append(spanEvents, events.SpanEvent{
+			Name:       messageEventName,
+			Time:       int64(msg.Time),
+			Attributes: attrs,
+		})
+	}
+
+	return spanEvents
+}
@@ -0,0 +1,96 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc
+
+import (
+	"net"
+	"strconv"
+
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/network"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/log"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/process"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+)
+
+// peerFields are the struct fields read to record the address of the server
+// the balancer picked for a call. Their offsets are not tracked per version,
+// they are read from the debug info of the target.
+var peerFields = []process.StructField{
+	{Struct: "google.golang.org/grpc/internal/transport.http2Client", Field: "remoteAddr"},
+	{Struct: "net.TCPAddr", Field: "IP"},
+	{Struct: "net.TCPAddr", Field: "Port"},
+}
+
+// peerVarNames are the constants the offsets of peerFields are injected as.
+var peerVarNames = []string{"http2client_remote_addr_pos", "tcp_addr_ip_pos", "tcp_addr_port_pos"}
+
+// peerAddressConstants returns the constants enabling reading the address of
+// the server of calls, nil if the offsets could not be read from the debug
+// info of target. Spans then hold the address of the dial target.
+func (g *grpcInstrumentor) peerAddressConstants(target *process.TargetDetails) map[string]interface{} {
+	if !target.HasFunctions(ClientWriteFuncName) {
+		log.Probe(g.LibraryName()).V(0).Info("function writing messages not found in target, recording dial targets instead",
+			"function", ClientWriteFuncName)
+		return nil
+	}
+
+	offsets, err := target.DebugFieldOffsets(peerFields)
+	if err != nil {
+		log.Probe(g.LibraryName()).V(0).Info("could not read the offsets of server addresses from debug info, recording dial targets instead",
+			"error", err.Error())
+		return nil
+	}
+
+	consts := map[string]interface{}{"read_peer_address": true}
+	for i, o := range offsets {
+		consts[peerVarNames[i]] = o.Offset
+	}
+
+	return consts
+}
+
+// peerAttributes returns the attributes of the server of e. The address of
+// the server picked by the balancer is preferred to the dial target, whose
+// host is kept as net.peer.name if it is not an IP address.
+func peerAttributes(e *GrpcEvent, target string) []attribute.KeyValue {
+	addr, transport := targetAddress(target)
+	peer := peerAddress(e)
+	if peer == "" || transport != network.TransportTCP {
+		return network.PeerAttributes(addr, transport)
+	}
+
+	attrs := network.PeerAttributes(peer, transport)
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	if host != "" && net.ParseIP(host) == nil {
+		attrs = append(attrs, semconv.NetPeerNameKey.String(host))
+	}
+
+	return attrs
+}
+
+// peerAddress returns the host:port address of the server of e, empty if it
+// was not read.
+func peerAddress(e *GrpcEvent) string {
+	if (e.PeerIPLen != net.IPv4len && e.PeerIPLen != net.IPv6len) || e.PeerPort == 0 || e.PeerPort > 65535 {
+		return ""
+	}
+
+	ip := net.IP(e.PeerIP[:e.PeerIPLen])
+	return net.JoinHostPort(ip.String(), strconv.FormatUint(e.PeerPort, 10))
+}
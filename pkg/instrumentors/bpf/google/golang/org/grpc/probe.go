@@ -22,18 +22,17 @@ import (
 	"strings"
 
 	"github.com/cilium/ebpf"
-	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/bpffs"
-
 	"github.com/cilium/ebpf/link"
 	"github.com/cilium/ebpf/perf"
 	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/inject"
 	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/context"
 	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/events"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/network"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/registry"
 	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/log"
 	"go.opentelemetry.io/otel/attribute"
 	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
 	"go.opentelemetry.io/otel/trace"
-	"golang.org/x/sys/unix"
 )
 
 //go:generate go run github.com/cilium/ebpf/cmd/bpf2go -target bpfel -cc clang -cflags $CFLAGS bpf ./bpf/probe.bpf.c
@@ -45,20 +44,58 @@ type GrpcEvent struct {
 	Target            [50]byte
 	SpanContext       context.EbpfSpanContext
 	ParentSpanContext context.EbpfSpanContext
+	_                 [4]byte
+	Messages          GrpcMessages
+	PeerIP            [16]byte
+	PeerIPLen         uint64
+	PeerPort          uint64
+	Goroutine         uint64
 }
 
 type grpcInstrumentor struct {
+	libVersion        string
 	bpfObjects        *bpfObjects
 	uprobe            link.Link
 	returnProbs       []link.Link
 	writeHeadersProbe []link.Link
+	messageProbes     []link.Link
 	eventsReader      *perf.Reader
 }
 
+// clientOffsets are the gRPC struct fields read by the probe.
+var clientOffsets = []*inject.InjectStructField{
+	{
+		VarName:    "clientconn_target_ptr_pos",
+		StructName: "google.golang.org/grpc.ClientConn",
+		Field:      "target",
+	},
+	{
+		VarName:    "stream_ctx_pos",
+		StructName: "google.golang.org/grpc/internal/transport.Stream",
+		Field:      "ctx",
+	},
+}
+
 func New() *grpcInstrumentor {
 	return &grpcInstrumentor{}
 }
 
+// Probe describes the probe of this package.
+func Probe() registry.Probe {
+	i := New()
+	return registry.Probe{
+		ID:                i.LibraryName(),
+		Package:           "google.golang.org/grpc",
+		Functions:         i.FuncNames(),
+		OptionalFunctions: []string{ClientWriteFuncName, StreamReadFuncName},
+		Offsets: []registry.Offsets{
+			{Module: "google.golang.org/grpc", Fields: clientOffsets},
+		},
+		Signals:   []registry.Signal{registry.SignalTraces},
+		SchemaURL: semconv.SchemaURL,
+	}
+}
+
 func (g *grpcInstrumentor) LibraryName() string {
 	return "google.golang.org/grpc"
 }
@@ -68,39 +105,60 @@ func (g *grpcInstrumentor) FuncNames() []string {
 		"google.golang.org/grpc/internal/transport.(*http2Client).createHeaderFields"}
 }
 
+// OptionalFuncNames returns the functions reading server addresses and, if
+// turned on, recording messages.
+func (g *grpcInstrumentor) OptionalFuncNames() []string {
+	return append([]string{ClientWriteFuncName}, MessageFuncNames(ClientWriteFuncName)...)
+}
+
 func (g *grpcInstrumentor) Load(ctx *context.InstrumentorContext) error {
 	libVersion, exists := ctx.TargetDetails.Libraries[g.LibraryName()]
 	if !exists {
 		libVersion = ""
 	}
-	spec, err := ctx.Injector.Inject(loadBpf, g.LibraryName(), libVersion, []*inject.InjectStructField{
-		{
-			VarName:    "clientconn_target_ptr_pos",
-			StructName: "google.golang.org/grpc.ClientConn",
-			Field:      "target",
-		},
-	}, true)
+	g.libVersion = libVersion
+	messageEvents, err := RecordMessages(ctx.TargetDetails, ClientWriteFuncName)
+	if err != nil {
+		return err
+	}
+
+	spec, err := ctx.Injector.Inject(loadBpf, g.LibraryName(), libVersion, clientOffsets, true)
 
 	if err != nil {
 		return err
 	}
 
+	err = spec.RewriteConstants(map[string]interface{}{"record_messages": messageEvents})
+	if err != nil {
+		return err
+	}
+
+	peerConsts := g.peerAddressConstants(ctx.TargetDetails)
+	if peerConsts != nil {
+		err = spec.RewriteConstants(peerConsts)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = ctx.LimitRequests(spec, "context_to_grpc_events")
+	if err != nil {
+		return err
+	}
+
 	g.bpfObjects = &bpfObjects{}
-	err = spec.LoadAndAssign(g.bpfObjects, &ebpf.CollectionOptions{
-		Maps: ebpf.MapOptions{
-			PinPath: bpffs.BpfFsPath,
-		},
-	})
+	err = ctx.LoadAndAssign(g.LibraryName(), spec, g.bpfObjects)
 	if err != nil {
 		return err
 	}
+	ctx.TrackDroppedRequests(g.LibraryName(), g.bpfObjects.DroppedRequests)
 
 	offset, err := ctx.TargetDetails.GetFunctionOffset(g.FuncNames()[0])
 	if err != nil {
 		return err
 	}
 
-	up, err := ctx.Executable.Uprobe("", g.bpfObjects.UprobeClientConnInvoke, &link.UprobeOptions{
+	up, err := ctx.ExecutableFor(g.FuncNames()[0]).Uprobe("", g.bpfObjects.UprobeClientConnInvoke, &link.UprobeOptions{
 		Offset: offset,
 	})
 	if err != nil {
@@ -114,7 +172,7 @@ func (g *grpcInstrumentor) Load(ctx *context.InstrumentorContext) error {
 	}
 
 	for _, ret := range retOffsets {
-		retProbe, err := ctx.Executable.Uprobe("", g.bpfObjects.UprobeClientConnInvokeReturns, &link.UprobeOptions{
+		retProbe, err := ctx.ExecutableFor(g.FuncNames()[0]).Uprobe("", g.bpfObjects.UprobeClientConnInvokeReturns, &link.UprobeOptions{
 			Offset: ret,
 		})
 		if err != nil {
@@ -135,7 +193,7 @@ func (g *grpcInstrumentor) Load(ctx *context.InstrumentorContext) error {
 		return err
 	}
 	for _, whOffset := range whOffsets {
-		whProbe, err := ctx.Executable.Uprobe("", g.bpfObjects.UprobeHttp2ClientCreateHeaderFields, &link.UprobeOptions{
+		whProbe, err := ctx.ExecutableFor(g.FuncNames()[1]).Uprobe("", g.bpfObjects.UprobeHttp2ClientCreateHeaderFields, &link.UprobeOptions{
 			Offset: whOffset,
 		})
 		if err != nil {
@@ -145,11 +203,34 @@ func (g *grpcInstrumentor) Load(ctx *context.InstrumentorContext) error {
 		g.writeHeadersProbe = append(g.writeHeadersProbe, whProbe)
 	}
 
+	// Server addresses are read when the first message is written
+	messageProgs := make(map[string]*ebpf.Program)
+	if messageEvents || peerConsts != nil {
+		messageProgs[ClientWriteFuncName] = g.bpfObjects.UprobeHttp2ClientWrite
+	}
+	if messageEvents {
+		messageProgs[StreamReadFuncName] = g.bpfObjects.UprobeStreamRead
+	}
+	for funcName, prog := range messageProgs {
+		offset, err := ctx.TargetDetails.GetFunctionOffset(funcName)
+		if err != nil {
+			return err
+		}
+
+		probe, err := ctx.ExecutableFor(funcName).Uprobe("", prog, &link.UprobeOptions{
+			Offset: offset,
+		})
+		if err != nil {
+			return err
+		}
+		g.messageProbes = append(g.messageProbes, probe)
+	}
+
 	return nil
 }
 
 func (g *grpcInstrumentor) Run(eventsChan chan<- *events.Event) {
-	logger := log.Logger.WithName("grpc-instrumentor")
+	logger := log.Probe(g.LibraryName())
 	var event GrpcEvent
 	for {
 		record, err := g.eventsReader.Read()
@@ -157,7 +238,7 @@ func (g *grpcInstrumentor) Run(eventsChan chan<- *events.Event) {
 			if errors.Is(err, perf.ErrClosed) {
 				return
 			}
-			logger.Error(err, "error reading from perf reader")
+			log.Error(logger, log.ErrProbeRead, err, "error reading from perf reader")
 			continue
 		}
 
@@ -167,7 +248,7 @@ func (g *grpcInstrumentor) Run(eventsChan chan<- *events.Event) {
 		}
 
 		if err := binary.Read(bytes.NewBuffer(record.RawSample), binary.LittleEndian, &event); err != nil {
-			logger.Error(err, "error parsing perf event")
+			log.Error(logger, log.ErrEventDecode, err, "error parsing perf event")
 			continue
 		}
 
@@ -177,20 +258,15 @@ func (g *grpcInstrumentor) Run(eventsChan chan<- *events.Event) {
 
 // According to https://github.com/open-telemetry/opentelemetry-specification/blob/main/specification/trace/semantic_conventions/rpc.md
 func (g *grpcInstrumentor) convertEvent(e *GrpcEvent) *events.Event {
-	method := unix.ByteSliceToString(e.Method[:])
-	target := unix.ByteSliceToString(e.Target[:])
-	var attrs []attribute.KeyValue
-
-	// remove port
-	if parts := strings.Split(target, ":"); len(parts) > 1 {
-		target = parts[0]
-		attrs = append(attrs, semconv.NetPeerPortKey.String(parts[1]))
-	}
-
-	attrs = append(attrs, semconv.RPCSystemKey.String("grpc"),
+	var strs events.StringReader
+	method := strs.Read(e.Method[:])
+	target := strs.Read(e.Target[:])
+	attrs := []attribute.KeyValue{
+		semconv.RPCSystemKey.String("grpc"),
 		semconv.RPCServiceKey.String(method),
-		semconv.NetPeerIPKey.String(target),
-		semconv.NetPeerNameKey.String(target))
+	}
+	attrs = append(attrs, peerAttributes(e, target)...)
+	attrs = append(attrs, strs.Attributes()...)
 
 	sc := trace.NewSpanContext(trace.SpanContextConfig{
 		TraceID:    e.SpanContext.TraceID,
@@ -211,9 +287,10 @@ func (g *grpcInstrumentor) convertEvent(e *GrpcEvent) *events.Event {
 		pscPtr = nil
 	}
 
-	log.Logger.V(0).Info("got spancontext", "trace_id", e.SpanContext.TraceID.String(), "span_id", e.SpanContext.SpanID.String())
+	log.Probe(g.LibraryName()).V(0).Info("got spancontext", "trace_id", e.SpanContext.TraceID.String(), "span_id", e.SpanContext.SpanID.String())
 	return &events.Event{
 		Library:           g.LibraryName(),
+		LibraryVersion:    g.libVersion,
 		Name:              method,
 		Kind:              trace.SpanKindClient,
 		StartTime:         int64(e.StartTime),
@@ -221,11 +298,34 @@ func (g *grpcInstrumentor) convertEvent(e *GrpcEvent) *events.Event {
 		Attributes:        attrs,
 		SpanContext:       &sc,
 		ParentSpanContext: pscPtr,
+		SpanEvents:        MessageEvents(&e.Messages),
+		Goroutine:         e.Goroutine,
 	}
 }
 
+// targetAddress returns the address and transport of the server a client
+// dials target to, as parsed by grpc.Dial: [scheme://[authority]/]address,
+// where unix targets hold a socket path instead.
+func targetAddress(target string) (string, network.Transport) {
+	switch {
+	case strings.HasPrefix(target, "unix://"):
+		return strings.TrimPrefix(target, "unix://"), network.TransportUnix
+	case strings.HasPrefix(target, "unix:"):
+		return strings.TrimPrefix(target, "unix:"), network.TransportUnix
+	}
+
+	if scheme := strings.Index(target, "://"); scheme >= 0 {
+		target = target[scheme+len("://"):]
+		if slash := strings.Index(target, "/"); slash >= 0 {
+			target = target[slash+1:]
+		}
+	}
+
+	return target, network.TransportTCP
+}
+
 func (g *grpcInstrumentor) Close() {
-	log.Logger.V(0).Info("closing gRPC instrumentor")
+	log.Probe(g.LibraryName()).V(0).Info("closing gRPC instrumentor")
 	if g.eventsReader != nil {
 		g.eventsReader.Close()
 	}
@@ -242,6 +342,10 @@ func (g *grpcInstrumentor) Close() {
 		r.Close()
 	}
 
+	for _, r := range g.messageProbes {
+		r.Close()
+	}
+
 	if g.bpfObjects != nil {
 		g.bpfObjects.Close()
 	}
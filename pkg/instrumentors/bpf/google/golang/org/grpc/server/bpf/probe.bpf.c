@@ -15,6 +15,12 @@
 #include "arguments.h"
 #include "go_types.h"
 #include "span_context.h"
+#include "grpc_messages.h"
+#include "goroutines.h"
+#include "gc_pauses.h"
+#include "sched_latency.h"
+#include "mutex_waits.h"
+#include "requests.h"
 
 char __license[] SEC("license") = "Dual MIT/GPL";
 
@@ -32,6 +38,8 @@ struct grpc_request_t
     char method[MAX_SIZE];
     struct span_context sc;
     struct span_context psc;
+    struct grpc_messages_t messages;
+    u64 sched_latency;
 };
 
 struct
@@ -42,11 +50,14 @@ struct
     __uint(max_entries, MAX_CONCURRENT);
 } context_to_grpc_events SEC(".maps");
 
+// Parent span contexts propagated in the headers of a stream, only the
+// parent is stored as the whole request does not fit on the stack of
+// decodeHeader
 struct
 {
     __uint(type, BPF_MAP_TYPE_HASH);
     __type(key, u32);
-    __type(value, struct grpc_request_t);
+    __type(value, struct span_context);
     __uint(max_entries, MAX_CONCURRENT);
 } streamid_to_grpc_events SEC(".maps");
 
@@ -80,11 +91,11 @@ int uprobe_server_handleStream(struct pt_regs *ctx)
     // Get parent context if exists
     u32 stream_id = 0;
     bpf_probe_read(&stream_id, sizeof(stream_id), (void *)(stream_ptr + stream_id_pos));
-    void *grpcReq_ptr = bpf_map_lookup_elem(&streamid_to_grpc_events, &stream_id);
+    struct span_context *psc_ptr = bpf_map_lookup_elem(&streamid_to_grpc_events, &stream_id);
     struct grpc_request_t grpcReq = {};
-    if (grpcReq_ptr != NULL)
+    if (psc_ptr != NULL)
     {
-        bpf_probe_read(&grpcReq, sizeof(grpcReq), grpcReq_ptr);
+        grpcReq.psc = *psc_ptr;
         bpf_map_delete_elem(&streamid_to_grpc_events, &stream_id);
         copy_byte_arrays(grpcReq.psc.TraceID, grpcReq.sc.TraceID, TRACE_ID_SIZE);
         generate_random_bytes(grpcReq.sc.SpanID, SPAN_ID_SIZE);
@@ -109,8 +120,13 @@ int uprobe_server_handleStream(struct pt_regs *ctx)
     bpf_probe_read(&ctx_iface, sizeof(ctx_iface), (void *)(stream_ptr + stream_ctx_pos));
     void *ctx_instance = 0;
     bpf_probe_read(&ctx_instance, sizeof(ctx_instance), (void *)(ctx_iface + 8));
-    bpf_map_update_elem(&context_to_grpc_events, &ctx_instance, &grpcReq, 0);
+    if (start_request(&context_to_grpc_events, &ctx_instance, &grpcReq) != 0)
+    {
+        return 0;
+    }
     bpf_map_update_elem(&spans_in_progress, &ctx_instance, &grpcReq.sc, 0);
+    set_goroutine_span(ctx, &grpcReq.sc);
+    start_sched_latency(ctx);
     return 0;
 }
 
@@ -122,11 +138,11 @@ int uprobe_server_handleStream_ByRegisters(struct pt_regs *ctx)
     // Get parent context if exists
     u32 stream_id = 0;
     bpf_probe_read(&stream_id, sizeof(stream_id), (void *)(stream_ptr + stream_id_pos));
-    void *grpcReq_ptr = bpf_map_lookup_elem(&streamid_to_grpc_events, &stream_id);
+    struct span_context *psc_ptr = bpf_map_lookup_elem(&streamid_to_grpc_events, &stream_id);
     struct grpc_request_t grpcReq = {};
-    if (grpcReq_ptr != NULL)
+    if (psc_ptr != NULL)
     {
-        bpf_probe_read(&grpcReq, sizeof(grpcReq), grpcReq_ptr);
+        grpcReq.psc = *psc_ptr;
         bpf_map_delete_elem(&streamid_to_grpc_events, &stream_id);
         copy_byte_arrays(grpcReq.psc.TraceID, grpcReq.sc.TraceID, TRACE_ID_SIZE);
         generate_random_bytes(grpcReq.sc.SpanID, SPAN_ID_SIZE);
@@ -151,8 +167,13 @@ int uprobe_server_handleStream_ByRegisters(struct pt_regs *ctx)
     bpf_probe_read(&ctx_iface, sizeof(ctx_iface), (void *)(stream_ptr + stream_ctx_pos));
     void *ctx_instance = 0;
     bpf_probe_read(&ctx_instance, sizeof(ctx_instance), (void *)(ctx_iface + 8));
-    bpf_map_update_elem(&context_to_grpc_events, &ctx_instance, &grpcReq, 0);
+    if (start_request(&context_to_grpc_events, &ctx_instance, &grpcReq) != 0)
+    {
+        return 0;
+    }
     bpf_map_update_elem(&spans_in_progress, &ctx_instance, &grpcReq.sc, 0);
+    set_goroutine_span(ctx, &grpcReq.sc);
+    start_sched_latency(ctx);
     return 0;
 }
 
@@ -166,14 +187,21 @@ int uprobe_server_handleStream_Returns(struct pt_regs *ctx)
     void *ctx_instance = 0;
     bpf_probe_read(&ctx_instance, sizeof(ctx_instance), (void *)(ctx_iface + 8));
 
-    void *grpcReq_ptr = bpf_map_lookup_elem(&context_to_grpc_events, &ctx_instance);
+    void *grpcReq_ptr = end_request(&context_to_grpc_events, &ctx_instance);
+    if (grpcReq_ptr == NULL)
+    {
+        delete_goroutine_span(ctx);
+        return 0;
+    }
     struct grpc_request_t grpcReq = {};
     bpf_probe_read(&grpcReq, sizeof(grpcReq), grpcReq_ptr);
 
     grpcReq.end_time = bpf_ktime_get_boot_ns();
+    grpcReq.sched_latency = end_sched_latency(ctx);
     bpf_perf_event_output(ctx, &events, BPF_F_CURRENT_CPU, &grpcReq, sizeof(grpcReq));
     bpf_map_delete_elem(&context_to_grpc_events, &ctx_instance);
     bpf_map_delete_elem(&spans_in_progress, &ctx_instance);
+    delete_goroutine_span(ctx);
     return 0;
 }
 
@@ -208,12 +236,122 @@ int uprobe_decodeState_decodeHeader(struct pt_regs *ctx)
                 bpf_probe_read(&headers_frame, sizeof(headers_frame), frame_ptr);
                 u32 stream_id = 0;
                 bpf_probe_read(&stream_id, sizeof(stream_id), (void *)(headers_frame + frame_stream_id_pod));
-                struct grpc_request_t grpcReq = {};
-                w3c_string_to_span_context(val, &grpcReq.psc);
-                bpf_map_update_elem(&streamid_to_grpc_events, &stream_id, &grpcReq, 0);
+                struct span_context psc = {};
+                w3c_string_to_span_context(val, &psc);
+                bpf_map_update_elem(&streamid_to_grpc_events, &stream_id, &psc, 0);
             }
         }
     }
 
     return 0;
-}
\ No newline at end of file
+}
+static __always_inline struct grpc_request_t *find_request_by_stream(void *stream_ptr)
+{
+    void *ctx_iface = 0;
+    bpf_probe_read(&ctx_iface, sizeof(ctx_iface), (void *)(stream_ptr + stream_ctx_pos));
+    void *ctx_instance = 0;
+    bpf_probe_read(&ctx_instance, sizeof(ctx_instance), (void *)(ctx_iface + 8));
+    return bpf_map_lookup_elem(&context_to_grpc_events, &ctx_instance);
+}
+
+// func (t *http2Server) Write(s *Stream, hdr []byte, data []byte, opts *Options) error
+SEC("uprobe/http2Server_Write")
+int uprobe_http2Server_Write(struct pt_regs *ctx)
+{
+    u64 stream_pos = 2;
+    u64 hdr_ptr_pos = 3;
+    u64 data_len_pos = 7;
+    struct grpc_request_t *grpcReq = find_request_by_stream(get_argument(ctx, stream_pos));
+    if (grpcReq == NULL)
+    {
+        return 0;
+    }
+
+    grpc_record_sent_message(&grpcReq->messages, get_argument(ctx, hdr_ptr_pos), (u64)get_argument(ctx, data_len_pos));
+    return 0;
+}
+
+// func (s *Stream) Read(p []byte) (n int, err error)
+SEC("uprobe/Stream_Read")
+int uprobe_Stream_Read(struct pt_regs *ctx)
+{
+    u64 stream_pos = 1;
+    u64 p_ptr_pos = 2;
+    u64 p_len_pos = 3;
+    struct grpc_request_t *grpcReq = find_request_by_stream(get_argument(ctx, stream_pos));
+    if (grpcReq == NULL)
+    {
+        return 0;
+    }
+
+    grpc_record_received_message(&grpcReq->messages, get_argument(ctx, p_ptr_pos), (u64)get_argument(ctx, p_len_pos));
+    return 0;
+}
+
+// func newproc1(fn *funcval, callergp *g, callerpc uintptr) *g
+SEC("uprobe/runtime_newproc1")
+int uprobe_runtime_newproc1(struct pt_regs *ctx)
+{
+    return track_newproc1(ctx);
+}
+
+SEC("uprobe/runtime_newproc1")
+int uprobe_runtime_newproc1_Returns(struct pt_regs *ctx)
+{
+    return track_newproc1_returns(ctx);
+}
+
+// func goexit1()
+SEC("uprobe/runtime_goexit1")
+int uprobe_runtime_goexit1(struct pt_regs *ctx)
+{
+    return track_goexit1(ctx);
+}
+
+// func stopTheWorldWithSema()
+SEC("uprobe/runtime_stopTheWorldWithSema")
+int uprobe_runtime_stopTheWorldWithSema(struct pt_regs *ctx)
+{
+    return track_stop_the_world(ctx);
+}
+
+// func startTheWorldWithSema(emitTraceEvent bool) int64
+SEC("uprobe/runtime_startTheWorldWithSema")
+int uprobe_runtime_startTheWorldWithSema(struct pt_regs *ctx)
+{
+    return track_start_the_world(ctx);
+}
+
+// func ready(gp *g, traceskip int, next bool)
+SEC("uprobe/runtime_ready")
+int uprobe_runtime_ready(struct pt_regs *ctx)
+{
+    return track_runnable(ctx);
+}
+
+// func goschedImpl(gp *g)
+SEC("uprobe/runtime_goschedImpl")
+int uprobe_runtime_goschedImpl(struct pt_regs *ctx)
+{
+    return track_runnable(ctx);
+}
+
+// func execute(gp *g, inheritTime bool)
+SEC("uprobe/runtime_execute")
+int uprobe_runtime_execute(struct pt_regs *ctx)
+{
+    return track_execute(ctx);
+}
+
+// func (m *Mutex) lockSlow()
+SEC("uprobe/sync_Mutex_lockSlow")
+int uprobe_sync_Mutex_lockSlow(struct pt_regs *ctx)
+{
+    return track_mutex_wait(ctx);
+}
+
+SEC("uprobe/sync_Mutex_lockSlow")
+int uprobe_sync_Mutex_lockSlow_Returns(struct pt_regs *ctx)
+{
+    return track_mutex_acquired(ctx);
+}
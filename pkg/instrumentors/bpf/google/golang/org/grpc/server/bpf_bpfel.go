@@ -54,21 +54,42 @@ type bpfSpecs struct {
 //
 // It can be passed ebpf.CollectionSpec.Assign.
 type bpfProgramSpecs struct {
+	UprobeStreamRead                    *ebpf.ProgramSpec `ebpf:"uprobe_Stream_Read"`
 	UprobeDecodeStateDecodeHeader       *ebpf.ProgramSpec `ebpf:"uprobe_decodeState_decodeHeader"`
+	UprobeHttp2ServerWrite              *ebpf.ProgramSpec `ebpf:"uprobe_http2Server_Write"`
+	UprobeRuntimeExecute                *ebpf.ProgramSpec `ebpf:"uprobe_runtime_execute"`
+	UprobeRuntimeGoexit1                *ebpf.ProgramSpec `ebpf:"uprobe_runtime_goexit1"`
+	UprobeRuntimeGoschedImpl            *ebpf.ProgramSpec `ebpf:"uprobe_runtime_goschedImpl"`
+	UprobeRuntimeNewproc1               *ebpf.ProgramSpec `ebpf:"uprobe_runtime_newproc1"`
+	UprobeRuntimeNewproc1Returns        *ebpf.ProgramSpec `ebpf:"uprobe_runtime_newproc1_Returns"`
+	UprobeRuntimeReady                  *ebpf.ProgramSpec `ebpf:"uprobe_runtime_ready"`
+	UprobeRuntimeStartTheWorldWithSema  *ebpf.ProgramSpec `ebpf:"uprobe_runtime_startTheWorldWithSema"`
+	UprobeRuntimeStopTheWorldWithSema   *ebpf.ProgramSpec `ebpf:"uprobe_runtime_stopTheWorldWithSema"`
 	UprobeServerHandleStream            *ebpf.ProgramSpec `ebpf:"uprobe_server_handleStream"`
 	UprobeServerHandleStreamByRegisters *ebpf.ProgramSpec `ebpf:"uprobe_server_handleStream_ByRegisters"`
 	UprobeServerHandleStreamReturns     *ebpf.ProgramSpec `ebpf:"uprobe_server_handleStream_Returns"`
+	UprobeSyncMutexLockSlow             *ebpf.ProgramSpec `ebpf:"uprobe_sync_Mutex_lockSlow"`
+	UprobeSyncMutexLockSlowReturns      *ebpf.ProgramSpec `ebpf:"uprobe_sync_Mutex_lockSlow_Returns"`
 }
 
 // bpfMapSpecs contains maps before they are loaded into the kernel.
 //
 // It can be passed ebpf.CollectionSpec.Assign.
 type bpfMapSpecs struct {
-	AllocMap             *ebpf.MapSpec `ebpf:"alloc_map"`
-	ContextToGrpcEvents  *ebpf.MapSpec `ebpf:"context_to_grpc_events"`
-	Events               *ebpf.MapSpec `ebpf:"events"`
-	SpansInProgress      *ebpf.MapSpec `ebpf:"spans_in_progress"`
-	StreamidToGrpcEvents *ebpf.MapSpec `ebpf:"streamid_to_grpc_events"`
+	AllocMap               *ebpf.MapSpec `ebpf:"alloc_map"`
+	ContextToGrpcEvents    *ebpf.MapSpec `ebpf:"context_to_grpc_events"`
+	DroppedRequests        *ebpf.MapSpec `ebpf:"dropped_requests"`
+	Events                 *ebpf.MapSpec `ebpf:"events"`
+	GcPauseEvents          *ebpf.MapSpec `ebpf:"gc_pause_events"`
+	GoroutineRunnableSince *ebpf.MapSpec `ebpf:"goroutine_runnable_since"`
+	GoroutineSchedLatency  *ebpf.MapSpec `ebpf:"goroutine_sched_latency"`
+	GoroutineSpans         *ebpf.MapSpec `ebpf:"goroutine_spans"`
+	MutexWaitEvents        *ebpf.MapSpec `ebpf:"mutex_wait_events"`
+	MutexWaitStart         *ebpf.MapSpec `ebpf:"mutex_wait_start"`
+	Newproc1Callers        *ebpf.MapSpec `ebpf:"newproc1_callers"`
+	SpansInProgress        *ebpf.MapSpec `ebpf:"spans_in_progress"`
+	StreamidToGrpcEvents   *ebpf.MapSpec `ebpf:"streamid_to_grpc_events"`
+	StwStartTime           *ebpf.MapSpec `ebpf:"stw_start_time"`
 }
 
 // bpfObjects contains all objects after they have been loaded into the kernel.
@@ -90,20 +111,38 @@ func (o *bpfObjects) Close() error {
 //
 // It can be passed to loadBpfObjects or ebpf.CollectionSpec.LoadAndAssign.
 type bpfMaps struct {
-	AllocMap             *ebpf.Map `ebpf:"alloc_map"`
-	ContextToGrpcEvents  *ebpf.Map `ebpf:"context_to_grpc_events"`
-	Events               *ebpf.Map `ebpf:"events"`
-	SpansInProgress      *ebpf.Map `ebpf:"spans_in_progress"`
-	StreamidToGrpcEvents *ebpf.Map `ebpf:"streamid_to_grpc_events"`
+	AllocMap               *ebpf.Map `ebpf:"alloc_map"`
+	ContextToGrpcEvents    *ebpf.Map `ebpf:"context_to_grpc_events"`
+	DroppedRequests        *ebpf.Map `ebpf:"dropped_requests"`
+	Events                 *ebpf.Map `ebpf:"events"`
+	GcPauseEvents          *ebpf.Map `ebpf:"gc_pause_events"`
+	GoroutineRunnableSince *ebpf.Map `ebpf:"goroutine_runnable_since"`
+	GoroutineSchedLatency  *ebpf.Map `ebpf:"goroutine_sched_latency"`
+	GoroutineSpans         *ebpf.Map `ebpf:"goroutine_spans"`
+	MutexWaitEvents        *ebpf.Map `ebpf:"mutex_wait_events"`
+	MutexWaitStart         *ebpf.Map `ebpf:"mutex_wait_start"`
+	Newproc1Callers        *ebpf.Map `ebpf:"newproc1_callers"`
+	SpansInProgress        *ebpf.Map `ebpf:"spans_in_progress"`
+	StreamidToGrpcEvents   *ebpf.Map `ebpf:"streamid_to_grpc_events"`
+	StwStartTime           *ebpf.Map `ebpf:"stw_start_time"`
 }
 
 func (m *bpfMaps) Close() error {
 	return _BpfClose(
 		m.AllocMap,
 		m.ContextToGrpcEvents,
+		m.DroppedRequests,
 		m.Events,
+		m.GcPauseEvents,
+		m.GoroutineRunnableSince,
+		m.GoroutineSchedLatency,
+		m.GoroutineSpans,
+		m.MutexWaitEvents,
+		m.MutexWaitStart,
+		m.Newproc1Callers,
 		m.SpansInProgress,
 		m.StreamidToGrpcEvents,
+		m.StwStartTime,
 	)
 }
 
@@ -111,18 +150,42 @@ func (m *bpfMaps) Close() error {
 //
 // It can be passed to loadBpfObjects or ebpf.CollectionSpec.LoadAndAssign.
 type bpfPrograms struct {
+	UprobeStreamRead                    *ebpf.Program `ebpf:"uprobe_Stream_Read"`
 	UprobeDecodeStateDecodeHeader       *ebpf.Program `ebpf:"uprobe_decodeState_decodeHeader"`
+	UprobeHttp2ServerWrite              *ebpf.Program `ebpf:"uprobe_http2Server_Write"`
+	UprobeRuntimeExecute                *ebpf.Program `ebpf:"uprobe_runtime_execute"`
+	UprobeRuntimeGoexit1                *ebpf.Program `ebpf:"uprobe_runtime_goexit1"`
+	UprobeRuntimeGoschedImpl            *ebpf.Program `ebpf:"uprobe_runtime_goschedImpl"`
+	UprobeRuntimeNewproc1               *ebpf.Program `ebpf:"uprobe_runtime_newproc1"`
+	UprobeRuntimeNewproc1Returns        *ebpf.Program `ebpf:"uprobe_runtime_newproc1_Returns"`
+	UprobeRuntimeReady                  *ebpf.Program `ebpf:"uprobe_runtime_ready"`
+	UprobeRuntimeStartTheWorldWithSema  *ebpf.Program `ebpf:"uprobe_runtime_startTheWorldWithSema"`
+	UprobeRuntimeStopTheWorldWithSema   *ebpf.Program `ebpf:"uprobe_runtime_stopTheWorldWithSema"`
 	UprobeServerHandleStream            *ebpf.Program `ebpf:"uprobe_server_handleStream"`
 	UprobeServerHandleStreamByRegisters *ebpf.Program `ebpf:"uprobe_server_handleStream_ByRegisters"`
 	UprobeServerHandleStreamReturns     *ebpf.Program `ebpf:"uprobe_server_handleStream_Returns"`
+	UprobeSyncMutexLockSlow             *ebpf.Program `ebpf:"uprobe_sync_Mutex_lockSlow"`
+	UprobeSyncMutexLockSlowReturns      *ebpf.Program `ebpf:"uprobe_sync_Mutex_lockSlow_Returns"`
 }
 
 func (p *bpfPrograms) Close() error {
 	return _BpfClose(
+		p.UprobeStreamRead,
 		p.UprobeDecodeStateDecodeHeader,
+		p.UprobeHttp2ServerWrite,
+		p.UprobeRuntimeExecute,
+		p.UprobeRuntimeGoexit1,
+		p.UprobeRuntimeGoschedImpl,
+		p.UprobeRuntimeNewproc1,
+		p.UprobeRuntimeNewproc1Returns,
+		p.UprobeRuntimeReady,
+		p.UprobeRuntimeStartTheWorldWithSema,
+		p.UprobeRuntimeStopTheWorldWithSema,
 		p.UprobeServerHandleStream,
 		p.UprobeServerHandleStreamByRegisters,
 		p.UprobeServerHandleStreamReturns,
+		p.UprobeSyncMutexLockSlow,
+		p.UprobeSyncMutexLockSlowReturns,
 	)
 }
 
@@ -20,19 +20,22 @@ import (
 	"errors"
 	"os"
 
-	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/bpffs"
-
 	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/link"
 	"github.com/cilium/ebpf/perf"
 	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/inject"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/bpf/google/golang/org/grpc"
 	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/context"
 	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/events"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/gcpauses"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/goroutines"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/mutexwaits"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/registry"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/schedlatency"
 	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/log"
 	"go.opentelemetry.io/otel/attribute"
 	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
 	"go.opentelemetry.io/otel/trace"
-	"golang.org/x/sys/unix"
 )
 
 //go:generate go run github.com/cilium/ebpf/cmd/bpf2go -target bpfel -cc clang -cflags $CFLAGS bpf ./bpf/probe.bpf.c
@@ -43,20 +46,75 @@ type GrpcEvent struct {
 	Method            [100]byte
 	SpanContext       context.EbpfSpanContext
 	ParentSpanContext context.EbpfSpanContext
+	_                 [4]byte
+	Messages          grpc.GrpcMessages
+	SchedLatency      uint64
 }
 
 type grpcServerInstrumentor struct {
-	bpfObjects   *bpfObjects
-	uprobe       link.Link
-	returnProbs  []link.Link
-	headersProbe link.Link
-	eventsReader *perf.Reader
+	libVersion      string
+	bpfObjects      *bpfObjects
+	uprobe          link.Link
+	returnProbs     []link.Link
+	headersProbe    link.Link
+	messageProbes   []link.Link
+	goroutineProbes []link.Link
+	gcPauses        *gcpauses.Recorder
+	schedLatency    bool
+	latencyProbes   []link.Link
+	mutexWaits      *mutexwaits.Recorder
+	eventsReader    *perf.Reader
+}
+
+// serverOffsets are the gRPC and HTTP/2 struct fields read by the probe.
+var serverOffsets = []*inject.InjectStructField{
+	{
+		VarName:    "stream_method_ptr_pos",
+		StructName: "google.golang.org/grpc/internal/transport.Stream",
+		Field:      "method",
+	},
+	{
+		VarName:    "stream_id_pos",
+		StructName: "google.golang.org/grpc/internal/transport.Stream",
+		Field:      "id",
+	},
+	{
+		VarName:    "stream_ctx_pos",
+		StructName: "google.golang.org/grpc/internal/transport.Stream",
+		Field:      "ctx",
+	},
+	{
+		VarName:    "frame_fields_pos",
+		StructName: "golang.org/x/net/http2.MetaHeadersFrame",
+		Field:      "Fields",
+	},
+	{
+		VarName:    "frame_stream_id_pod",
+		StructName: "golang.org/x/net/http2.FrameHeader",
+		Field:      "StreamID",
+	},
 }
 
 func New() *grpcServerInstrumentor {
 	return &grpcServerInstrumentor{}
 }
 
+// Probe describes the probe of this package.
+func Probe() registry.Probe {
+	i := New()
+	return registry.Probe{
+		ID:                i.LibraryName(),
+		Package:           "google.golang.org/grpc",
+		Functions:         i.FuncNames(),
+		OptionalFunctions: featureFuncNames(),
+		Offsets: []registry.Offsets{
+			{Module: "google.golang.org/grpc", Fields: serverOffsets},
+		},
+		Signals:   []registry.Signal{registry.SignalTraces},
+		SchemaURL: semconv.SchemaURL,
+	}
+}
+
 func (g *grpcServerInstrumentor) LibraryName() string {
 	return "google.golang.org/grpc/server"
 }
@@ -66,53 +124,93 @@ func (g *grpcServerInstrumentor) FuncNames() []string {
 		"google.golang.org/grpc/internal/transport.(*decodeState).decodeHeader"}
 }
 
+// featureFuncNames returns the functions of all the opt-in features of the
+// probe.
+func featureFuncNames() []string {
+	funcs := []string{grpc.ServerWriteFuncName, grpc.StreamReadFuncName}
+	funcs = append(funcs, goroutines.FuncNames...)
+	funcs = append(funcs, gcpauses.FuncNames...)
+	funcs = append(funcs, schedlatency.FuncNames...)
+	return append(funcs, mutexwaits.FuncNames...)
+}
+
+// OptionalFuncNames returns the functions of the opt-in features turned on.
+func (g *grpcServerInstrumentor) OptionalFuncNames() []string {
+	funcs := grpc.MessageFuncNames(grpc.ServerWriteFuncName)
+	funcs = append(funcs, goroutines.OptionalFuncNames()...)
+	funcs = append(funcs, gcpauses.OptionalFuncNames()...)
+	funcs = append(funcs, schedlatency.OptionalFuncNames()...)
+	return append(funcs, mutexwaits.OptionalFuncNames()...)
+}
+
 func (g *grpcServerInstrumentor) Load(ctx *context.InstrumentorContext) error {
 	targetLib := "google.golang.org/grpc"
 	libVersion, exists := ctx.TargetDetails.Libraries[targetLib]
 	if !exists {
 		libVersion = ""
 	}
-	spec, err := ctx.Injector.Inject(loadBpf, "google.golang.org/grpc", libVersion, []*inject.InjectStructField{
-		{
-			VarName:    "stream_method_ptr_pos",
-			StructName: "google.golang.org/grpc/internal/transport.Stream",
-			Field:      "method",
-		},
-		{
-			VarName:    "stream_id_pos",
-			StructName: "google.golang.org/grpc/internal/transport.Stream",
-			Field:      "id",
-		},
-		{
-			VarName:    "stream_ctx_pos",
-			StructName: "google.golang.org/grpc/internal/transport.Stream",
-			Field:      "ctx",
-		},
-		{
-			VarName:    "frame_fields_pos",
-			StructName: "golang.org/x/net/http2.MetaHeadersFrame",
-			Field:      "Fields",
-		},
-		{
-			VarName:    "frame_stream_id_pod",
-			StructName: "golang.org/x/net/http2.FrameHeader",
-			Field:      "StreamID",
-		},
-	}, true)
+	g.libVersion = libVersion
+	messageEvents, err := grpc.RecordMessages(ctx.TargetDetails, grpc.ServerWriteFuncName)
+	if err != nil {
+		return err
+	}
+
+	goroutineDepth, err := goroutines.Depth(ctx.TargetDetails)
+	if err != nil {
+		return err
+	}
+
+	gcPauseEvents, err := gcpauses.Enabled(ctx.TargetDetails)
+	if err != nil {
+		return err
+	}
+
+	g.schedLatency, err = schedlatency.Enabled(ctx.TargetDetails)
+	if err != nil {
+		return err
+	}
+
+	mutexWaitThreshold, err := mutexwaits.Threshold(ctx.TargetDetails)
+	if err != nil {
+		return err
+	}
+
+	spec, err := ctx.Injector.Inject(loadBpf, "google.golang.org/grpc", libVersion, serverOffsets, true)
+
+	if err != nil {
+		return err
+	}
+
+	if goroutineDepth > 0 {
+		err = spec.RewriteConstants(goroutines.Constants(ctx.TargetDetails, goroutineDepth))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = spec.RewriteConstants(schedlatency.Constants(g.schedLatency))
+	if err != nil {
+		return err
+	}
+
+	if mutexWaitThreshold > 0 {
+		err = spec.RewriteConstants(mutexwaits.Constants(mutexWaitThreshold))
+		if err != nil {
+			return err
+		}
+	}
 
+	err = ctx.LimitRequests(spec, "context_to_grpc_events", "streamid_to_grpc_events")
 	if err != nil {
 		return err
 	}
 
 	g.bpfObjects = &bpfObjects{}
-	err = spec.LoadAndAssign(g.bpfObjects, &ebpf.CollectionOptions{
-		Maps: ebpf.MapOptions{
-			PinPath: bpffs.BpfFsPath,
-		},
-	})
+	err = ctx.LoadAndAssign(g.LibraryName(), spec, g.bpfObjects)
 	if err != nil {
 		return err
 	}
+	ctx.TrackDroppedRequests(g.LibraryName(), g.bpfObjects.DroppedRequests)
 
 	offset, err := ctx.TargetDetails.GetFunctionOffset(g.FuncNames()[0])
 	if err != nil {
@@ -126,7 +224,7 @@ func (g *grpcServerInstrumentor) Load(ctx *context.InstrumentorContext) error {
 		uprobeObj = g.bpfObjects.UprobeServerHandleStream
 	}
 
-	up, err := ctx.Executable.Uprobe("", uprobeObj, &link.UprobeOptions{
+	up, err := ctx.ExecutableFor(g.FuncNames()[0]).Uprobe("", uprobeObj, &link.UprobeOptions{
 		Offset: offset,
 	})
 	if err != nil {
@@ -140,7 +238,7 @@ func (g *grpcServerInstrumentor) Load(ctx *context.InstrumentorContext) error {
 	}
 
 	for _, ret := range retOffsets {
-		retProbe, err := ctx.Executable.Uprobe("", g.bpfObjects.UprobeServerHandleStreamReturns, &link.UprobeOptions{
+		retProbe, err := ctx.ExecutableFor(g.FuncNames()[0]).Uprobe("", g.bpfObjects.UprobeServerHandleStreamReturns, &link.UprobeOptions{
 			Offset: ret,
 		})
 		if err != nil {
@@ -153,7 +251,7 @@ func (g *grpcServerInstrumentor) Load(ctx *context.InstrumentorContext) error {
 	if err != nil {
 		return err
 	}
-	hProbe, err := ctx.Executable.Uprobe("", g.bpfObjects.UprobeDecodeStateDecodeHeader, &link.UprobeOptions{
+	hProbe, err := ctx.ExecutableFor(g.FuncNames()[1]).Uprobe("", g.bpfObjects.UprobeDecodeStateDecodeHeader, &link.UprobeOptions{
 		Offset: headerOffset,
 	})
 	if err != nil {
@@ -161,6 +259,71 @@ func (g *grpcServerInstrumentor) Load(ctx *context.InstrumentorContext) error {
 	}
 	g.headersProbe = hProbe
 
+	if messageEvents {
+		messageProgs := map[string]*ebpf.Program{
+			grpc.ServerWriteFuncName: g.bpfObjects.UprobeHttp2ServerWrite,
+			grpc.StreamReadFuncName:  g.bpfObjects.UprobeStreamRead,
+		}
+		for funcName, prog := range messageProgs {
+			offset, err := ctx.TargetDetails.GetFunctionOffset(funcName)
+			if err != nil {
+				return err
+			}
+
+			probe, err := ctx.ExecutableFor(funcName).Uprobe("", prog, &link.UprobeOptions{
+				Offset: offset,
+			})
+			if err != nil {
+				return err
+			}
+			g.messageProbes = append(g.messageProbes, probe)
+		}
+	}
+
+	if goroutineDepth > 0 {
+		g.goroutineProbes, err = goroutines.Attach(ctx, goroutines.Programs{
+			Newproc1:        g.bpfObjects.UprobeRuntimeNewproc1,
+			Newproc1Returns: g.bpfObjects.UprobeRuntimeNewproc1Returns,
+			Goexit1:         g.bpfObjects.UprobeRuntimeGoexit1,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if gcPauseEvents {
+		g.gcPauses, err = gcpauses.Attach(ctx, gcpauses.Programs{
+			StopTheWorld:  g.bpfObjects.UprobeRuntimeStopTheWorldWithSema,
+			StartTheWorld: g.bpfObjects.UprobeRuntimeStartTheWorldWithSema,
+			Events:        g.bpfObjects.GcPauseEvents,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if g.schedLatency {
+		g.latencyProbes, err = schedlatency.Attach(ctx, schedlatency.Programs{
+			Ready:   g.bpfObjects.UprobeRuntimeReady,
+			Gosched: g.bpfObjects.UprobeRuntimeGoschedImpl,
+			Execute: g.bpfObjects.UprobeRuntimeExecute,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if mutexWaitThreshold > 0 {
+		g.mutexWaits, err = mutexwaits.Attach(ctx, mutexwaits.Programs{
+			LockSlow:        g.bpfObjects.UprobeSyncMutexLockSlow,
+			LockSlowReturns: g.bpfObjects.UprobeSyncMutexLockSlowReturns,
+			Events:          g.bpfObjects.MutexWaitEvents,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
 	rd, err := perf.NewReader(g.bpfObjects.Events, os.Getpagesize())
 	if err != nil {
 		return err
@@ -171,7 +334,7 @@ func (g *grpcServerInstrumentor) Load(ctx *context.InstrumentorContext) error {
 }
 
 func (g *grpcServerInstrumentor) Run(eventsChan chan<- *events.Event) {
-	logger := log.Logger.WithName("grpc-server-instrumentor")
+	logger := log.Probe(g.LibraryName())
 	var event GrpcEvent
 	for {
 		record, err := g.eventsReader.Read()
@@ -179,7 +342,7 @@ func (g *grpcServerInstrumentor) Run(eventsChan chan<- *events.Event) {
 			if errors.Is(err, perf.ErrClosed) {
 				return
 			}
-			logger.Error(err, "error reading from perf reader")
+			log.Error(logger, log.ErrProbeRead, err, "error reading from perf reader")
 			continue
 		}
 
@@ -189,7 +352,7 @@ func (g *grpcServerInstrumentor) Run(eventsChan chan<- *events.Event) {
 		}
 
 		if err := binary.Read(bytes.NewBuffer(record.RawSample), binary.LittleEndian, &event); err != nil {
-			logger.Error(err, "error parsing perf event")
+			log.Error(logger, log.ErrEventDecode, err, "error parsing perf event")
 			continue
 		}
 
@@ -198,7 +361,8 @@ func (g *grpcServerInstrumentor) Run(eventsChan chan<- *events.Event) {
 }
 
 func (g *grpcServerInstrumentor) convertEvent(e *GrpcEvent) *events.Event {
-	method := unix.ByteSliceToString(e.Method[:])
+	var strs events.StringReader
+	method := strs.Read(e.Method[:])
 
 	sc := trace.NewSpanContext(trace.SpanContextConfig{
 		TraceID:    e.SpanContext.TraceID,
@@ -219,23 +383,31 @@ func (g *grpcServerInstrumentor) convertEvent(e *GrpcEvent) *events.Event {
 		pscPtr = nil
 	}
 
+	attrs := []attribute.KeyValue{
+		semconv.RPCSystemKey.String("grpc"),
+		semconv.RPCServiceKey.String(method),
+	}
+	if g.schedLatency {
+		attrs = append(attrs, schedlatency.Attribute(e.SchedLatency))
+	}
+	attrs = append(attrs, strs.Attributes()...)
+
 	return &events.Event{
-		Library:   g.LibraryName(),
-		Name:      method,
-		Kind:      trace.SpanKindServer,
-		StartTime: int64(e.StartTime),
-		EndTime:   int64(e.EndTime),
-		Attributes: []attribute.KeyValue{
-			semconv.RPCSystemKey.String("grpc"),
-			semconv.RPCServiceKey.String(method),
-		},
+		Library:           g.LibraryName(),
+		LibraryVersion:    g.libVersion,
+		Name:              method,
+		Kind:              trace.SpanKindServer,
+		StartTime:         int64(e.StartTime),
+		EndTime:           int64(e.EndTime),
+		Attributes:        attrs,
 		ParentSpanContext: pscPtr,
 		SpanContext:       &sc,
+		SpanEvents:        grpc.MessageEvents(&e.Messages),
 	}
 }
 
 func (g *grpcServerInstrumentor) Close() {
-	log.Logger.V(0).Info("closing gRPC server instrumentor")
+	log.Probe(g.LibraryName()).V(0).Info("closing gRPC server instrumentor")
 	if g.eventsReader != nil {
 		g.eventsReader.Close()
 	}
@@ -252,6 +424,26 @@ func (g *grpcServerInstrumentor) Close() {
 		g.headersProbe.Close()
 	}
 
+	for _, r := range g.messageProbes {
+		r.Close()
+	}
+
+	for _, r := range g.goroutineProbes {
+		r.Close()
+	}
+
+	if g.gcPauses != nil {
+		g.gcPauses.Close()
+	}
+
+	for _, r := range g.latencyProbes {
+		r.Close()
+	}
+
+	if g.mutexWaits != nil {
+		g.mutexWaits.Close()
+	}
+
 	if g.bpfObjects != nil {
 		g.bpfObjects.Close()
 	}
@@ -15,11 +15,31 @@
 #include "arguments.h"
 #include "span_context.h"
 #include "go_context.h"
+#include "goroutines.h"
+#include "gc_pauses.h"
+#include "sched_latency.h"
+#include "mutex_waits.h"
+#include "requests.h"
 
 char __license[] SEC("license") = "Dual MIT/GPL";
 
 #define MAX_SIZE 100
 #define MAX_CONCURRENT 50
+#define STATUS_NOT_FOUND 404
+#define SPAN_END_HANDLER_RETURN 0
+#define SPAN_END_RESPONSE_FLUSH 1
+#define MAX_HEADER_BUCKETS 8
+#define BUCKET_SLOTS 8
+#define MIN_TOP_HASH 5
+#define HEADER_KEY_SIZE 16
+#define MAX_ERROR_BODY_SIZE 128
+#define MIN_ERROR_STATUS 500
+
+// Layout of the buckets of a Go map[string][]string: 8 top hashes, 8 keys
+// then 8 values, followed by the overflow bucket pointer.
+#define HEADER_BUCKET_KEYS_POS 8
+#define HEADER_BUCKET_VALUES_POS 136
+#define HEADER_BUCKET_SIZE 336
 
 struct http_request_t
 {
@@ -28,8 +48,28 @@ struct http_request_t
     char method[MAX_SIZE];
     char path[MAX_SIZE];
     struct span_context sc;
+    u64 status_code;
+    u64 is_tls;
+    char host[MAX_SIZE];
+    char remote_addr[MAX_SIZE];
+    char forwarded_for[MAX_SIZE];
+    char forwarded[MAX_SIZE];
+    u64 proto_major;
+    u64 sched_latency;
+    char error_body[MAX_ERROR_BODY_SIZE];
+    // Goroutine serving the request, to limit the spans per goroutine
+    u64 goroutine;
 };
 
+// Requests are built in a per CPU buffer, as they do not fit on the stack.
+struct
+{
+    __uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+    __type(key, s32);
+    __type(value, struct http_request_t);
+    __uint(max_entries, 1);
+} http_request_buff_map SEC(".maps");
+
 struct
 {
     __uint(type, BPF_MAP_TYPE_HASH);
@@ -38,6 +78,34 @@ struct
     __uint(max_entries, MAX_CONCURRENT);
 } context_to_http_events SEC(".maps");
 
+// Requests whose handler returned, waiting for the response to be flushed.
+// Keyed by goroutine since the request is no longer available at that point.
+struct
+{
+    __uint(type, BPF_MAP_TYPE_LRU_HASH);
+    __type(key, void *);
+    __type(value, struct http_request_t);
+    __uint(max_entries, MAX_CONCURRENT);
+} goroutine_to_pending_http_events SEC(".maps");
+
+// Request context of the outermost handler served by a goroutine, and the
+// number of ServeHTTP calls in progress on it, nested muxes included.
+struct goroutine_http_context_t
+{
+    void *ctx_iface;
+    u64 depth;
+};
+
+// Request contexts of the handlers being served, keyed by goroutine, to find
+// the request a response is written for.
+struct
+{
+    __uint(type, BPF_MAP_TYPE_LRU_HASH);
+    __type(key, void *);
+    __type(value, struct goroutine_http_context_t);
+    __uint(max_entries, MAX_CONCURRENT);
+} goroutine_to_http_context SEC(".maps");
+
 struct
 {
     __uint(type, BPF_MAP_TYPE_PERF_EVENT_ARRAY);
@@ -48,6 +116,175 @@ volatile const u64 method_ptr_pos;
 volatile const u64 url_ptr_pos;
 volatile const u64 path_ptr_pos;
 volatile const u64 ctx_ptr_pos;
+volatile const u64 host_ptr_pos;
+volatile const u64 tls_ptr_pos;
+volatile const u64 remote_addr_ptr_pos;
+volatile const u64 header_ptr_pos;
+volatile const u64 hmap_b_pos;
+volatile const u64 hmap_buckets_pos;
+volatile const u64 proto_major_pos;
+volatile const bool read_proto_major;
+volatile const bool read_host;
+volatile const bool read_headers;
+volatile const u64 max_url_size;
+volatile const u64 max_header_value_size;
+volatile const u64 max_error_body_size;
+
+char forwarded_for_key[HEADER_KEY_SIZE] = "X-Forwarded-For";
+char forwarded_key[HEADER_KEY_SIZE] = "Forwarded";
+volatile const u64 span_end_mode;
+
+static __always_inline int emit_pending_http_event(struct pt_regs *ctx)
+{
+    void *goroutine = current_goroutine(ctx);
+    struct http_request_t *httpReq = bpf_map_lookup_elem(&goroutine_to_pending_http_events, &goroutine);
+    if (httpReq == NULL)
+    {
+        return 0;
+    }
+
+    httpReq->end_time = bpf_ktime_get_boot_ns();
+    bpf_perf_event_output(ctx, &events, BPF_F_CURRENT_CPU, httpReq, sizeof(*httpReq));
+    bpf_map_delete_elem(&goroutine_to_pending_http_events, &goroutine);
+    return 0;
+}
+
+// Returns the request served by the current goroutine, tracked only when
+// error bodies are read.
+static __always_inline struct http_request_t *goroutine_http_request(struct pt_regs *ctx)
+{
+    void *goroutine = current_goroutine(ctx);
+    struct goroutine_http_context_t *http_ctx = bpf_map_lookup_elem(&goroutine_to_http_context, &goroutine);
+    if (http_ctx == NULL)
+    {
+        return NULL;
+    }
+
+    void *key = http_ctx->ctx_iface;
+    return bpf_map_lookup_elem(&context_to_http_events, &key);
+}
+
+// Tracks the request context served by the current goroutine. Nested muxes
+// keep the request of the outermost one, an entry left by a goroutine that
+// did not return from ServeHTTP, such as on panic, is replaced.
+static __always_inline void enter_goroutine_http_context(struct pt_regs *ctx, void *ctx_iface)
+{
+    void *goroutine = current_goroutine(ctx);
+    struct goroutine_http_context_t *outer = bpf_map_lookup_elem(&goroutine_to_http_context, &goroutine);
+    if (outer != NULL)
+    {
+        void *key = outer->ctx_iface;
+        if (bpf_map_lookup_elem(&context_to_http_events, &key) != NULL)
+        {
+            outer->depth += 1;
+            return;
+        }
+    }
+
+    struct goroutine_http_context_t http_ctx = {.ctx_iface = ctx_iface, .depth = 1};
+    bpf_map_update_elem(&goroutine_to_http_context, &goroutine, &http_ctx, 0);
+}
+
+// Stops tracking the request context of the current goroutine once its
+// outermost ServeHTTP call returns.
+static __always_inline void exit_goroutine_http_context(struct pt_regs *ctx)
+{
+    void *goroutine = current_goroutine(ctx);
+    struct goroutine_http_context_t *http_ctx = bpf_map_lookup_elem(&goroutine_to_http_context, &goroutine);
+    if (http_ctx == NULL)
+    {
+        return;
+    }
+
+    http_ctx->depth -= 1;
+    if (http_ctx->depth == 0)
+    {
+        bpf_map_delete_elem(&goroutine_to_http_context, &goroutine);
+    }
+}
+
+static __always_inline void read_go_string(void *str_ptr, char *buf, u64 buf_size)
+{
+    void *ptr = 0;
+    bpf_probe_read(&ptr, sizeof(ptr), str_ptr);
+    u64 len = 0;
+    bpf_probe_read(&len, sizeof(len), str_ptr + 8);
+    u64 size = buf_size < len ? buf_size : len;
+    bpf_probe_read(buf, size, ptr);
+}
+
+// Reads the first value of the forwarding headers from header_map, the
+// map[string][]string of Request.Header. Only the first MAX_HEADER_BUCKETS
+// buckets are searched and overflow buckets are not followed, which covers
+// requests with up to MAX_HEADER_BUCKETS * BUCKET_SLOTS headers.
+static __always_inline void read_forwarding_headers(void *header_map, struct http_request_t *httpReq)
+{
+    if (header_map == NULL)
+    {
+        return;
+    }
+
+    u8 b = 0;
+    bpf_probe_read(&b, sizeof(b), header_map + hmap_b_pos);
+    void *buckets = 0;
+    bpf_probe_read(&buckets, sizeof(buckets), header_map + hmap_buckets_pos);
+    u64 buckets_count = b < 4 ? 1 << b : MAX_HEADER_BUCKETS;
+
+    for (u64 i = 0; i < MAX_HEADER_BUCKETS; i++)
+    {
+        if (i >= buckets_count)
+        {
+            break;
+        }
+
+        void *bucket = buckets + i * HEADER_BUCKET_SIZE;
+        for (u64 j = 0; j < BUCKET_SLOTS; j++)
+        {
+            u8 top_hash = 0;
+            bpf_probe_read(&top_hash, sizeof(top_hash), bucket + j);
+            if (top_hash < MIN_TOP_HASH)
+            {
+                continue;
+            }
+
+            void *key_ptr = bucket + HEADER_BUCKET_KEYS_POS + j * 16;
+            u64 key_len = 0;
+            bpf_probe_read(&key_len, sizeof(key_len), key_ptr + 8);
+
+            char *dst = NULL;
+            char *expected = NULL;
+            if (key_len == sizeof("X-Forwarded-For") - 1)
+            {
+                dst = httpReq->forwarded_for;
+                expected = forwarded_for_key;
+            }
+            else if (key_len == sizeof("Forwarded") - 1)
+            {
+                dst = httpReq->forwarded;
+                expected = forwarded_key;
+            }
+            else
+            {
+                continue;
+            }
+
+            char key[HEADER_KEY_SIZE] = {};
+            read_go_string(key_ptr, key, HEADER_KEY_SIZE);
+            if (!bpf_memcmp(key, expected, HEADER_KEY_SIZE))
+            {
+                continue;
+            }
+
+            // Read the first string of the []string value
+            void *values = 0;
+            bpf_probe_read(&values, sizeof(values), bucket + HEADER_BUCKET_VALUES_POS + j * 24);
+            // Read the volatile limit once so the verifier sees the bounded value
+            u64 value_size_limit = max_header_value_size;
+            u64 value_size = value_size_limit < MAX_SIZE ? value_size_limit : MAX_SIZE;
+            read_go_string(values, dst, value_size);
+        }
+    }
+}
 
 // This instrumentation attaches uprobe to the following function:
 // func (mux *ServeMux) ServeHTTP(w ResponseWriter, r *Request)
@@ -55,8 +292,14 @@ SEC("uprobe/ServerMux_ServeHTTP")
 int uprobe_ServerMux_ServeHTTP(struct pt_regs *ctx)
 {
     u64 request_pos = 4;
-    struct http_request_t httpReq = {};
-    httpReq.start_time = bpf_ktime_get_boot_ns();
+    s32 index = 0;
+    struct http_request_t *httpReq = bpf_map_lookup_elem(&http_request_buff_map, &index);
+    if (httpReq == NULL)
+    {
+        return 0;
+    }
+    __builtin_memset(httpReq, 0, sizeof(*httpReq));
+    httpReq->start_time = bpf_ktime_get_boot_ns();
 
     // Get request struct
     void *req_ptr = get_argument(ctx, request_pos);
@@ -66,9 +309,9 @@ int uprobe_ServerMux_ServeHTTP(struct pt_regs *ctx)
     bpf_probe_read(&method_ptr, sizeof(method_ptr), (void *)(req_ptr + method_ptr_pos));
     u64 method_len = 0;
     bpf_probe_read(&method_len, sizeof(method_len), (void *)(req_ptr + (method_ptr_pos + 8)));
-    u64 method_size = sizeof(httpReq.method);
+    u64 method_size = sizeof(httpReq->method);
     method_size = method_size < method_len ? method_size : method_len;
-    bpf_probe_read(&httpReq.method, method_size, method_ptr);
+    bpf_probe_read(&httpReq->method, method_size, method_ptr);
 
     // get path from Request.URL
     void *url_ptr = 0;
@@ -77,18 +320,69 @@ int uprobe_ServerMux_ServeHTTP(struct pt_regs *ctx)
     bpf_probe_read(&path_ptr, sizeof(path_ptr), (void *)(url_ptr + path_ptr_pos));
     u64 path_len = 0;
     bpf_probe_read(&path_len, sizeof(path_len), (void *)(url_ptr + (path_ptr_pos + 8)));
-    u64 path_size = sizeof(httpReq.path);
+    // Read the volatile limit once so the verifier sees the bounded value
+    u64 url_size_limit = max_url_size;
+    u64 path_size = url_size_limit < sizeof(httpReq->path) ? url_size_limit : sizeof(httpReq->path);
     path_size = path_size < path_len ? path_size : path_len;
-    bpf_probe_read(&httpReq.path, path_size, path_ptr);
+    bpf_probe_read(&httpReq->path, path_size, path_ptr);
+
+    if (read_host)
+    {
+        // Get host from Request.Host, holding the port unless it is the default one
+        void *host_ptr = 0;
+        bpf_probe_read(&host_ptr, sizeof(host_ptr), (void *)(req_ptr + host_ptr_pos));
+        u64 host_len = 0;
+        bpf_probe_read(&host_len, sizeof(host_len), (void *)(req_ptr + (host_ptr_pos + 8)));
+        u64 host_size = sizeof(httpReq->host);
+        host_size = host_size < host_len ? host_size : host_len;
+        bpf_probe_read(&httpReq->host, host_size, host_ptr);
+
+        // Request.TLS is only set when the connection is served through crypto/tls
+        void *tls_ptr = 0;
+        bpf_probe_read(&tls_ptr, sizeof(tls_ptr), (void *)(req_ptr + tls_ptr_pos));
+        httpReq->is_tls = tls_ptr != 0;
+    }
+
+    // Get the protocol major version, 3 for HTTP/3 requests served by quic-go
+    if (read_proto_major)
+    {
+        bpf_probe_read(&httpReq->proto_major, sizeof(httpReq->proto_major), (void *)(req_ptr + proto_major_pos));
+    }
+
+    // Get the peer address from Request.RemoteAddr and the forwarding headers
+    // from Request.Header
+    read_go_string(req_ptr + remote_addr_ptr_pos, httpReq->remote_addr, MAX_SIZE);
+    if (read_headers)
+    {
+        void *header_map = 0;
+        bpf_probe_read(&header_map, sizeof(header_map), (void *)(req_ptr + header_ptr_pos));
+        read_forwarding_headers(header_map, httpReq);
+    }
 
     // Get Request.ctx
     void *ctx_iface = 0;
     bpf_probe_read(&ctx_iface, sizeof(ctx_iface), (void *)(req_ptr + ctx_ptr_pos + 8));
 
+    if (is_registers_abi)
+    {
+        httpReq->goroutine = (u64)current_goroutine(ctx);
+    }
+
     // Write event
-    httpReq.sc = generate_span_context();
-    bpf_map_update_elem(&context_to_http_events, &ctx_iface, &httpReq, 0);
-    long res = bpf_map_update_elem(&spans_in_progress, &ctx_iface, &httpReq.sc, 0);
+    httpReq->sc = generate_span_context();
+    if (max_error_body_size > 0)
+    {
+        // Tracked before the request, which may be dropped, so that every
+        // return is matched
+        enter_goroutine_http_context(ctx, ctx_iface);
+    }
+    if (start_request(&context_to_http_events, &ctx_iface, httpReq) != 0)
+    {
+        return 0;
+    }
+    long res = bpf_map_update_elem(&spans_in_progress, &ctx_iface, &httpReq->sc, 0);
+    set_goroutine_span(ctx, &httpReq->sc);
+    start_sched_latency(ctx);
     return 0;
 }
 
@@ -99,13 +393,186 @@ int uprobe_ServerMux_ServeHTTP_Returns(struct pt_regs *ctx)
     void *req_ptr = get_argument(ctx, request_pos);
     void *ctx_iface = 0;
     bpf_probe_read(&ctx_iface, sizeof(ctx_iface), (void *)(req_ptr + ctx_ptr_pos + 8));
+    if (max_error_body_size > 0)
+    {
+        exit_goroutine_http_context(ctx);
+    }
 
-    void *httpReq_ptr = bpf_map_lookup_elem(&context_to_http_events, &ctx_iface);
-    struct http_request_t httpReq = {};
-    bpf_probe_read(&httpReq, sizeof(httpReq), httpReq_ptr);
-    httpReq.end_time = bpf_ktime_get_boot_ns();
-    bpf_perf_event_output(ctx, &events, BPF_F_CURRENT_CPU, &httpReq, sizeof(httpReq));
+    struct http_request_t *httpReq = end_request(&context_to_http_events, &ctx_iface);
+    if (httpReq == NULL)
+    {
+        delete_goroutine_span(ctx);
+        return 0;
+    }
+
+    httpReq->end_time = bpf_ktime_get_boot_ns();
+    httpReq->sched_latency = end_sched_latency(ctx);
+    // HTTP/2 requests are not served by conn.serve, their spans end on
+    // handler return
+    if (span_end_mode == SPAN_END_RESPONSE_FLUSH && is_registers_abi && read_proto_major && httpReq->proto_major == 1)
+    {
+        void *goroutine = current_goroutine(ctx);
+        bpf_map_update_elem(&goroutine_to_pending_http_events, &goroutine, httpReq, 0);
+    }
+    else
+    {
+        bpf_perf_event_output(ctx, &events, BPF_F_CURRENT_CPU, httpReq, sizeof(*httpReq));
+    }
     bpf_map_delete_elem(&context_to_http_events, &ctx_iface);
     bpf_map_delete_elem(&spans_in_progress, &ctx_iface);
+    delete_goroutine_span(ctx);
     return 0;
-}
\ No newline at end of file
+}
+
+// This instrumentation attaches uprobe to the following function:
+// func NotFound(w ResponseWriter, r *Request)
+// ServeMux replies with NotFound when no registered pattern matches the request.
+SEC("uprobe/NotFound")
+int uprobe_NotFound(struct pt_regs *ctx)
+{
+    u64 request_pos = 3;
+    void *req_ptr = get_argument(ctx, request_pos);
+    void *ctx_iface = 0;
+    bpf_probe_read(&ctx_iface, sizeof(ctx_iface), (void *)(req_ptr + ctx_ptr_pos + 8));
+
+    struct http_request_t *httpReq = bpf_map_lookup_elem(&context_to_http_events, &ctx_iface);
+    if (httpReq == NULL)
+    {
+        return 0;
+    }
+
+    httpReq->status_code = STATUS_NOT_FOUND;
+    return 0;
+}
+
+// This instrumentation attaches uprobe to the following function:
+// func (w *response) WriteHeader(code int)
+// Only the first call sets the status code, as in net/http.
+SEC("uprobe/response_WriteHeader")
+int uprobe_response_WriteHeader(struct pt_regs *ctx)
+{
+    u64 code_pos = 2;
+    struct http_request_t *httpReq = goroutine_http_request(ctx);
+    if (httpReq == NULL || httpReq->status_code != 0)
+    {
+        return 0;
+    }
+
+    httpReq->status_code = (u64)get_argument(ctx, code_pos);
+    return 0;
+}
+
+// This instrumentation attaches uprobe to the following function:
+// func (w *response) write(lenData int, dataB []byte, dataS string) (n int, err error)
+// The first bytes written after a 5xx status code are read as the error body.
+SEC("uprobe/response_write")
+int uprobe_response_write(struct pt_regs *ctx)
+{
+    u64 len_pos = 2;
+    u64 data_b_pos = 3;
+    u64 data_s_pos = 6;
+    struct http_request_t *httpReq = goroutine_http_request(ctx);
+    if (httpReq == NULL || httpReq->status_code < MIN_ERROR_STATUS || httpReq->error_body[0] != 0)
+    {
+        return 0;
+    }
+
+    u64 len = (u64)get_argument(ctx, len_pos);
+    void *data = get_argument(ctx, data_b_pos);
+    if (data == NULL)
+    {
+        data = get_argument(ctx, data_s_pos);
+    }
+    // Read the volatile limit once so the verifier sees the bounded value
+    u64 body_size_limit = max_error_body_size;
+    u64 size = body_size_limit < MAX_ERROR_BODY_SIZE ? body_size_limit : MAX_ERROR_BODY_SIZE;
+    size = size < len ? size : len;
+    bpf_probe_read(httpReq->error_body, size, data);
+    return 0;
+}
+
+// This instrumentation attaches uprobe to the returns of the following function:
+// func (w *response) finishRequest()
+// The response is flushed to the connection once it returns.
+SEC("uprobe/response_finishRequest")
+int uprobe_response_finishRequest_Returns(struct pt_regs *ctx)
+{
+    return emit_pending_http_event(ctx);
+}
+
+// This instrumentation attaches uprobe to the returns of the following function:
+// func (c *conn) serve(ctx context.Context)
+// serve returns without finishing the request when the handler hijacked the connection.
+SEC("uprobe/conn_serve")
+int uprobe_conn_serve_Returns(struct pt_regs *ctx)
+{
+    return emit_pending_http_event(ctx);
+}
+
+// func newproc1(fn *funcval, callergp *g, callerpc uintptr) *g
+SEC("uprobe/runtime_newproc1")
+int uprobe_runtime_newproc1(struct pt_regs *ctx)
+{
+    return track_newproc1(ctx);
+}
+
+SEC("uprobe/runtime_newproc1")
+int uprobe_runtime_newproc1_Returns(struct pt_regs *ctx)
+{
+    return track_newproc1_returns(ctx);
+}
+
+// func goexit1()
+SEC("uprobe/runtime_goexit1")
+int uprobe_runtime_goexit1(struct pt_regs *ctx)
+{
+    return track_goexit1(ctx);
+}
+
+// func stopTheWorldWithSema()
+SEC("uprobe/runtime_stopTheWorldWithSema")
+int uprobe_runtime_stopTheWorldWithSema(struct pt_regs *ctx)
+{
+    return track_stop_the_world(ctx);
+}
+
+// func startTheWorldWithSema(emitTraceEvent bool) int64
+SEC("uprobe/runtime_startTheWorldWithSema")
+int uprobe_runtime_startTheWorldWithSema(struct pt_regs *ctx)
+{
+    return track_start_the_world(ctx);
+}
+
+// func ready(gp *g, traceskip int, next bool)
+SEC("uprobe/runtime_ready")
+int uprobe_runtime_ready(struct pt_regs *ctx)
+{
+    return track_runnable(ctx);
+}
+
+// func goschedImpl(gp *g)
+SEC("uprobe/runtime_goschedImpl")
+int uprobe_runtime_goschedImpl(struct pt_regs *ctx)
+{
+    return track_runnable(ctx);
+}
+
+// func execute(gp *g, inheritTime bool)
+SEC("uprobe/runtime_execute")
+int uprobe_runtime_execute(struct pt_regs *ctx)
+{
+    return track_execute(ctx);
+}
+
+// func (m *Mutex) lockSlow()
+SEC("uprobe/sync_Mutex_lockSlow")
+int uprobe_sync_Mutex_lockSlow(struct pt_regs *ctx)
+{
+    return track_mutex_wait(ctx);
+}
+
+SEC("uprobe/sync_Mutex_lockSlow")
+int uprobe_sync_Mutex_lockSlow_Returns(struct pt_regs *ctx)
+{
+    return track_mutex_acquired(ctx);
+}
@@ -54,17 +54,44 @@ type bpfSpecs struct {
 //
 // It can be passed ebpf.CollectionSpec.Assign.
 type bpfProgramSpecs struct {
-	UprobeServerMuxServeHTTP         *ebpf.ProgramSpec `ebpf:"uprobe_ServerMux_ServeHTTP"`
-	UprobeServerMuxServeHTTP_Returns *ebpf.ProgramSpec `ebpf:"uprobe_ServerMux_ServeHTTP_Returns"`
+	UprobeNotFound                     *ebpf.ProgramSpec `ebpf:"uprobe_NotFound"`
+	UprobeServerMuxServeHTTP           *ebpf.ProgramSpec `ebpf:"uprobe_ServerMux_ServeHTTP"`
+	UprobeServerMuxServeHTTP_Returns   *ebpf.ProgramSpec `ebpf:"uprobe_ServerMux_ServeHTTP_Returns"`
+	UprobeConnServeReturns             *ebpf.ProgramSpec `ebpf:"uprobe_conn_serve_Returns"`
+	UprobeResponseWriteHeader          *ebpf.ProgramSpec `ebpf:"uprobe_response_WriteHeader"`
+	UprobeResponseFinishRequestReturns *ebpf.ProgramSpec `ebpf:"uprobe_response_finishRequest_Returns"`
+	UprobeResponseWrite                *ebpf.ProgramSpec `ebpf:"uprobe_response_write"`
+	UprobeRuntimeExecute               *ebpf.ProgramSpec `ebpf:"uprobe_runtime_execute"`
+	UprobeRuntimeGoexit1               *ebpf.ProgramSpec `ebpf:"uprobe_runtime_goexit1"`
+	UprobeRuntimeGoschedImpl           *ebpf.ProgramSpec `ebpf:"uprobe_runtime_goschedImpl"`
+	UprobeRuntimeNewproc1              *ebpf.ProgramSpec `ebpf:"uprobe_runtime_newproc1"`
+	UprobeRuntimeNewproc1Returns       *ebpf.ProgramSpec `ebpf:"uprobe_runtime_newproc1_Returns"`
+	UprobeRuntimeReady                 *ebpf.ProgramSpec `ebpf:"uprobe_runtime_ready"`
+	UprobeRuntimeStartTheWorldWithSema *ebpf.ProgramSpec `ebpf:"uprobe_runtime_startTheWorldWithSema"`
+	UprobeRuntimeStopTheWorldWithSema  *ebpf.ProgramSpec `ebpf:"uprobe_runtime_stopTheWorldWithSema"`
+	UprobeSyncMutexLockSlow            *ebpf.ProgramSpec `ebpf:"uprobe_sync_Mutex_lockSlow"`
+	UprobeSyncMutexLockSlowReturns     *ebpf.ProgramSpec `ebpf:"uprobe_sync_Mutex_lockSlow_Returns"`
 }
 
 // bpfMapSpecs contains maps before they are loaded into the kernel.
 //
 // It can be passed ebpf.CollectionSpec.Assign.
 type bpfMapSpecs struct {
-	ContextToHttpEvents *ebpf.MapSpec `ebpf:"context_to_http_events"`
-	Events              *ebpf.MapSpec `ebpf:"events"`
-	SpansInProgress     *ebpf.MapSpec `ebpf:"spans_in_progress"`
+	ContextToHttpEvents          *ebpf.MapSpec `ebpf:"context_to_http_events"`
+	DroppedRequests              *ebpf.MapSpec `ebpf:"dropped_requests"`
+	Events                       *ebpf.MapSpec `ebpf:"events"`
+	GcPauseEvents                *ebpf.MapSpec `ebpf:"gc_pause_events"`
+	GoroutineRunnableSince       *ebpf.MapSpec `ebpf:"goroutine_runnable_since"`
+	GoroutineSchedLatency        *ebpf.MapSpec `ebpf:"goroutine_sched_latency"`
+	GoroutineSpans               *ebpf.MapSpec `ebpf:"goroutine_spans"`
+	GoroutineToHttpContext       *ebpf.MapSpec `ebpf:"goroutine_to_http_context"`
+	GoroutineToPendingHttpEvents *ebpf.MapSpec `ebpf:"goroutine_to_pending_http_events"`
+	HttpRequestBuffMap           *ebpf.MapSpec `ebpf:"http_request_buff_map"`
+	MutexWaitEvents              *ebpf.MapSpec `ebpf:"mutex_wait_events"`
+	MutexWaitStart               *ebpf.MapSpec `ebpf:"mutex_wait_start"`
+	Newproc1Callers              *ebpf.MapSpec `ebpf:"newproc1_callers"`
+	SpansInProgress              *ebpf.MapSpec `ebpf:"spans_in_progress"`
+	StwStartTime                 *ebpf.MapSpec `ebpf:"stw_start_time"`
 }
 
 // bpfObjects contains all objects after they have been loaded into the kernel.
@@ -86,16 +113,40 @@ func (o *bpfObjects) Close() error {
 //
 // It can be passed to loadBpfObjects or ebpf.CollectionSpec.LoadAndAssign.
 type bpfMaps struct {
-	ContextToHttpEvents *ebpf.Map `ebpf:"context_to_http_events"`
-	Events              *ebpf.Map `ebpf:"events"`
-	SpansInProgress     *ebpf.Map `ebpf:"spans_in_progress"`
+	ContextToHttpEvents          *ebpf.Map `ebpf:"context_to_http_events"`
+	DroppedRequests              *ebpf.Map `ebpf:"dropped_requests"`
+	Events                       *ebpf.Map `ebpf:"events"`
+	GcPauseEvents                *ebpf.Map `ebpf:"gc_pause_events"`
+	GoroutineRunnableSince       *ebpf.Map `ebpf:"goroutine_runnable_since"`
+	GoroutineSchedLatency        *ebpf.Map `ebpf:"goroutine_sched_latency"`
+	GoroutineSpans               *ebpf.Map `ebpf:"goroutine_spans"`
+	GoroutineToHttpContext       *ebpf.Map `ebpf:"goroutine_to_http_context"`
+	GoroutineToPendingHttpEvents *ebpf.Map `ebpf:"goroutine_to_pending_http_events"`
+	HttpRequestBuffMap           *ebpf.Map `ebpf:"http_request_buff_map"`
+	MutexWaitEvents              *ebpf.Map `ebpf:"mutex_wait_events"`
+	MutexWaitStart               *ebpf.Map `ebpf:"mutex_wait_start"`
+	Newproc1Callers              *ebpf.Map `ebpf:"newproc1_callers"`
+	SpansInProgress              *ebpf.Map `ebpf:"spans_in_progress"`
+	StwStartTime                 *ebpf.Map `ebpf:"stw_start_time"`
 }
 
 func (m *bpfMaps) Close() error {
 	return _BpfClose(
 		m.ContextToHttpEvents,
+		m.DroppedRequests,
 		m.Events,
+		m.GcPauseEvents,
+		m.GoroutineRunnableSince,
+		m.GoroutineSchedLatency,
+		m.GoroutineSpans,
+		m.GoroutineToHttpContext,
+		m.GoroutineToPendingHttpEvents,
+		m.HttpRequestBuffMap,
+		m.MutexWaitEvents,
+		m.MutexWaitStart,
+		m.Newproc1Callers,
 		m.SpansInProgress,
+		m.StwStartTime,
 	)
 }
 
@@ -103,14 +154,44 @@ func (m *bpfMaps) Close() error {
 //
 // It can be passed to loadBpfObjects or ebpf.CollectionSpec.LoadAndAssign.
 type bpfPrograms struct {
-	UprobeServerMuxServeHTTP         *ebpf.Program `ebpf:"uprobe_ServerMux_ServeHTTP"`
-	UprobeServerMuxServeHTTP_Returns *ebpf.Program `ebpf:"uprobe_ServerMux_ServeHTTP_Returns"`
+	UprobeNotFound                     *ebpf.Program `ebpf:"uprobe_NotFound"`
+	UprobeServerMuxServeHTTP           *ebpf.Program `ebpf:"uprobe_ServerMux_ServeHTTP"`
+	UprobeServerMuxServeHTTP_Returns   *ebpf.Program `ebpf:"uprobe_ServerMux_ServeHTTP_Returns"`
+	UprobeConnServeReturns             *ebpf.Program `ebpf:"uprobe_conn_serve_Returns"`
+	UprobeResponseWriteHeader          *ebpf.Program `ebpf:"uprobe_response_WriteHeader"`
+	UprobeResponseFinishRequestReturns *ebpf.Program `ebpf:"uprobe_response_finishRequest_Returns"`
+	UprobeResponseWrite                *ebpf.Program `ebpf:"uprobe_response_write"`
+	UprobeRuntimeExecute               *ebpf.Program `ebpf:"uprobe_runtime_execute"`
+	UprobeRuntimeGoexit1               *ebpf.Program `ebpf:"uprobe_runtime_goexit1"`
+	UprobeRuntimeGoschedImpl           *ebpf.Program `ebpf:"uprobe_runtime_goschedImpl"`
+	UprobeRuntimeNewproc1              *ebpf.Program `ebpf:"uprobe_runtime_newproc1"`
+	UprobeRuntimeNewproc1Returns       *ebpf.Program `ebpf:"uprobe_runtime_newproc1_Returns"`
+	UprobeRuntimeReady                 *ebpf.Program `ebpf:"uprobe_runtime_ready"`
+	UprobeRuntimeStartTheWorldWithSema *ebpf.Program `ebpf:"uprobe_runtime_startTheWorldWithSema"`
+	UprobeRuntimeStopTheWorldWithSema  *ebpf.Program `ebpf:"uprobe_runtime_stopTheWorldWithSema"`
+	UprobeSyncMutexLockSlow            *ebpf.Program `ebpf:"uprobe_sync_Mutex_lockSlow"`
+	UprobeSyncMutexLockSlowReturns     *ebpf.Program `ebpf:"uprobe_sync_Mutex_lockSlow_Returns"`
 }
 
 func (p *bpfPrograms) Close() error {
 	return _BpfClose(
+		p.UprobeNotFound,
 		p.UprobeServerMuxServeHTTP,
 		p.UprobeServerMuxServeHTTP_Returns,
+		p.UprobeConnServeReturns,
+		p.UprobeResponseWriteHeader,
+		p.UprobeResponseFinishRequestReturns,
+		p.UprobeResponseWrite,
+		p.UprobeRuntimeExecute,
+		p.UprobeRuntimeGoexit1,
+		p.UprobeRuntimeGoschedImpl,
+		p.UprobeRuntimeNewproc1,
+		p.UprobeRuntimeNewproc1Returns,
+		p.UprobeRuntimeReady,
+		p.UprobeRuntimeStartTheWorldWithSema,
+		p.UprobeRuntimeStopTheWorldWithSema,
+		p.UprobeSyncMutexLockSlow,
+		p.UprobeSyncMutexLockSlowReturns,
 	)
 }
 
@@ -0,0 +1,161 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+const (
+	// TrustedProxiesEnvVar is a comma separated list of IP addresses or
+	// CIDR ranges of the proxies trusted to set forwarding headers.
+	//
+	// Forwarding headers are only read from targets built with debug info.
+	// Only the first 8 buckets of the Request.Header map are searched and
+	// their overflow buckets are not followed, so the forwarding headers of
+	// requests with many headers may be missed. The peer is then recorded as
+	// the client.
+	TrustedProxiesEnvVar = "OTEL_GO_AUTO_HTTP_TRUSTED_PROXIES"
+)
+
+// parseTrustedProxies returns the proxies configured by
+// TrustedProxiesEnvVar, nil if it is not set.
+func parseTrustedProxies() ([]*net.IPNet, error) {
+	val, exists := os.LookupEnv(TrustedProxiesEnvVar)
+	if !exists {
+		return nil, nil
+	}
+
+	var result []*net.IPNet
+	for _, entry := range strings.Split(val, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid %s entry %q", TrustedProxiesEnvVar, entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			result = append(result, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s entry %q: %w", TrustedProxiesEnvVar, entry, err)
+		}
+		result = append(result, ipNet)
+	}
+
+	return result, nil
+}
+
+// clientAddress returns the address of the client that sent the request
+// received from peer. The addresses forwarded by proxies are read from the
+// X-Forwarded-For header, or the Forwarded header if it is missing. Without
+// trusted proxies the first forwarded address is used, as SDK
+// instrumentations do. Otherwise forwarded addresses are only used when peer
+// is trusted, and walked back from peer skipping trusted proxies.
+func clientAddress(peer string, forwardedFor string, forwarded string, trusted []*net.IPNet) string {
+	chain := parseForwardedFor(forwardedFor)
+	if len(chain) == 0 {
+		chain = parseForwarded(forwarded)
+	}
+	if len(chain) == 0 {
+		return peer
+	}
+
+	if len(trusted) == 0 {
+		return chain[0]
+	}
+
+	if !isTrusted(peer, trusted) {
+		return peer
+	}
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		if !isTrusted(chain[i], trusted) {
+			return chain[i]
+		}
+	}
+
+	return chain[0]
+}
+
+func isTrusted(addr string, trusted []*net.IPNet) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+
+	for _, ipNet := range trusted {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseForwardedFor returns the addresses of a X-Forwarded-For header.
+func parseForwardedFor(val string) []string {
+	var result []string
+	for _, addr := range strings.Split(val, ",") {
+		if addr = hostOnly(addr); addr != "" {
+			result = append(result, addr)
+		}
+	}
+
+	return result
+}
+
+// parseForwarded returns the addresses of the "for" parameters of a
+// Forwarded header, as defined by RFC 7239.
+func parseForwarded(val string) []string {
+	var result []string
+	for _, element := range strings.Split(val, ",") {
+		for _, pair := range strings.Split(element, ";") {
+			parts := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+			if len(parts) != 2 || !strings.EqualFold(parts[0], "for") {
+				continue
+			}
+
+			if addr := hostOnly(parts[1]); addr != "" {
+				result = append(result, addr)
+			}
+		}
+	}
+
+	return result
+}
+
+// hostOnly strips the quotes, brackets and port around a forwarded address.
+func hostOnly(addr string) string {
+	addr = strings.Trim(strings.TrimSpace(addr), `"`)
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+
+	return strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+}
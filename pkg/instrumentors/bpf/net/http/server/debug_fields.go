@@ -0,0 +1,74 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/process"
+)
+
+// debugFields are struct fields read by a feature of the probe whose offsets
+// are not tracked per version. They are read from the debug info of the
+// target instead, and the feature is turned off when they cannot be.
+type debugFields struct {
+	// enable is the constant turning the feature on.
+	enable string
+	fields []process.StructField
+	// varNames are the constants the offsets of fields are injected as.
+	varNames []string
+}
+
+// protoFields are the fields read to record the protocol version of requests.
+var protoFields = debugFields{
+	enable:   "read_proto_major",
+	fields:   []process.StructField{{Struct: "net/http.Request", Field: "ProtoMajor"}},
+	varNames: []string{"proto_major_pos"},
+}
+
+// hostFields are the fields read to record the scheme and port of requests.
+var hostFields = debugFields{
+	enable: "read_host",
+	fields: []process.StructField{
+		{Struct: "net/http.Request", Field: "Host"},
+		{Struct: "net/http.Request", Field: "TLS"},
+	},
+	varNames: []string{"host_ptr_pos", "tls_ptr_pos"},
+}
+
+// headerFields are the fields read to find the forwarding headers of
+// requests.
+var headerFields = debugFields{
+	enable: "read_headers",
+	fields: []process.StructField{
+		{Struct: "net/http.Request", Field: "Header"},
+		{Struct: "runtime.hmap", Field: "B"},
+		{Struct: "runtime.hmap", Field: "buckets"},
+	},
+	varNames: []string{"header_ptr_pos", "hmap_b_pos", "hmap_buckets_pos"},
+}
+
+// constants returns the constants turning the feature on for target.
+func (d debugFields) constants(target *process.TargetDetails) (map[string]interface{}, error) {
+	offsets, err := target.DebugFieldOffsets(d.fields)
+	if err != nil {
+		return nil, err
+	}
+
+	consts := map[string]interface{}{d.enable: true}
+	for i, o := range offsets {
+		consts[d.varNames[i]] = o.Offset
+	}
+
+	return consts, nil
+}
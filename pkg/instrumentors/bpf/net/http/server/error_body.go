@@ -0,0 +1,104 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/log"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/process"
+)
+
+const (
+	// ErrorBodySizeEnvVar is the number of bytes of the body of 5xx
+	// responses read as the description of the error status of their span.
+	// Bodies are not read when it is not set. When set, the status code of
+	// every response is recorded, not only 404.
+	ErrorBodySizeEnvVar = "OTEL_GO_AUTO_HTTP_SERVER_ERROR_BODY_SIZE"
+)
+
+const (
+	writeHeaderFuncName = "net/http.(*response).WriteHeader"
+	writeFuncName       = "net/http.(*response).write"
+)
+
+// errorBodyFuncNames are the functions reading the status codes and error
+// bodies of responses.
+var errorBodyFuncNames = []string{writeHeaderFuncName, writeFuncName}
+
+// errorBodySizeConfig returns the number of bytes of error bodies set by
+// ErrorBodySizeEnvVar, 0 if they are not read.
+func errorBodySizeConfig() (int, error) {
+	val, exists := os.LookupEnv(ErrorBodySizeEnvVar)
+	if !exists {
+		return 0, nil
+	}
+
+	size, err := strconv.Atoi(val)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("unsupported %s value %q", ErrorBodySizeEnvVar, val)
+	}
+
+	return size, nil
+}
+
+// parseErrorBodySize returns the number of bytes of error bodies read, capped to
+// the size of the event buffer, 0 if they are not read. Responses are
+// matched to their request by goroutine, through the register based ABI, in
+// the functions of errorBodyFuncNames.
+func (h *httpServerInstrumentor) parseErrorBodySize(target *process.TargetDetails) (int, error) {
+	size, err := errorBodySizeConfig()
+	if err != nil || size == 0 {
+		return 0, err
+	}
+
+	if !target.IsRegistersABI() {
+		log.Probe(h.LibraryName()).V(0).Info("reading error bodies requires Go 1.17 or newer, disabling it",
+			"go_version", target.GoVersion.Original())
+		return 0, nil
+	}
+
+	if !target.HasFunctions(errorBodyFuncNames...) {
+		log.Probe(h.LibraryName()).V(0).Info("response functions not found in target, disabling error bodies",
+			"functions", errorBodyFuncNames)
+		return 0, nil
+	}
+
+	if max := len(HttpEvent{}.ErrorBody); size > max {
+		return max, nil
+	}
+
+	return size, nil
+}
+
+// sanitizeErrorBody returns the text of body on a single line. Control
+// characters and invalid UTF-8, such as a character cut by the size limit,
+// are dropped and runs of white space collapsed.
+func sanitizeErrorBody(body []byte) string {
+	if i := bytes.IndexByte(body, 0); i >= 0 {
+		body = body[:i]
+	}
+	s := strings.ToValidUTF8(string(body), "")
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsControl(r)
+	})
+
+	return strings.Join(fields, " ")
+}
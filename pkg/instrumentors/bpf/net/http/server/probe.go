@@ -18,9 +18,10 @@ import (
 	"bytes"
 	"encoding/binary"
 	"errors"
+	"fmt"
+	"net"
 	"os"
-
-	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/bpffs"
+	"strconv"
 
 	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/link"
@@ -28,34 +29,140 @@ import (
 	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/inject"
 	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/context"
 	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/events"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/gcpauses"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/goroutines"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/mutexwaits"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/network"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/registry"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/schedlatency"
 	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/log"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/process"
 	"go.opentelemetry.io/otel/attribute"
 	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
 	"go.opentelemetry.io/otel/trace"
-	"golang.org/x/sys/unix"
 )
 
 //go:generate go run github.com/cilium/ebpf/cmd/bpf2go -target bpfel -cc clang -cflags $CFLAGS bpf ./bpf/probe.bpf.c
 
+const (
+	// SpanEndEnvVar configures when server spans end, either once the
+	// handler returns ("handler", the default) or once the response is
+	// flushed to the connection ("flush"). Spans of HTTP/2 requests always
+	// end once the handler returns.
+	SpanEndEnvVar = "OTEL_GO_AUTO_HTTP_SERVER_SPAN_END"
+)
+
+const (
+	spanEndHandlerReturn uint64 = iota
+	spanEndResponseFlush
+)
+
+var spanEndModes = map[string]uint64{
+	"handler": spanEndHandlerReturn,
+	"flush":   spanEndResponseFlush,
+}
+
+const (
+	finishRequestFuncName = "net/http.(*response).finishRequest"
+	connServeFuncName     = "net/http.(*conn).serve"
+)
+
+// notFoundFuncName marks requests no pattern of ServeMux matched. It may be
+// missing from targets that never call it, unmatched requests are then named
+// after their method without recording their status code.
+const notFoundFuncName = "net/http.NotFound"
+
+// flushFuncNames are the functions ending spans on response flush.
+var flushFuncNames = []string{finishRequestFuncName, connServeFuncName}
+
 type HttpEvent struct {
-	StartTime   uint64
-	EndTime     uint64
-	Method      [100]byte
-	Path        [100]byte
-	SpanContext context.EbpfSpanContext
+	StartTime    uint64
+	EndTime      uint64
+	Method       [100]byte
+	Path         [100]byte
+	SpanContext  context.EbpfSpanContext
+	StatusCode   uint64
+	IsTLS        uint64
+	Host         [100]byte
+	RemoteAddr   [100]byte
+	ForwardedFor [100]byte
+	Forwarded    [100]byte
+	ProtoMajor   uint64
+	SchedLatency uint64
+	ErrorBody    [128]byte
+	Goroutine    uint64
 }
 
 type httpServerInstrumentor struct {
-	bpfObjects   *bpfObjects
-	uprobe       link.Link
-	returnProbs  []link.Link
-	eventsReader *perf.Reader
+	libVersion      string
+	maxURLSize      int
+	maxHeaderSize   int
+	readHost        bool
+	trustedProxies  []*net.IPNet
+	bpfObjects      *bpfObjects
+	uprobe          link.Link
+	returnProbs     []link.Link
+	notFoundProbe   link.Link
+	errorBodySize   int
+	responseProbes  []link.Link
+	flushProbes     []link.Link
+	goroutineProbes []link.Link
+	gcPauses        *gcpauses.Recorder
+	schedLatency    bool
+	latencyProbes   []link.Link
+	mutexWaits      *mutexwaits.Recorder
+	eventsReader    *perf.Reader
+}
+
+// requestOffsets are the net/http and runtime struct fields read by the probe.
+var requestOffsets = []*inject.InjectStructField{
+	{
+		VarName:    "method_ptr_pos",
+		StructName: "net/http.Request",
+		Field:      "Method",
+	},
+	{
+		VarName:    "url_ptr_pos",
+		StructName: "net/http.Request",
+		Field:      "URL",
+	},
+	{
+		VarName:    "ctx_ptr_pos",
+		StructName: "net/http.Request",
+		Field:      "ctx",
+	},
+	{
+		VarName:    "path_ptr_pos",
+		StructName: "net/url.URL",
+		Field:      "Path",
+	},
+	{
+		VarName:    "remote_addr_ptr_pos",
+		StructName: "net/http.Request",
+		Field:      "RemoteAddr",
+	},
 }
 
 func New() *httpServerInstrumentor {
 	return &httpServerInstrumentor{}
 }
 
+// Probe describes the probe of this package.
+func Probe() registry.Probe {
+	i := New()
+	return registry.Probe{
+		ID:                i.LibraryName(),
+		Package:           "net/http",
+		Functions:         i.FuncNames(),
+		OptionalFunctions: featureFuncNames(),
+		Offsets: []registry.Offsets{
+			{Module: "go", Fields: requestOffsets},
+		},
+		Signals:   []registry.Signal{registry.SignalTraces},
+		SchemaURL: semconv.SchemaURL,
+	}
+}
+
 func (h *httpServerInstrumentor) LibraryName() string {
 	return "net/http"
 }
@@ -64,50 +171,166 @@ func (h *httpServerInstrumentor) FuncNames() []string {
 	return []string{"net/http.(*ServeMux).ServeHTTP"}
 }
 
+// featureFuncNames returns the functions of all the optional and opt-in
+// features of the probe.
+func featureFuncNames() []string {
+	funcs := []string{notFoundFuncName}
+	funcs = append(funcs, flushFuncNames...)
+	funcs = append(funcs, errorBodyFuncNames...)
+	funcs = append(funcs, goroutines.FuncNames...)
+	funcs = append(funcs, gcpauses.FuncNames...)
+	funcs = append(funcs, schedlatency.FuncNames...)
+	return append(funcs, mutexwaits.FuncNames...)
+}
+
+// OptionalFuncNames returns the functions of the optional features and of the
+// opt-in features turned on.
+func (h *httpServerInstrumentor) OptionalFuncNames() []string {
+	funcs := []string{notFoundFuncName}
+	if mode, err := spanEndModeConfig(); err == nil && mode == spanEndResponseFlush {
+		funcs = append(funcs, flushFuncNames...)
+	}
+	if size, err := errorBodySizeConfig(); err == nil && size > 0 {
+		funcs = append(funcs, errorBodyFuncNames...)
+	}
+	funcs = append(funcs, goroutines.OptionalFuncNames()...)
+	funcs = append(funcs, gcpauses.OptionalFuncNames()...)
+	funcs = append(funcs, schedlatency.OptionalFuncNames()...)
+	return append(funcs, mutexwaits.OptionalFuncNames()...)
+}
+
 func (h *httpServerInstrumentor) Load(ctx *context.InstrumentorContext) error {
-	spec, err := ctx.Injector.Inject(loadBpf, "go", ctx.TargetDetails.GoVersion.Original(), []*inject.InjectStructField{
-		{
-			VarName:    "method_ptr_pos",
-			StructName: "net/http.Request",
-			Field:      "Method",
-		},
-		{
-			VarName:    "url_ptr_pos",
-			StructName: "net/http.Request",
-			Field:      "URL",
-		},
-		{
-			VarName:    "ctx_ptr_pos",
-			StructName: "net/http.Request",
-			Field:      "ctx",
-		},
-		{
-			VarName:    "path_ptr_pos",
-			StructName: "net/url.URL",
-			Field:      "Path",
-		},
-	}, false)
+	h.libVersion = ctx.TargetDetails.GoVersion.Original()
+	protoConsts, err := protoFields.constants(ctx.TargetDetails)
+	if err != nil {
+		log.Probe(h.LibraryName()).V(0).Info("could not read the offset of the request protocol from debug info, not recording it",
+			"error", err.Error())
+	}
 
+	hostConsts, err := hostFields.constants(ctx.TargetDetails)
+	if err != nil {
+		log.Probe(h.LibraryName()).V(0).Info("could not read the offsets of the request host from debug info, not recording the scheme and port",
+			"error", err.Error())
+	}
+	h.readHost = hostConsts != nil
+
+	headerConsts, err := headerFields.constants(ctx.TargetDetails)
+	if err != nil {
+		log.Probe(h.LibraryName()).V(0).Info("could not read the offsets of request headers from debug info, recording peers as clients",
+			"error", err.Error())
+	}
+
+	spanEnd, err := h.spanEndMode(ctx.TargetDetails, protoConsts != nil)
 	if err != nil {
 		return err
 	}
 
-	h.bpfObjects = &bpfObjects{}
-	err = spec.LoadAndAssign(h.bpfObjects, &ebpf.CollectionOptions{
-		Maps: ebpf.MapOptions{
-			PinPath: bpffs.BpfFsPath,
-		},
+	h.trustedProxies, err = parseTrustedProxies()
+	if err != nil {
+		return err
+	}
+
+	h.errorBodySize, err = h.parseErrorBodySize(ctx.TargetDetails)
+	if err != nil {
+		return err
+	}
+
+	goroutineDepth, err := goroutines.Depth(ctx.TargetDetails)
+	if err != nil {
+		return err
+	}
+
+	gcPauseEvents, err := gcpauses.Enabled(ctx.TargetDetails)
+	if err != nil {
+		return err
+	}
+
+	h.schedLatency, err = schedlatency.Enabled(ctx.TargetDetails)
+	if err != nil {
+		return err
+	}
+
+	mutexWaitThreshold, err := mutexwaits.Threshold(ctx.TargetDetails)
+	if err != nil {
+		return err
+	}
+
+	spec, err := ctx.Injector.Inject(loadBpf, "go", h.libVersion, requestOffsets, false)
+
+	if err != nil {
+		return err
+	}
+
+	h.maxURLSize = context.ReadLimit(ctx.ReadLimits.URLSize, len(HttpEvent{}.Path))
+	h.maxHeaderSize = context.ReadLimit(ctx.ReadLimits.HeaderValueSize, len(HttpEvent{}.ForwardedFor))
+	err = spec.RewriteConstants(map[string]interface{}{
+		"span_end_mode":         spanEnd,
+		"max_url_size":          uint64(h.maxURLSize),
+		"max_header_value_size": uint64(h.maxHeaderSize),
+		"max_error_body_size":   uint64(h.errorBodySize),
 	})
 	if err != nil {
 		return err
 	}
 
+	if protoConsts != nil {
+		err = spec.RewriteConstants(protoConsts)
+		if err != nil {
+			return err
+		}
+	}
+
+	if hostConsts != nil {
+		err = spec.RewriteConstants(hostConsts)
+		if err != nil {
+			return err
+		}
+	}
+
+	if headerConsts != nil {
+		err = spec.RewriteConstants(headerConsts)
+		if err != nil {
+			return err
+		}
+	}
+
+	if goroutineDepth > 0 {
+		err = spec.RewriteConstants(goroutines.Constants(ctx.TargetDetails, goroutineDepth))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = spec.RewriteConstants(schedlatency.Constants(h.schedLatency))
+	if err != nil {
+		return err
+	}
+
+	if mutexWaitThreshold > 0 {
+		err = spec.RewriteConstants(mutexwaits.Constants(mutexWaitThreshold))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = ctx.LimitRequests(spec, "context_to_http_events")
+	if err != nil {
+		return err
+	}
+
+	h.bpfObjects = &bpfObjects{}
+	err = ctx.LoadAndAssign(h.LibraryName(), spec, h.bpfObjects)
+	if err != nil {
+		return err
+	}
+	ctx.TrackDroppedRequests(h.LibraryName(), h.bpfObjects.DroppedRequests)
+
 	offset, err := ctx.TargetDetails.GetFunctionOffset(h.FuncNames()[0])
 	if err != nil {
 		return err
 	}
 
-	up, err := ctx.Executable.Uprobe("", h.bpfObjects.UprobeServerMuxServeHTTP, &link.UprobeOptions{
+	up, err := ctx.ExecutableFor(h.FuncNames()[0]).Uprobe("", h.bpfObjects.UprobeServerMuxServeHTTP, &link.UprobeOptions{
 		Offset: offset,
 	})
 	if err != nil {
@@ -121,7 +344,7 @@ func (h *httpServerInstrumentor) Load(ctx *context.InstrumentorContext) error {
 	}
 
 	for _, ret := range retOffsets {
-		retProbe, err := ctx.Executable.Uprobe("", h.bpfObjects.UprobeServerMuxServeHTTP_Returns, &link.UprobeOptions{
+		retProbe, err := ctx.ExecutableFor(h.FuncNames()[0]).Uprobe("", h.bpfObjects.UprobeServerMuxServeHTTP_Returns, &link.UprobeOptions{
 			Offset: ret,
 		})
 		if err != nil {
@@ -130,6 +353,111 @@ func (h *httpServerInstrumentor) Load(ctx *context.InstrumentorContext) error {
 		h.returnProbs = append(h.returnProbs, retProbe)
 	}
 
+	if ctx.TargetDetails.HasFunctions(notFoundFuncName) {
+		notFoundOffset, err := ctx.TargetDetails.GetFunctionOffset(notFoundFuncName)
+		if err != nil {
+			return err
+		}
+		nfProbe, err := ctx.ExecutableFor(notFoundFuncName).Uprobe("", h.bpfObjects.UprobeNotFound, &link.UprobeOptions{
+			Offset: notFoundOffset,
+		})
+		if err != nil {
+			return err
+		}
+		h.notFoundProbe = nfProbe
+	} else {
+		log.Probe(h.LibraryName()).V(0).Info("function replying to unmatched requests not found in target, not recording their status code",
+			"function", notFoundFuncName)
+	}
+
+	if spanEnd == spanEndResponseFlush {
+		flushProgs := map[string]*ebpf.Program{
+			finishRequestFuncName: h.bpfObjects.UprobeResponseFinishRequestReturns,
+			connServeFuncName:     h.bpfObjects.UprobeConnServeReturns,
+		}
+		for funcName, prog := range flushProgs {
+			offsets, err := ctx.TargetDetails.GetFunctionReturns(funcName)
+			if err != nil {
+				return err
+			}
+
+			for _, offset := range offsets {
+				flushProbe, err := ctx.ExecutableFor(funcName).Uprobe("", prog, &link.UprobeOptions{
+					Offset: offset,
+				})
+				if err != nil {
+					return err
+				}
+				h.flushProbes = append(h.flushProbes, flushProbe)
+			}
+		}
+	}
+
+	if h.errorBodySize > 0 {
+		responseProgs := map[string]*ebpf.Program{
+			writeHeaderFuncName: h.bpfObjects.UprobeResponseWriteHeader,
+			writeFuncName:       h.bpfObjects.UprobeResponseWrite,
+		}
+		for funcName, prog := range responseProgs {
+			offset, err := ctx.TargetDetails.GetFunctionOffset(funcName)
+			if err != nil {
+				return err
+			}
+
+			responseProbe, err := ctx.ExecutableFor(funcName).Uprobe("", prog, &link.UprobeOptions{
+				Offset: offset,
+			})
+			if err != nil {
+				return err
+			}
+			h.responseProbes = append(h.responseProbes, responseProbe)
+		}
+	}
+
+	if goroutineDepth > 0 {
+		h.goroutineProbes, err = goroutines.Attach(ctx, goroutines.Programs{
+			Newproc1:        h.bpfObjects.UprobeRuntimeNewproc1,
+			Newproc1Returns: h.bpfObjects.UprobeRuntimeNewproc1Returns,
+			Goexit1:         h.bpfObjects.UprobeRuntimeGoexit1,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if gcPauseEvents {
+		h.gcPauses, err = gcpauses.Attach(ctx, gcpauses.Programs{
+			StopTheWorld:  h.bpfObjects.UprobeRuntimeStopTheWorldWithSema,
+			StartTheWorld: h.bpfObjects.UprobeRuntimeStartTheWorldWithSema,
+			Events:        h.bpfObjects.GcPauseEvents,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if h.schedLatency {
+		h.latencyProbes, err = schedlatency.Attach(ctx, schedlatency.Programs{
+			Ready:   h.bpfObjects.UprobeRuntimeReady,
+			Gosched: h.bpfObjects.UprobeRuntimeGoschedImpl,
+			Execute: h.bpfObjects.UprobeRuntimeExecute,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if mutexWaitThreshold > 0 {
+		h.mutexWaits, err = mutexwaits.Attach(ctx, mutexwaits.Programs{
+			LockSlow:        h.bpfObjects.UprobeSyncMutexLockSlow,
+			LockSlowReturns: h.bpfObjects.UprobeSyncMutexLockSlowReturns,
+			Events:          h.bpfObjects.MutexWaitEvents,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
 	rd, err := perf.NewReader(h.bpfObjects.Events, os.Getpagesize())
 	if err != nil {
 		return err
@@ -139,8 +467,53 @@ func (h *httpServerInstrumentor) Load(ctx *context.InstrumentorContext) error {
 	return nil
 }
 
+// spanEndModeConfig returns the span end mode configured by SpanEndEnvVar.
+func spanEndModeConfig() (uint64, error) {
+	val, exists := os.LookupEnv(SpanEndEnvVar)
+	if !exists {
+		return spanEndHandlerReturn, nil
+	}
+
+	mode, supported := spanEndModes[val]
+	if !supported {
+		return 0, fmt.Errorf("unsupported %s value %q", SpanEndEnvVar, val)
+	}
+
+	return mode, nil
+}
+
+// spanEndMode returns the span end mode used for target. Ending spans on
+// response flush tracks requests by goroutine, which is only possible with
+// the register based ABI, and requires the functions of flushFuncNames and
+// the protocol of requests, as HTTP/2 requests are not served by conn.serve.
+func (h *httpServerInstrumentor) spanEndMode(target *process.TargetDetails, protoRead bool) (uint64, error) {
+	mode, err := spanEndModeConfig()
+	if err != nil || mode != spanEndResponseFlush {
+		return mode, err
+	}
+
+	if !target.IsRegistersABI() {
+		log.Probe(h.LibraryName()).V(0).Info("ending spans on response flush requires Go 1.17 or newer, ending spans on handler return",
+			"go_version", target.GoVersion.Original())
+		return spanEndHandlerReturn, nil
+	}
+
+	if !target.HasFunctions(flushFuncNames...) {
+		log.Probe(h.LibraryName()).V(0).Info("response flush functions not found in target, ending spans on handler return",
+			"functions", flushFuncNames)
+		return spanEndHandlerReturn, nil
+	}
+
+	if !protoRead {
+		log.Probe(h.LibraryName()).V(0).Info("request protocol not read, ending spans on handler return")
+		return spanEndHandlerReturn, nil
+	}
+
+	return mode, nil
+}
+
 func (h *httpServerInstrumentor) Run(eventsChan chan<- *events.Event) {
-	logger := log.Logger.WithName("net/http-instrumentor")
+	logger := log.Probe(h.LibraryName())
 	var event HttpEvent
 	for {
 		record, err := h.eventsReader.Read()
@@ -148,7 +521,7 @@ func (h *httpServerInstrumentor) Run(eventsChan chan<- *events.Event) {
 			if errors.Is(err, perf.ErrClosed) {
 				return
 			}
-			logger.Error(err, "error reading from perf reader")
+			log.Error(logger, log.ErrProbeRead, err, "error reading from perf reader")
 			continue
 		}
 
@@ -158,7 +531,7 @@ func (h *httpServerInstrumentor) Run(eventsChan chan<- *events.Event) {
 		}
 
 		if err := binary.Read(bytes.NewBuffer(record.RawSample), binary.LittleEndian, &event); err != nil {
-			logger.Error(err, "error parsing perf event")
+			log.Error(logger, log.ErrEventDecode, err, "error parsing perf event")
 			continue
 		}
 
@@ -167,8 +540,9 @@ func (h *httpServerInstrumentor) Run(eventsChan chan<- *events.Event) {
 }
 
 func (h *httpServerInstrumentor) convertEvent(e *HttpEvent) *events.Event {
-	method := unix.ByteSliceToString(e.Method[:])
-	path := unix.ByteSliceToString(e.Path[:])
+	var strs events.StringReader
+	method := strs.Read(e.Method[:])
+	path := strs.ReadLimited(e.Path[:], h.maxURLSize)
 
 	sc := trace.NewSpanContext(trace.SpanContextConfig{
 		TraceID:    e.SpanContext.TraceID,
@@ -176,22 +550,89 @@ func (h *httpServerInstrumentor) convertEvent(e *HttpEvent) *events.Event {
 		TraceFlags: trace.FlagsSampled,
 	})
 
+	// ServeMux does not expose the pattern a request matched, so spans are
+	// not named after their path, which would produce an unbounded number of
+	// span names.
+	name := "HTTP " + method
+	attrs := []attribute.KeyValue{
+		semconv.HTTPMethodKey.String(method),
+		semconv.HTTPTargetKey.String(path),
+	}
+	if h.readHost {
+		scheme := "http"
+		if e.IsTLS != 0 {
+			scheme = "https"
+		}
+		attrs = append(attrs, semconv.HTTPSchemeKey.String(scheme))
+		if port := serverPort(strs.Read(e.Host[:]), scheme); port != 0 {
+			attrs = append(attrs, semconv.NetHostPortKey.Int(port))
+		}
+	}
+
+	// HTTP/3 is served over QUIC, on top of UDP.
+	transport := network.TransportTCP
+	if e.ProtoMajor == 3 {
+		transport = network.TransportUDP
+	}
+	remoteAddr := strs.Read(e.RemoteAddr[:])
+	attrs = append(attrs, network.PeerAttributes(remoteAddr, transport)...)
+
+	if peer, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		// The first forwarded addresses may be missing from truncated
+		// headers, the peer is used instead.
+		client := peer
+		forwardedFor, forwardedForOK := strs.TryReadLimited(e.ForwardedFor[:], h.maxHeaderSize)
+		forwarded, forwardedOK := strs.TryReadLimited(e.Forwarded[:], h.maxHeaderSize)
+		if forwardedForOK && forwardedOK {
+			client = clientAddress(peer, forwardedFor, forwarded, h.trustedProxies)
+		}
+		attrs = append(attrs, semconv.HTTPClientIPKey.String(client))
+	}
+
+	if e.StatusCode != 0 {
+		attrs = append(attrs, semconv.HTTPStatusCodeKey.Int(int(e.StatusCode)))
+	}
+
+	if h.schedLatency {
+		attrs = append(attrs, schedlatency.Attribute(e.SchedLatency))
+	}
+	attrs = append(attrs, strs.Attributes()...)
+
 	return &events.Event{
-		Library:     h.LibraryName(),
-		Name:        path,
-		Kind:        trace.SpanKindServer,
-		StartTime:   int64(e.StartTime),
-		EndTime:     int64(e.EndTime),
-		SpanContext: &sc,
-		Attributes: []attribute.KeyValue{
-			semconv.HTTPMethodKey.String(method),
-			semconv.HTTPTargetKey.String(path),
-		},
+		Library:           h.LibraryName(),
+		LibraryVersion:    h.libVersion,
+		Name:              name,
+		Kind:              trace.SpanKindServer,
+		StartTime:         int64(e.StartTime),
+		EndTime:           int64(e.EndTime),
+		SpanContext:       &sc,
+		Attributes:        attrs,
+		StatusDescription: sanitizeErrorBody(e.ErrorBody[:]),
+		Goroutine:         e.Goroutine,
 	}
 }
 
+// serverPort returns the port of host, or the default port of scheme if host
+// has none. It returns 0 if the port is invalid.
+func serverPort(host string, scheme string) int {
+	_, port, err := net.SplitHostPort(host)
+	if err != nil {
+		if scheme == "https" {
+			return 443
+		}
+		return 80
+	}
+
+	p, err := strconv.Atoi(port)
+	if err != nil || p <= 0 || p > 65535 {
+		return 0
+	}
+
+	return p
+}
+
 func (h *httpServerInstrumentor) Close() {
-	log.Logger.V(0).Info("closing net/http instrumentor")
+	log.Probe(h.LibraryName()).V(0).Info("closing net/http instrumentor")
 	if h.eventsReader != nil {
 		h.eventsReader.Close()
 	}
@@ -204,6 +645,34 @@ func (h *httpServerInstrumentor) Close() {
 		r.Close()
 	}
 
+	if h.notFoundProbe != nil {
+		h.notFoundProbe.Close()
+	}
+
+	for _, r := range h.flushProbes {
+		r.Close()
+	}
+
+	for _, r := range h.responseProbes {
+		r.Close()
+	}
+
+	for _, r := range h.goroutineProbes {
+		r.Close()
+	}
+
+	if h.gcPauses != nil {
+		h.gcPauses.Close()
+	}
+
+	for _, r := range h.latencyProbes {
+		r.Close()
+	}
+
+	if h.mutexWaits != nil {
+		h.mutexWaits.Close()
+	}
+
 	if h.bpfObjects != nil {
 		h.bpfObjects.Close()
 	}
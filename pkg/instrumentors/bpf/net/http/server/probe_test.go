@@ -0,0 +1,39 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestConvertEventSpanNameCardinality(t *testing.T) {
+	const paths = 10000
+	h := &httpServerInstrumentor{maxURLSize: 100, maxHeaderSize: 100}
+	names := make(map[string]bool)
+	for i := 0; i < paths; i++ {
+		var e HttpEvent
+		copy(e.Method[:], []string{"GET", "POST"}[i%2])
+		copy(e.Path[:], fmt.Sprintf("/items/%d", i))
+		if i%3 == 0 {
+			e.StatusCode = 404
+		}
+		names[h.convertEvent(&e).Name] = true
+	}
+
+	if len(names) != 2 || !names["HTTP GET"] || !names["HTTP POST"] {
+		t.Errorf("%d paths produced span names %v, want HTTP GET and HTTP POST", paths, names)
+	}
+}
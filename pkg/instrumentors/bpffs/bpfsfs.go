@@ -14,6 +14,22 @@
 
 package bpffs
 
+import (
+	"fmt"
+	"path/filepath"
+)
+
 const (
 	BpfFsPath = "/sys/fs/bpf"
+
+	// PinMapsEnvVar set to "true" pins the maps of every probe under a
+	// directory per target, so an agent restarted after a crash reuses
+	// them and keeps the state of in-flight requests.
+	PinMapsEnvVar = "OTEL_GO_AUTO_PIN_MAPS"
 )
+
+// TargetPinPath returns the directory maps of the probes attached to pid
+// are pinned to.
+func TargetPinPath(pid int) string {
+	return filepath.Join(BpfFsPath, fmt.Sprintf("otel-go-%d", pid))
+}
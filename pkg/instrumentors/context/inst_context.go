@@ -15,8 +15,15 @@
 package context
 
 import (
+	"strings"
+	"sync"
+
+	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/link"
 	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/inject"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/bpffs"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/diagnostics"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/registry"
 	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/process"
 )
 
@@ -24,4 +31,77 @@ type InstrumentorContext struct {
 	TargetDetails *process.TargetDetails
 	Executable    *link.Executable
 	Injector      *inject.Injector
+	// SharedObjects holds the shared objects of the target containing
+	// relevant functions, keyed by path.
+	SharedObjects map[string]*link.Executable
+	// PinPath is the directory all maps are pinned to, empty if only maps
+	// shared between probes are pinned.
+	PinPath string
+	// Instance numbers the instrumentors loaded once per module instance
+	// providing their functions, empty for the first one.
+	Instance string
+	// RequestLimits bounds the in-flight requests tracked by each probe.
+	RequestLimits RequestLimits
+	// ReadLimits bounds the strings read by the probes.
+	ReadLimits ReadLimits
+	// Variant is the variant of the programs of the instrumentor selected
+	// for the target, nil if its probe has no variants.
+	Variant *registry.Variant
+}
+
+// loadMu serializes loading collections, as instrumentors loaded
+// concurrently create and pin the maps they share.
+var loadMu sync.Mutex
+
+// ExecutableFor returns the executable or shared object uprobes for
+// funcName should be attached to.
+func (c *InstrumentorContext) ExecutableFor(funcName string) *link.Executable {
+	for _, f := range c.TargetDetails.Functions {
+		if f.Name != funcName || f.Path == "" {
+			continue
+		}
+
+		if exe, exists := c.SharedObjects[f.Path]; exists {
+			return exe
+		}
+	}
+
+	return c.Executable
+}
+
+// LoadAndAssign loads the maps and programs of spec for library and assigns
+// them to objs.
+func (c *InstrumentorContext) LoadAndAssign(library string, spec *ebpf.CollectionSpec, objs interface{}) error {
+	loadMu.Lock()
+	defer loadMu.Unlock()
+
+	return spec.LoadAndAssign(objs, &ebpf.CollectionOptions{
+		Maps: c.mapOptions(library, spec),
+		Programs: ebpf.ProgramOptions{
+			LogSize: diagnostics.VerifierLogSize,
+		},
+	})
+}
+
+// mapOptions returns the options to load the maps of spec for library. When
+// PinPath is set, maps not shared between probes are pinned as well, prefixed
+// with the library name to keep them apart from the maps of other probes.
+func (c *InstrumentorContext) mapOptions(library string, spec *ebpf.CollectionSpec) ebpf.MapOptions {
+	if c.PinPath == "" {
+		return ebpf.MapOptions{PinPath: bpffs.BpfFsPath}
+	}
+
+	// Map names may only contain alphanumeric characters, '_' and '.'.
+	prefix := strings.NewReplacer("/", "_", "-", "_").Replace(library)
+	if c.Instance != "" {
+		prefix += "_" + c.Instance
+	}
+	for _, m := range spec.Maps {
+		if m.Pinning == ebpf.PinNone {
+			m.Pinning = ebpf.PinByName
+			m.Name = prefix + "." + m.Name
+		}
+	}
+
+	return ebpf.MapOptions{PinPath: c.PinPath}
 }
@@ -0,0 +1,77 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package context
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+const (
+	// MaxURLSizeEnvVar is the number of bytes of request URLs read by the
+	// probes.
+	MaxURLSizeEnvVar = "OTEL_GO_AUTO_MAX_URL_SIZE"
+	// MaxHeaderValueSizeEnvVar is the number of bytes of request header
+	// values read by the probes.
+	MaxHeaderValueSizeEnvVar = "OTEL_GO_AUTO_MAX_HEADER_VALUE_SIZE"
+)
+
+// ReadLimits bounds the strings read by the probes per attribute class.
+// Limits are capped to the size of the event buffers the probes read the
+// strings into, zero reads as much as the buffers hold.
+type ReadLimits struct {
+	URLSize         int
+	HeaderValueSize int
+}
+
+// ParseReadLimits returns the limits configured by MaxURLSizeEnvVar and
+// MaxHeaderValueSizeEnvVar.
+func ParseReadLimits() (ReadLimits, error) {
+	var limits ReadLimits
+	var err error
+	if limits.URLSize, err = parseReadLimit(MaxURLSizeEnvVar); err != nil {
+		return ReadLimits{}, err
+	}
+	if limits.HeaderValueSize, err = parseReadLimit(MaxHeaderValueSizeEnvVar); err != nil {
+		return ReadLimits{}, err
+	}
+
+	return limits, nil
+}
+
+func parseReadLimit(envVar string) (int, error) {
+	val, exists := os.LookupEnv(envVar)
+	if !exists {
+		return 0, nil
+	}
+
+	limit, err := strconv.Atoi(val)
+	if err != nil || limit <= 0 {
+		return 0, fmt.Errorf("unsupported %s value %q", envVar, val)
+	}
+
+	return limit, nil
+}
+
+// ReadLimit returns the number of bytes read into a buffer of size bytes
+// under limit.
+func ReadLimit(limit int, size int) int {
+	if limit == 0 || limit > size {
+		return size
+	}
+
+	return limit
+}
@@ -0,0 +1,140 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package context
+
+import (
+	"expvar"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/cilium/ebpf"
+)
+
+const (
+	// MaxRequestsEnvVar is the number of in-flight requests each probe
+	// tracks. Probes keep the size they are compiled with when it is not
+	// set.
+	MaxRequestsEnvVar = "OTEL_GO_AUTO_MAX_CONCURRENT_REQUESTS"
+	// RequestsPolicyEnvVar selects the requests dropped once a probe tracks
+	// MaxRequestsEnvVar in-flight requests, either the new ones
+	// ("drop-new", the default) or the oldest ones ("drop-oldest").
+	RequestsPolicyEnvVar = "OTEL_GO_AUTO_CONCURRENT_REQUESTS_POLICY"
+)
+
+// RequestsPolicy selects the requests dropped by probes tracking as many
+// in-flight requests as they can.
+type RequestsPolicy string
+
+const (
+	DropNewRequests    RequestsPolicy = "drop-new"
+	DropOldestRequests RequestsPolicy = "drop-oldest"
+)
+
+// Keys of the dropped_requests map of the probes, see requests.h.
+const (
+	droppedNewRequestsKey uint32 = iota
+)
+
+// droppedRequests publishes the requests dropped by each probe under
+// /debug/vars of the diagnostics server. Only new requests refused by a full
+// map are counted, not the ones evicted with the drop-oldest policy.
+var droppedRequests = expvar.NewMap("dropped_requests")
+
+// RequestLimits bounds the in-flight requests tracked by each probe.
+type RequestLimits struct {
+	// MaxRequests is the number of in-flight requests tracked, zero keeps
+	// the size the probes are compiled with.
+	MaxRequests uint32
+	Policy      RequestsPolicy
+}
+
+// ParseRequestLimits returns the limits configured by MaxRequestsEnvVar and
+// RequestsPolicyEnvVar.
+func ParseRequestLimits() (RequestLimits, error) {
+	limits := RequestLimits{Policy: DropNewRequests}
+	if val, exists := os.LookupEnv(MaxRequestsEnvVar); exists {
+		max, err := strconv.ParseUint(val, 10, 32)
+		if err != nil || max == 0 {
+			return RequestLimits{}, fmt.Errorf("unsupported %s value %q", MaxRequestsEnvVar, val)
+		}
+		limits.MaxRequests = uint32(max)
+	}
+
+	if val, exists := os.LookupEnv(RequestsPolicyEnvVar); exists {
+		switch policy := RequestsPolicy(val); policy {
+		case DropNewRequests, DropOldestRequests:
+			limits.Policy = policy
+		default:
+			return RequestLimits{}, fmt.Errorf("unsupported %s value %q", RequestsPolicyEnvVar, val)
+		}
+	}
+
+	return limits, nil
+}
+
+// LimitRequests applies RequestLimits to the maps of spec holding in-flight
+// requests. Requests are dropped once a hash map is full, the oldest ones
+// are evicted from LRU hash maps.
+func (c *InstrumentorContext) LimitRequests(spec *ebpf.CollectionSpec, mapNames ...string) error {
+	for _, name := range mapNames {
+		m, exists := spec.Maps[name]
+		if !exists {
+			return fmt.Errorf("could not find map %s", name)
+		}
+
+		if c.RequestLimits.MaxRequests != 0 {
+			m.MaxEntries = c.RequestLimits.MaxRequests
+		}
+		if c.RequestLimits.Policy == DropOldestRequests && m.Type == ebpf.Hash {
+			m.Type = ebpf.LRUHash
+		}
+	}
+
+	return nil
+}
+
+// TrackDroppedRequests publishes the counters of the requests dropped by
+// the probe of library, read from its dropped_requests map.
+func (c *InstrumentorContext) TrackDroppedRequests(library string, m *ebpf.Map) {
+	name := library
+	if c.Instance != "" {
+		name += "#" + c.Instance
+	}
+
+	droppedRequests.Set(name, expvar.Func(func() interface{} {
+		newRequests, err := sumPerCPU(m, droppedNewRequestsKey)
+		if err != nil {
+			return nil
+		}
+
+		return map[string]uint64{
+			"new": newRequests,
+		}
+	}))
+}
+
+func sumPerCPU(m *ebpf.Map, key uint32) (uint64, error) {
+	var values []uint64
+	if err := m.Lookup(key, &values); err != nil {
+		return 0, err
+	}
+
+	var sum uint64
+	for _, v := range values {
+		sum += v
+	}
+	return sum, nil
+}
@@ -0,0 +1,77 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package instrumentors
+
+import (
+	"expvar"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/events"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/log"
+)
+
+// maxRecentEvents is the number of most recent events duplicates are looked
+// for among.
+const maxRecentEvents = 4096
+
+// duplicateEvents publishes the duplicate events dropped for each probe under
+// /debug/vars of the diagnostics server.
+var duplicateEvents = expvar.NewMap("duplicate_events")
+
+type eventKey struct {
+	goroutine uint64
+	library   string
+	startTime int64
+}
+
+// eventDeduplicator drops the events of a span already received, as
+// produced when a uretprobe fires twice for a single return on some kernels.
+// Events are keyed by goroutine, library and start time: a goroutine starts
+// a single span of a probe at a time.
+type eventDeduplicator struct {
+	recent map[eventKey]struct{}
+	// ring holds the keys of recent in arrival order, to forget the oldest.
+	ring [maxRecentEvents]eventKey
+	next int
+
+	// duplicatesTotal counts the dropped events.
+	duplicatesTotal uint64
+}
+
+func newEventDeduplicator() *eventDeduplicator {
+	return &eventDeduplicator{
+		recent: make(map[eventKey]struct{}, maxRecentEvents),
+	}
+}
+
+// isDuplicate reports whether an event of the span of e was recently seen.
+// It is not safe for concurrent use.
+func (d *eventDeduplicator) isDuplicate(e *events.Event) bool {
+	key := eventKey{goroutine: e.Goroutine, library: e.Library, startTime: e.StartTime}
+	if _, exists := d.recent[key]; exists {
+		total := atomic.AddUint64(&d.duplicatesTotal, 1)
+		duplicateEvents.Add(e.Library, 1)
+		log.Component(log.ComponentManager).V(1).Info("dropping duplicate event", "library", e.Library,
+			"goroutine", fmt.Sprintf("%#x", e.Goroutine), "duplicates_total", total)
+		return true
+	}
+
+	delete(d.recent, d.ring[d.next])
+	d.ring[d.next] = key
+	d.next = (d.next + 1) % maxRecentEvents
+	d.recent[key] = struct{}{}
+	return false
+}
@@ -0,0 +1,104 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package instrumentors
+
+import (
+	"testing"
+
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/events"
+)
+
+func newTestManager(goroutineSpanLimit uint64, handler RawEventHandler) *instrumentorsManager {
+	return &instrumentorsManager{
+		watchdog:     newProbeWatchdog(),
+		dedup:        newEventDeduplicator(),
+		limiter:      newGoroutineLimiter(goroutineSpanLimit),
+		duplicateOf:  make(map[string]string),
+		eventHandler: handler,
+	}
+}
+
+func TestHandleEventDropsDuplicates(t *testing.T) {
+	tests := []struct {
+		name           string
+		events         []events.Event
+		wantHandled    int
+		wantDuplicates uint64
+	}{
+		{
+			name: "distinct events",
+			events: []events.Event{
+				{Library: "net/http", Goroutine: 1, StartTime: 100},
+				{Library: "net/http", Goroutine: 1, StartTime: 200},
+				{Library: "net/http", Goroutine: 2, StartTime: 100},
+				{Library: "gorilla/mux", Goroutine: 1, StartTime: 100},
+			},
+			wantHandled: 4,
+		},
+		{
+			name: "uretprobe fired twice",
+			events: []events.Event{
+				{Library: "net/http", Goroutine: 1, StartTime: 100, EndTime: 150},
+				{Library: "net/http", Goroutine: 1, StartTime: 100, EndTime: 160},
+			},
+			wantHandled:    1,
+			wantDuplicates: 1,
+		},
+		{
+			name: "duplicates interleaved with other events",
+			events: []events.Event{
+				{Library: "net/http", Goroutine: 1, StartTime: 100},
+				{Library: "net/http", Goroutine: 2, StartTime: 110},
+				{Library: "net/http", Goroutine: 1, StartTime: 100},
+				{Library: "net/http", Goroutine: 2, StartTime: 110},
+				{Library: "net/http", Goroutine: 1, StartTime: 100},
+			},
+			wantHandled:    2,
+			wantDuplicates: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var handled int
+			m := newTestManager(0, func(string, *events.Event) { handled++ })
+			for i := range tt.events {
+				m.handleEvent(&tt.events[i])
+			}
+
+			if handled != tt.wantHandled {
+				t.Errorf("handled %d events, want %d", handled, tt.wantHandled)
+			}
+			if got := m.DuplicateEventsTotal(); got != tt.wantDuplicates {
+				t.Errorf("DuplicateEventsTotal() = %d, want %d", got, tt.wantDuplicates)
+			}
+		})
+	}
+}
+
+func TestEventDeduplicatorForgetsOldestEvents(t *testing.T) {
+	d := newEventDeduplicator()
+	first := &events.Event{Library: "net/http", Goroutine: 1, StartTime: 0}
+	if d.isDuplicate(first) {
+		t.Fatal("first event reported as duplicate")
+	}
+	for i := 1; i <= maxRecentEvents; i++ {
+		d.isDuplicate(&events.Event{Library: "net/http", Goroutine: 1, StartTime: int64(i)})
+	}
+
+	if d.isDuplicate(first) {
+		t.Error("event older than maxRecentEvents reported as duplicate")
+	}
+}
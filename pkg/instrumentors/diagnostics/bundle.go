@@ -0,0 +1,120 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diagnostics
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/process"
+)
+
+const (
+	// DirEnvVar is the directory diagnostic bundles are written to. Bundles
+	// are only logged when it is not set.
+	DirEnvVar = "OTEL_GO_AUTO_DIAGNOSTICS_DIR"
+
+	// VerifierLogSize is the size of the buffer used to capture the kernel
+	// verifier log, large enough to avoid truncating it for our programs.
+	VerifierLogSize = 4 * 1024 * 1024
+
+	kernelBTFPath = "/sys/kernel/btf/vmlinux"
+)
+
+// Bundle holds everything needed to report a failure to load an
+// instrumentor into the kernel.
+type Bundle struct {
+	Time          time.Time `json:"time"`
+	Library       string    `json:"library"`
+	Functions     []string  `json:"functions"`
+	GoVersion     string    `json:"go_version"`
+	KernelVersion string    `json:"kernel_version"`
+	BTFAvailable  bool      `json:"btf_available"`
+	Error         string    `json:"error"`
+	VerifierLog   []string  `json:"verifier_log,omitempty"`
+}
+
+// NewBundle collects diagnostic details about a failure to load the
+// instrumentor of library.
+func NewBundle(library string, funcs []string, target *process.TargetDetails, err error) *Bundle {
+	b := &Bundle{
+		Time:          time.Now(),
+		Library:       library,
+		Functions:     funcs,
+		KernelVersion: kernelVersion(),
+		BTFAvailable:  btfAvailable(),
+		Error:         err.Error(),
+	}
+
+	if target != nil && target.GoVersion != nil {
+		b.GoVersion = target.GoVersion.Original()
+	}
+
+	b.VerifierLog = verifierLog(err)
+
+	return b
+}
+
+// verifierLog returns the lines of the verifier log held by err. cilium/ebpf
+// appends the log to the errno of the failed program load, as
+// "<errno>: <log>", one instruction per line.
+func verifierLog(err error) []string {
+	for ; err != nil; err = errors.Unwrap(err) {
+		cause, ok := errors.Unwrap(err).(syscall.Errno)
+		if !ok {
+			continue
+		}
+
+		prefix := cause.Error() + ": "
+		if msg := err.Error(); strings.HasPrefix(msg, prefix) {
+			return strings.Split(strings.TrimRight(strings.TrimPrefix(msg, prefix), "\n"), "\n")
+		}
+	}
+
+	return nil
+}
+
+// WriteFile writes the bundle as JSON into dir and returns the path of the
+// created file.
+func (b *Bundle) WriteFile(dir string) (string, error) {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	name := fmt.Sprintf("%s-%d.json", strings.ReplaceAll(b.Library, "/", "_"), b.Time.Unix())
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+func btfAvailable() bool {
+	_, err := os.Stat(kernelBTFPath)
+	return err == nil
+}
@@ -0,0 +1,59 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diagnostics
+
+import (
+	"expvar"
+	"net"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/log"
+)
+
+const (
+	// AddrEnvVar is the address the diagnostics server listens on, serving
+	// the pprof profiles of the agent under /debug/pprof/, its expvar
+	// variables under /debug/vars and the recent traces of the target under
+	// /debug/traces. The server is disabled when it is not set.
+	AddrEnvVar = "OTEL_GO_AUTO_DIAGNOSTICS_ADDR"
+)
+
+// StartServer starts serving the runtime diagnostics of the agent on addr.
+func StartServer(addr string) (*http.Server, error) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/debug/traces", serveTraces)
+
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	server := &http.Server{Handler: mux}
+	go func() {
+		if err := server.Serve(lis); err != nil && err != http.ErrServerClosed {
+			log.Error(log.Component(log.ComponentDiagnostics), log.ErrDiagnostics, err, "diagnostics server failed", "addr", addr)
+		}
+	}()
+
+	log.Component(log.ComponentDiagnostics).V(0).Info("serving diagnostics", "addr", lis.Addr().String())
+	return server, nil
+}
@@ -0,0 +1,167 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diagnostics
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/log"
+)
+
+const (
+	// RecentTracesEnvVar is the number of completed traces of the target the
+	// diagnostics server shows under /debug/traces, 20 by default. No trace
+	// is kept with zero.
+	RecentTracesEnvVar = "OTEL_GO_AUTO_DIAGNOSTICS_RECENT_TRACES"
+
+	defaultRecentTraces = 20
+)
+
+// Trace is a completed trace of the target.
+type Trace struct {
+	TraceID string `json:"trace_id"`
+	// Spans are ordered depth first, children by start time.
+	Spans []Span `json:"spans"`
+}
+
+// Span is a span of a Trace.
+type Span struct {
+	SpanID       string            `json:"span_id"`
+	ParentSpanID string            `json:"parent_span_id,omitempty"`
+	Depth        int               `json:"depth"`
+	Name         string            `json:"name"`
+	Kind         string            `json:"kind"`
+	Start        time.Time         `json:"start"`
+	DurationMS   float64           `json:"duration_ms"`
+	Status       string            `json:"status"`
+	Attributes   map[string]string `json:"attributes,omitempty"`
+}
+
+// traceRing holds the most recent traces.
+type traceRing struct {
+	mu     sync.Mutex
+	traces []Trace
+	next   int
+	full   bool
+}
+
+var recentTraces traceRing
+
+// RecentTraces returns the number of traces to keep for /debug/traces, as
+// set by RecentTracesEnvVar.
+func RecentTraces() (int, error) {
+	val, exists := os.LookupEnv(RecentTracesEnvVar)
+	if !exists {
+		return defaultRecentTraces, nil
+	}
+
+	n, err := strconv.Atoi(val)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("unsupported %s value %q", RecentTracesEnvVar, val)
+	}
+
+	return n, nil
+}
+
+// KeepRecentTraces keeps the last n traces passed to RecordTrace, for
+// /debug/traces.
+func KeepRecentTraces(n int) {
+	recentTraces.mu.Lock()
+	defer recentTraces.mu.Unlock()
+	recentTraces.traces = make([]Trace, n)
+	recentTraces.next = 0
+	recentTraces.full = false
+}
+
+// RecordTrace records t as the most recent trace. Traces are only kept once
+// KeepRecentTraces is called.
+func RecordTrace(t Trace) {
+	recentTraces.mu.Lock()
+	defer recentTraces.mu.Unlock()
+	if len(recentTraces.traces) == 0 {
+		return
+	}
+
+	recentTraces.traces[recentTraces.next] = t
+	recentTraces.next = (recentTraces.next + 1) % len(recentTraces.traces)
+	if recentTraces.next == 0 {
+		recentTraces.full = true
+	}
+}
+
+// snapshot returns the kept traces, most recent first.
+func (r *traceRing) snapshot() []Trace {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	count := r.next
+	if r.full {
+		count = len(r.traces)
+	}
+
+	result := make([]Trace, 0, count)
+	for i := 1; i <= count; i++ {
+		result = append(result, r.traces[(r.next-i+len(r.traces))%len(r.traces)])
+	}
+
+	return result
+}
+
+var tracesPage = template.Must(template.New("traces").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Recent traces</title></head>
+<body>
+<h1>Recent traces</h1>
+{{range .}}<h2><code>{{.TraceID}}</code></h2>
+<table>
+<tr><th>Span</th><th>Kind</th><th>Duration (ms)</th><th>Status</th><th>Attributes</th></tr>
+{{range .Spans}}<tr>
+<td style="padding-left: {{.Depth}}em">{{.Name}}</td>
+<td>{{.Kind}}</td>
+<td>{{printf "%.3f" .DurationMS}}</td>
+<td>{{.Status}}</td>
+<td>{{range $k, $v := .Attributes}}<code>{{$k}}={{$v}}</code> {{end}}</td>
+</tr>
+{{end}}</table>
+{{else}}<p>No trace completed yet.</p>
+{{end}}</body>
+</html>
+`))
+
+// serveTraces serves the recent traces as JSON, or as an HTML page to
+// browsers and with ?format=html.
+func serveTraces(w http.ResponseWriter, r *http.Request) {
+	traces := recentTraces.snapshot()
+	format := r.URL.Query().Get("format")
+	if format == "html" || (format == "" && strings.Contains(r.Header.Get("Accept"), "text/html")) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := tracesPage.Execute(w, traces); err != nil {
+			log.Error(log.Component(log.ComponentDiagnostics), log.ErrDiagnostics, err, "could not render recent traces")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(traces); err != nil {
+		log.Error(log.Component(log.ComponentDiagnostics), log.ErrDiagnostics, err, "could not encode recent traces")
+	}
+}
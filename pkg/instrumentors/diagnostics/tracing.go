@@ -0,0 +1,117 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diagnostics
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/version"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// TracesEndpointEnvVar is the OTLP/gRPC endpoint the agent exports spans
+	// of its own operations (loading, attaching probes and exporting span
+	// batches) to. It is separate from the endpoint spans of the target are
+	// exported to. Self-diagnostics traces are disabled when it is not set.
+	TracesEndpointEnvVar = "OTEL_GO_AUTO_DIAGNOSTICS_TRACES_ENDPOINT"
+
+	selfServiceName = "opentelemetry-go-instrumentation"
+	tracerName      = "go.opentelemetry.io/auto/diagnostics"
+
+	tracesConnectTimeout = 10 * time.Second
+)
+
+// tracerHolder gives tracer a fixed concrete type, atomic.Value panics when
+// the type of the stored value changes.
+type tracerHolder struct {
+	t trace.Tracer
+}
+
+var tracer atomic.Value
+
+func init() {
+	tracer.Store(tracerHolder{t: trace.NewNoopTracerProvider().Tracer(tracerName)})
+}
+
+// StartTracing exports the spans of the agent operations to endpoint. The
+// returned provider must be shut down to flush the remaining spans.
+func StartTracing(ctx context.Context, endpoint string) (*sdktrace.TracerProvider, error) {
+	// Endpoints are dialed without TLS unless they use the https scheme.
+	var opts []otlptracegrpc.Option
+	if strings.HasPrefix(endpoint, "https://") {
+		endpoint = strings.TrimPrefix(endpoint, "https://")
+	} else {
+		endpoint = strings.TrimPrefix(endpoint, "http://")
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+	opts = append(opts, otlptracegrpc.WithEndpoint(endpoint))
+
+	timeoutContext, cancel := context.WithTimeout(ctx, tracesConnectTimeout)
+	defer cancel()
+	exporter, err := otlptracegrpc.New(timeoutContext, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithAttributes(
+			semconv.ServiceNameKey.String(selfServiceName),
+			semconv.ServiceVersionKey.String(version.Version()),
+		),
+		resource.WithSchemaURL(semconv.SchemaURL),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(sdktrace.AlwaysSample()),
+		sdktrace.WithResource(res),
+		sdktrace.WithBatcher(exporter),
+	)
+	tracer.Store(tracerHolder{t: tp.Tracer(tracerName, trace.WithInstrumentationVersion(version.Version()))})
+	return tp, nil
+}
+
+// StartOperation starts a span for an operation of the agent, as a child of
+// parent if it is not nil. Spans are dropped unless StartTracing was called.
+func StartOperation(parent trace.Span, name string, attrs ...attribute.KeyValue) trace.Span {
+	ctx := context.Background()
+	if parent != nil {
+		ctx = trace.ContextWithSpan(ctx, parent)
+	}
+
+	_, span := tracer.Load().(tracerHolder).t.Start(ctx, name, trace.WithAttributes(attrs...))
+	return span
+}
+
+// EndOperation ends span, marking it as failed if err is not nil.
+func EndOperation(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
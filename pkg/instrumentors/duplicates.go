@@ -0,0 +1,104 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package instrumentors
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/log"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/process"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// DuplicatesEnvVar configures how instrumentors overlapping with an
+	// OpenTelemetry instrumentation library linked in the target are handled:
+	// "mark" (the default) adds a duplicateOfKey attribute to their spans so
+	// a processor can drop them, "suppress" disables them and "keep" leaves
+	// them untouched.
+	DuplicatesEnvVar = "OTEL_GO_AUTO_DUPLICATE_INSTRUMENTATION"
+)
+
+type duplicatesMode int
+
+const (
+	duplicatesMark duplicatesMode = iota
+	duplicatesSuppress
+	duplicatesKeep
+)
+
+var duplicatesModes = map[string]duplicatesMode{
+	"mark":     duplicatesMark,
+	"suppress": duplicatesSuppress,
+	"keep":     duplicatesKeep,
+}
+
+// duplicateOfKey records the module path of the instrumentation library
+// already linked in the target that likely produces the same span.
+var duplicateOfKey = attribute.Key("telemetry.auto.duplicate_of")
+
+// overlappingInstrumentations maps the module path of OpenTelemetry
+// instrumentation libraries to the instrumentors producing the same spans.
+var overlappingInstrumentations = map[string][]string{
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp":               {"net/http"},
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc": {"google.golang.org/grpc", "google.golang.org/grpc/server"},
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux":  {"github.com/gorilla/mux"},
+}
+
+// parseDuplicatesMode returns the configured duplicates handling mode.
+func parseDuplicatesMode() (duplicatesMode, error) {
+	val, exists := os.LookupEnv(DuplicatesEnvVar)
+	if !exists {
+		return duplicatesMark, nil
+	}
+
+	mode, supported := duplicatesModes[val]
+	if !supported {
+		return 0, fmt.Errorf("unsupported %s value %q", DuplicatesEnvVar, val)
+	}
+
+	return mode, nil
+}
+
+// filterDuplicateInstrumentors detects instrumentors overlapping with
+// instrumentation libraries in the target dependencies and suppresses or
+// records them according to the configured mode.
+func (m *instrumentorsManager) filterDuplicateInstrumentors(target *process.TargetDetails) {
+	if m.duplicatesMode == duplicatesKeep {
+		return
+	}
+
+	for mod, libs := range overlappingInstrumentations {
+		if _, exists := target.Libraries[mod]; !exists {
+			continue
+		}
+
+		for _, name := range libs {
+			if _, exists := m.instrumentors[name]; !exists {
+				continue
+			}
+
+			if m.duplicatesMode == duplicatesSuppress {
+				log.Component(log.ComponentManager).V(0).Info("suppressing instrumentation already provided by target", "name", name, "instrumentation", mod)
+				delete(m.instrumentors, name)
+				continue
+			}
+
+			log.Component(log.ComponentManager).V(0).Info("marking spans of instrumentation already provided by target", "name", name, "instrumentation", mod)
+			m.duplicateOf[name] = mod
+		}
+	}
+}
@@ -21,6 +21,7 @@ import (
 
 type Event struct {
 	Library           string
+	LibraryVersion    string
 	Name              string
 	Attributes        []attribute.KeyValue
 	Kind              trace.SpanKind
@@ -28,4 +29,16 @@ type Event struct {
 	EndTime           int64
 	SpanContext       *trace.SpanContext
 	ParentSpanContext *trace.SpanContext
+	SpanEvents        []SpanEvent
+	StatusDescription string
+	// Goroutine is the address of the goroutine of the target the span was
+	// produced in, 0 if the probe does not record it.
+	Goroutine uint64
+}
+
+// SpanEvent is an event that occurred during the span.
+type SpanEvent struct {
+	Name       string
+	Time       int64
+	Attributes []attribute.KeyValue
 }
@@ -0,0 +1,67 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"bytes"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// TruncatedKey flags spans built from strings cut off at the size of the
+// buffer their probe reads them into.
+var TruncatedKey = attribute.Key("otel.truncated")
+
+// StringReader decodes the strings of a probe event, remembering whether
+// any of them filled its buffer. Probes copy at most the size of the buffer,
+// a string without a terminating NUL byte may have been longer.
+type StringReader struct {
+	truncated bool
+}
+
+// Read returns the NUL terminated string in b.
+func (r *StringReader) Read(b []byte) string {
+	return r.ReadLimited(b, len(b))
+}
+
+// ReadLimited returns the NUL terminated string in b, of which the probe
+// copied at most limit bytes.
+func (r *StringReader) ReadLimited(b []byte, limit int) string {
+	s, ok := r.TryReadLimited(b, limit)
+	if !ok {
+		r.truncated = true
+	}
+	return s
+}
+
+// TryReadLimited returns the NUL terminated string in b, of which the probe
+// copied at most limit bytes, and false if it may have been truncated. The
+// string is not flagged as truncated, callers are expected to discard it.
+func (r *StringReader) TryReadLimited(b []byte, limit int) (string, bool) {
+	s := b
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		s = b[:i]
+	}
+	return string(s), len(s) < limit
+}
+
+// Attributes returns the attributes flagging truncated strings, if any
+// string read so far filled its buffer.
+func (r *StringReader) Attributes() []attribute.KeyValue {
+	if !r.truncated {
+		return nil
+	}
+	return []attribute.KeyValue{TruncatedKey.Bool(true)}
+}
@@ -0,0 +1,55 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package instrumentors
+
+import (
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/inject"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/process"
+)
+
+// ModuleForks returns the forks of instrumented modules declared by the
+// probes, along with the layouts of the upstream modules they are checked
+// against.
+func ModuleForks() ([]*process.ModuleFork, error) {
+	var forks []*process.ModuleFork
+	for _, p := range Probes() {
+		for _, o := range p.Offsets {
+			if len(o.Forks) == 0 {
+				continue
+			}
+
+			shapes, err := inject.StructShapes(o.Module, o.Fields)
+			if err != nil {
+				return nil, err
+			}
+
+			fields := make([]process.StructField, 0, len(o.Fields))
+			for _, f := range o.Fields {
+				fields = append(fields, process.StructField{Struct: f.StructName, Field: f.Field})
+			}
+
+			for _, pattern := range o.Forks {
+				forks = append(forks, &process.ModuleFork{
+					Pattern:  pattern,
+					Original: o.Module,
+					Fields:   fields,
+					Shapes:   shapes,
+				})
+			}
+		}
+	}
+
+	return forks, nil
+}
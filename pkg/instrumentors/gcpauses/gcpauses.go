@@ -0,0 +1,242 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gcpauses records the stop-the-world pauses of the target runtime,
+// to annotate the server spans they overlap with span events. It helps
+// telling the latency of the application from the one of the runtime.
+package gcpauses
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+	"github.com/cilium/ebpf/perf"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/context"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/events"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/log"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/process"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// EventsEnvVar enables the span events of the pauses overlapping server
+	// spans when set to true. It probes every stop-the-world pause of the
+	// target, most of them are garbage collections.
+	EventsEnvVar = "OTEL_GO_AUTO_GC_PAUSE_EVENTS"
+
+	// EventName is the name of the span events of pauses.
+	EventName = "gc.pause"
+
+	stopTheWorldFuncName  = "runtime.stopTheWorldWithSema"
+	startTheWorldFuncName = "runtime.startTheWorldWithSema"
+
+	// maxPauses is the number of recent pauses spans are annotated with.
+	maxPauses = 256
+)
+
+// FuncNames are the runtime functions recording pauses attach to.
+var FuncNames = []string{stopTheWorldFuncName, startTheWorldFuncName}
+
+// durationKey holds the duration of a pause in nanoseconds.
+var durationKey = attribute.Key("telemetry.auto.gc.pause.duration")
+
+// Programs record pauses, they are shared by the probes of servers through
+// gc_pauses.h.
+type Programs struct {
+	StopTheWorld  *ebpf.Program
+	StartTheWorld *ebpf.Program
+	Events        *ebpf.Map
+}
+
+// pause mirrors struct gc_pause_t of gc_pauses.h.
+type pause struct {
+	StartTime uint64
+	EndTime   uint64
+}
+
+var (
+	mu       sync.Mutex
+	attached bool
+	pauses   [maxPauses]pause
+	next     int
+)
+
+// enabled reports whether pause span events are turned on by EventsEnvVar.
+func enabled() (bool, error) {
+	val, exists := os.LookupEnv(EventsEnvVar)
+	if !exists {
+		return false, nil
+	}
+
+	enabled, err := strconv.ParseBool(val)
+	if err != nil {
+		return false, fmt.Errorf("unsupported %s value %q", EventsEnvVar, val)
+	}
+
+	return enabled, nil
+}
+
+// OptionalFuncNames returns the functions probes resolve in the target to
+// record pauses, none unless pause span events are turned on.
+func OptionalFuncNames() []string {
+	if on, err := enabled(); err != nil || !on {
+		return nil
+	}
+
+	return FuncNames
+}
+
+// Enabled reports whether pause span events are enabled for target.
+func Enabled(target *process.TargetDetails) (bool, error) {
+	enabled, err := enabled()
+	if err != nil || !enabled {
+		return false, err
+	}
+
+	if !target.HasFunctions(FuncNames...) {
+		log.Component(log.ComponentProbe).V(0).Info("GC pause functions not found in target, disabling pause events",
+			"functions", FuncNames)
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// Recorder reads the pauses of the target until closed.
+type Recorder struct {
+	links  []link.Link
+	reader *perf.Reader
+}
+
+// Attach attaches progs to the runtime functions of the target and records
+// the pauses they report. Pauses are recorded once per target, it returns
+// nil if the programs of another probe are already attached.
+func Attach(ctx *context.InstrumentorContext, progs Programs) (*Recorder, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if attached {
+		return nil, nil
+	}
+
+	r := &Recorder{}
+	for funcName, prog := range map[string]*ebpf.Program{
+		stopTheWorldFuncName:  progs.StopTheWorld,
+		startTheWorldFuncName: progs.StartTheWorld,
+	} {
+		offset, err := ctx.TargetDetails.GetFunctionOffset(funcName)
+		if err != nil {
+			r.closeLinks()
+			return nil, err
+		}
+
+		l, err := ctx.ExecutableFor(funcName).Uprobe("", prog, &link.UprobeOptions{Offset: offset})
+		if err != nil {
+			r.closeLinks()
+			return nil, err
+		}
+		r.links = append(r.links, l)
+	}
+
+	rd, err := perf.NewReader(progs.Events, os.Getpagesize())
+	if err != nil {
+		r.closeLinks()
+		return nil, err
+	}
+	r.reader = rd
+
+	attached = true
+	go r.run()
+	return r, nil
+}
+
+func (r *Recorder) run() {
+	logger := log.Component(log.ComponentProbe)
+	for {
+		record, err := r.reader.Read()
+		if err != nil {
+			if errors.Is(err, perf.ErrClosed) {
+				return
+			}
+			log.Error(logger, log.ErrProbeRead, err, "error reading from gc pauses perf reader")
+			continue
+		}
+
+		if record.LostSamples != 0 {
+			logger.V(0).Info("gc pauses perf event ring buffer full", "dropped", record.LostSamples)
+			continue
+		}
+
+		var p pause
+		if err := binary.Read(bytes.NewBuffer(record.RawSample), binary.LittleEndian, &p); err != nil {
+			log.Error(logger, log.ErrEventDecode, err, "error parsing gc pause event")
+			continue
+		}
+
+		mu.Lock()
+		pauses[next] = p
+		next = (next + 1) % maxPauses
+		mu.Unlock()
+	}
+}
+
+func (r *Recorder) closeLinks() {
+	for _, l := range r.links {
+		l.Close()
+	}
+}
+
+// Close stops recording pauses.
+func (r *Recorder) Close() {
+	r.closeLinks()
+	if r.reader != nil {
+		r.reader.Close()
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	attached = false
+	pauses = [maxPauses]pause{}
+	next = 0
+}
+
+// Annotate adds a span event to e for each recorded pause overlapping it.
+// Pauses are read concurrently with spans, a pause read after the span it
+// overlaps is not reported.
+func Annotate(e *events.Event) {
+	mu.Lock()
+	defer mu.Unlock()
+	if !attached {
+		return
+	}
+
+	for i := 0; i < maxPauses; i++ {
+		p := pauses[(next+i)%maxPauses]
+		if p.EndTime == 0 || int64(p.EndTime) < e.StartTime || int64(p.StartTime) > e.EndTime {
+			continue
+		}
+
+		e.SpanEvents = append(e.SpanEvents, events.SpanEvent{
+			Name:       EventName,
+			Time:       int64(p.StartTime),
+			Attributes: []attribute.KeyValue{durationKey.Int64(int64(p.EndTime - p.StartTime))},
+		})
+	}
+}
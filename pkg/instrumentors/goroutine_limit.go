@@ -0,0 +1,108 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package instrumentors
+
+import (
+	"expvar"
+	"fmt"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/events"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/log"
+)
+
+const (
+	// GoroutineSpanLimitEnvVar is the maximum number of spans a goroutine of
+	// the target may produce per second. Further spans of the goroutine are
+	// dropped until the next second, protecting the pipeline from runaway
+	// loops such as tight retry loops. Spans are not limited when it is not
+	// set or 0. Only the spans of probes recording their goroutine, with the
+	// register based ABI of Go 1.17, are limited.
+	GoroutineSpanLimitEnvVar = "OTEL_GO_AUTO_MAX_SPANS_PER_GOROUTINE"
+
+	// goroutineLimitMetric is the name of the self-metric reported when a
+	// goroutine reaches the limit.
+	goroutineLimitMetric = "otelauto.goroutine_span_limit.engaged"
+)
+
+// goroutineLimitedEvents publishes the events dropped by the span limit per
+// goroutine under /debug/vars of the diagnostics server.
+var goroutineLimitedEvents = expvar.NewInt("goroutine_limited_events")
+
+// parseGoroutineSpanLimit returns the configured span limit per goroutine
+// per second, 0 if spans are not limited.
+func parseGoroutineSpanLimit() (uint64, error) {
+	val, exists := os.LookupEnv(GoroutineSpanLimitEnvVar)
+	if !exists {
+		return 0, nil
+	}
+
+	limit, err := strconv.ParseUint(val, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unsupported %s value %q", GoroutineSpanLimitEnvVar, val)
+	}
+
+	return limit, nil
+}
+
+// goroutineLimiter drops the events of goroutines producing more than limit
+// spans in the current second.
+type goroutineLimiter struct {
+	limit  uint64
+	second int64
+	counts map[uint64]uint64
+
+	// droppedTotal counts the dropped events.
+	droppedTotal uint64
+}
+
+func newGoroutineLimiter(limit uint64) *goroutineLimiter {
+	return &goroutineLimiter{
+		limit:  limit,
+		counts: make(map[uint64]uint64),
+	}
+}
+
+// isOverLimit reports whether the goroutine of e already produced limit
+// spans in the second of now. It is not safe for concurrent use.
+func (l *goroutineLimiter) isOverLimit(e *events.Event, now time.Time) bool {
+	if l.limit == 0 || e.Goroutine == 0 {
+		return false
+	}
+
+	if second := now.Unix(); second != l.second {
+		l.second = second
+		l.counts = make(map[uint64]uint64, len(l.counts))
+	}
+
+	l.counts[e.Goroutine]++
+	count := l.counts[e.Goroutine]
+	if count <= l.limit {
+		return false
+	}
+
+	total := atomic.AddUint64(&l.droppedTotal, 1)
+	goroutineLimitedEvents.Add(1)
+	if count == l.limit+1 {
+		log.Component(log.ComponentManager).V(0).Info("goroutine reached the span limit, dropping its spans for the rest of the second",
+			"metric", goroutineLimitMetric, "library", e.Library, "goroutine", fmt.Sprintf("%#x", e.Goroutine),
+			"limit", l.limit, "dropped_total", total)
+	}
+
+	return true
+}
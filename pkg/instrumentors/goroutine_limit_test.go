@@ -0,0 +1,96 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package instrumentors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/events"
+)
+
+func TestGoroutineLimiterIsOverLimit(t *testing.T) {
+	start := time.Unix(1000, 0)
+	type call struct {
+		goroutine uint64
+		now       time.Time
+		want      bool
+	}
+	tests := []struct {
+		name        string
+		limit       uint64
+		calls       []call
+		wantDropped uint64
+	}{
+		{
+			name:  "not limited",
+			limit: 0,
+			calls: []call{
+				{goroutine: 1, now: start},
+				{goroutine: 1, now: start},
+				{goroutine: 1, now: start},
+			},
+		},
+		{
+			name:  "goroutine not recorded",
+			limit: 1,
+			calls: []call{
+				{goroutine: 0, now: start},
+				{goroutine: 0, now: start},
+			},
+		},
+		{
+			name:  "limited per goroutine",
+			limit: 2,
+			calls: []call{
+				{goroutine: 1, now: start},
+				{goroutine: 1, now: start},
+				{goroutine: 2, now: start},
+				{goroutine: 1, now: start, want: true},
+				{goroutine: 2, now: start},
+				{goroutine: 1, now: start.Add(999 * time.Millisecond), want: true},
+			},
+			wantDropped: 2,
+		},
+		{
+			name:  "reset every second",
+			limit: 1,
+			calls: []call{
+				{goroutine: 1, now: start},
+				{goroutine: 1, now: start.Add(500 * time.Millisecond), want: true},
+				{goroutine: 1, now: start.Add(time.Second)},
+				{goroutine: 1, now: start.Add(1500 * time.Millisecond), want: true},
+				{goroutine: 1, now: start.Add(3 * time.Second)},
+			},
+			wantDropped: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newGoroutineLimiter(tt.limit)
+			for i, c := range tt.calls {
+				e := &events.Event{Library: "net/http", Goroutine: c.goroutine}
+				if got := l.isOverLimit(e, c.now); got != c.want {
+					t.Errorf("call %d: isOverLimit() = %t, want %t", i, got, c.want)
+				}
+			}
+
+			if l.droppedTotal != tt.wantDropped {
+				t.Errorf("dropped %d events, want %d", l.droppedTotal, tt.wantDropped)
+			}
+		})
+	}
+}
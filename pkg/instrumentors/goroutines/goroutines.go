@@ -0,0 +1,178 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package goroutines propagates the span of instrumented request handlers to
+// the goroutines they spawn, so spans created in those goroutines without
+// the request context still belong to the request trace.
+package goroutines
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+	"github.com/hashicorp/go-version"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/context"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/log"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/process"
+)
+
+const (
+	// DepthEnvVar is the number of goroutine generations inheriting the span
+	// of the request handler they were spawned from: 1 covers goroutines
+	// spawned by the handler, 2 also the goroutines they spawn, and so on.
+	// Propagation is disabled when it is not set or 0, as it probes every
+	// goroutine creation and exit in the target.
+	DepthEnvVar = "OTEL_GO_AUTO_GOROUTINE_PROPAGATION_DEPTH"
+
+	newprocFuncName = "runtime.newproc1"
+	goexitFuncName  = "runtime.goexit1"
+)
+
+// FuncNames are the runtime functions tracking goroutines attach to.
+var FuncNames = []string{newprocFuncName, goexitFuncName}
+
+// Programs track goroutines, they are shared by the probes of request
+// handlers through goroutines.h.
+type Programs struct {
+	Newproc1        *ebpf.Program
+	Newproc1Returns *ebpf.Program
+	Goexit1         *ebpf.Program
+}
+
+var (
+	mu       sync.Mutex
+	attached bool
+)
+
+// depth returns the propagation depth set by DepthEnvVar, 0 if propagation
+// is disabled.
+func depth() (uint64, error) {
+	val, exists := os.LookupEnv(DepthEnvVar)
+	if !exists {
+		return 0, nil
+	}
+
+	depth, err := strconv.ParseUint(val, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unsupported %s value %q", DepthEnvVar, val)
+	}
+
+	return depth, nil
+}
+
+// OptionalFuncNames returns the functions probes resolve in the target to
+// track goroutines, none unless propagation is turned on.
+func OptionalFuncNames() []string {
+	if d, err := depth(); err != nil || d == 0 {
+		return nil
+	}
+
+	return FuncNames
+}
+
+// Depth returns the configured propagation depth, 0 if propagation is
+// disabled. Tracking goroutines requires the register based ABI of Go 1.17.
+func Depth(target *process.TargetDetails) (uint64, error) {
+	depth, err := depth()
+	if err != nil || depth == 0 {
+		return 0, err
+	}
+
+	if !target.IsRegistersABI() {
+		log.Component(log.ComponentProbe).V(0).Info("goroutine propagation requires Go 1.17 or newer, disabling it",
+			"go_version", target.GoVersion.Original())
+		return 0, nil
+	}
+
+	if !target.HasFunctions(FuncNames...) {
+		log.Component(log.ComponentProbe).V(0).Info("goroutine functions not found in target, disabling propagation",
+			"functions", FuncNames)
+		return 0, nil
+	}
+
+	return depth, nil
+}
+
+// Constants returns the constants goroutines.h is injected with.
+func Constants(target *process.TargetDetails, depth uint64) map[string]interface{} {
+	// newproc1 takes the function arguments before the caller goroutine
+	// until Go 1.18.
+	noArgsVersion, _ := version.NewVersion("1.18")
+
+	return map[string]interface{}{
+		"max_goroutine_depth": depth,
+		"newproc1_takes_args": target.GoVersion.LessThan(noArgsVersion),
+	}
+}
+
+// Attach attaches progs to the runtime functions of the target. Goroutines
+// are tracked once per target, it returns no links if the programs of
+// another probe are already attached.
+func Attach(ctx *context.InstrumentorContext, progs Programs) ([]link.Link, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if attached {
+		return nil, nil
+	}
+
+	var links []link.Link
+	closeAll := func() {
+		for _, l := range links {
+			l.Close()
+		}
+	}
+
+	newprocOffset, err := ctx.TargetDetails.GetFunctionOffset(newprocFuncName)
+	if err != nil {
+		return nil, err
+	}
+	l, err := ctx.Executable.Uprobe("", progs.Newproc1, &link.UprobeOptions{Offset: newprocOffset})
+	if err != nil {
+		return nil, err
+	}
+	links = append(links, l)
+
+	retOffsets, err := ctx.TargetDetails.GetFunctionReturns(newprocFuncName)
+	if err != nil {
+		closeAll()
+		return nil, err
+	}
+	for _, ret := range retOffsets {
+		l, err := ctx.Executable.Uprobe("", progs.Newproc1Returns, &link.UprobeOptions{Offset: ret})
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		links = append(links, l)
+	}
+
+	goexitOffset, err := ctx.TargetDetails.GetFunctionOffset(goexitFuncName)
+	if err != nil {
+		closeAll()
+		return nil, err
+	}
+	l, err = ctx.Executable.Uprobe("", progs.Goexit1, &link.UprobeOptions{Offset: goexitOffset})
+	if err != nil {
+		closeAll()
+		return nil, err
+	}
+	links = append(links, l)
+
+	attached = true
+	return links, nil
+}
@@ -0,0 +1,59 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package instrumentors
+
+import (
+	"strconv"
+
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/log"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/process"
+)
+
+// moduleInstance is the module instance an instrumentor is loaded for.
+type moduleInstance struct {
+	target *process.TargetDetails
+	// number is empty for the first instance.
+	number string
+}
+
+// expandModuleInstances registers an instrumentor per module instance
+// providing its functions, e.g. when the target links both a module and a
+// fork aliased to it, so each instance is attached with its own offsets.
+func (m *instrumentorsManager) expandModuleInstances(target *process.TargetDetails) {
+	m.moduleInstances = make(map[string]*moduleInstance)
+	insts := make(map[string]Instrumentor, len(m.instrumentors))
+	for name, inst := range m.instrumentors {
+		insts[name] = inst
+	}
+
+	for name, inst := range insts {
+		views := target.Instances(inst.FuncNames())
+		if len(views) == 1 && views[0] == target {
+			continue
+		}
+
+		for n, view := range views {
+			key, number := name, ""
+			if n > 0 {
+				number = strconv.Itoa(n + 1)
+				key = name + "#" + number
+				m.instrumentors[key] = m.factories[name]()
+			}
+
+			log.Component(log.ComponentManager).V(0).Info("instrumenting module instance", "name", key)
+			m.moduleInstances[key] = &moduleInstance{target: view, number: number}
+		}
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package instrumentors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/log"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/opentelemetry"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/process"
+)
+
+// runShutdownTimeout bounds the shutdown of Run.
+const runShutdownTimeout = 10 * time.Second
+
+// Load loads the probes of the instrumentors into target. Their events are
+// exported with otelController once Start is called. Loading is abandoned
+// when ctx is done. Shutdown must be called once the manager is no longer
+// used, even if Load fails.
+func (m *instrumentorsManager) Load(ctx context.Context, target *process.TargetDetails, otelController *opentelemetry.Controller) error {
+	m.otelController = otelController
+	if len(m.instrumentors) == 0 {
+		log.Component(log.ComponentManager).V(0).Info("there are no avilable instrumentations for target process")
+		return nil
+	}
+
+	if err := m.load(target, ctx.Done()); err != nil {
+		return err
+	}
+	m.loadedTarget = target
+	return nil
+}
+
+// Start starts handling the events of the probes loaded by Load, and returns.
+// Events are handled until Shutdown is called.
+func (m *instrumentorsManager) Start() {
+	if m.loadedTarget == nil || m.loopDone != nil {
+		return
+	}
+
+	for _, i := range m.instrumentors {
+		m.running.Add(1)
+		go func(i Instrumentor) {
+			defer m.running.Done()
+			i.Run(m.incomingEvents)
+		}(i)
+	}
+
+	m.loopDone = make(chan struct{})
+	go m.handleEvents(m.loadedTarget)
+}
+
+// Wait blocks until Close is called or, with SetStopOnTargetExit, until the
+// target exited and the events it produced were handled. It returns at once
+// if the manager was not started.
+func (m *instrumentorsManager) Wait() {
+	if m.loopDone == nil {
+		return
+	}
+
+	select {
+	case <-m.done:
+		log.Component(log.ComponentManager).V(0).Info("shutting down all instrumentors due to signal")
+	case <-m.targetGone:
+		log.Component(log.ComponentManager).V(0).Info("shutting down all instrumentors after target exit")
+	}
+}
+
+// Shutdown detaches the probes, handles the events they already produced,
+// flushes the OpenTelemetry controller and releases the target, in that
+// order. Every step is run, the errors of all of them are returned joined.
+// Waiting for the remaining events is abandoned when ctx is done. It must be
+// called once.
+func (m *instrumentorsManager) Shutdown(ctx context.Context) error {
+	var errs []error
+	if m.loadedTarget != nil {
+		m.closeInstrumentors()
+		if m.loopDone != nil {
+			if err := m.drainEvents(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("draining events: %w", err))
+			}
+		}
+		if err := m.release(); err != nil {
+			errs = append(errs, fmt.Errorf("releasing target: %w", err))
+		}
+		m.loadedTarget = nil
+	}
+
+	if m.otelController != nil {
+		if err := m.otelController.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("flushing telemetry: %w", err))
+		}
+	}
+
+	return joinErrors(errs)
+}
+
+// drainEvents waits for the instrumentors to stop sending events, then for
+// the events already sent to be handled.
+func (m *instrumentorsManager) drainEvents(ctx context.Context) error {
+	stopped := make(chan struct{})
+	go func() {
+		m.running.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		// The instrumentors may still send events, incomingEvents is left
+		// open for them not to panic.
+		return ctx.Err()
+	}
+
+	close(m.incomingEvents)
+	select {
+	case <-m.loopDone:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Run loads the probes into target and handles their events until Close is
+// called or, with SetStopOnTargetExit, the target exits. It then shuts the
+// manager down, otelController included.
+func (m *instrumentorsManager) Run(target *process.TargetDetails, otelController *opentelemetry.Controller) error {
+	err := m.Load(context.Background(), target, otelController)
+	if err == nil {
+		m.Start()
+		m.Wait()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), runShutdownTimeout)
+	defer cancel()
+	return joinErrors([]error{err, m.Shutdown(ctx)})
+}
+
+// joinedErrors holds the errors of several steps that all ran.
+type joinedErrors []error
+
+func (e joinedErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// Is reports whether any of the joined errors is target.
+func (e joinedErrors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// joinErrors returns the non nil errors of errs joined, nil if there are
+// none, the error itself if there is only one.
+func joinErrors(errs []error) error {
+	var result joinedErrors
+	for _, err := range errs {
+		if err != nil {
+			result = append(result, err)
+		}
+	}
+
+	switch len(result) {
+	case 0:
+		return nil
+	case 1:
+		return result[0]
+	}
+
+	return result
+}
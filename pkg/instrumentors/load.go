@@ -0,0 +1,178 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package instrumentors
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/errors"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/context"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/diagnostics"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/registry"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/log"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/process"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// libraryKey records the instrumentor loaded by self-diagnostics spans.
+var libraryKey = attribute.Key("telemetry.auto.library")
+
+// LoadOptions bounds loading the instrumentors into the target, which
+// attaches a uprobe per instrumented function and return instruction.
+type LoadOptions struct {
+	// Concurrency is the number of instrumentors attaching their uprobes at
+	// the same time. Instrumentors are loaded one at a time if it is zero.
+	Concurrency int
+	// Timeout bounds loading all instrumentors, Load fails with
+	// errors.ErrLoadTimeout once it expires. Instrumentors already loading
+	// are waited for before cleaning up. Zero means no timeout.
+	Timeout time.Duration
+	// Progress, if set, is called each time an instrumentor is done loading.
+	Progress func(LoadProgress)
+}
+
+// LoadProgress reports an instrumentor done loading.
+type LoadProgress struct {
+	Library string
+	// Err is the error loading the instrumentor, if any.
+	Err     error
+	Loaded  int
+	Total   int
+	Elapsed time.Duration
+}
+
+type loadResult struct {
+	inst Instrumentor
+	err  error
+}
+
+// SetLoadOptions configures how instrumentors are loaded. It must be called
+// before Load.
+func (m *instrumentorsManager) SetLoadOptions(opts LoadOptions) {
+	m.loadOptions = opts
+}
+
+func (m *instrumentorsManager) loadInstrumentors(ctx *context.InstrumentorContext, span trace.Span, cancel <-chan struct{}) error {
+	concurrency := m.loadOptions.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	var timeout <-chan time.Time
+	if m.loadOptions.Timeout > 0 {
+		timer := time.NewTimer(m.loadOptions.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	total := len(m.instrumentors)
+	results := make(chan loadResult, total)
+	slots := make(chan struct{}, concurrency)
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for name, i := range m.instrumentors {
+		wg.Add(1)
+		go func(name string, i Instrumentor) {
+			defer wg.Done()
+			select {
+			case slots <- struct{}{}:
+			case <-stop:
+				return
+			}
+			defer func() { <-slots }()
+
+			select {
+			case <-stop:
+				return
+			default:
+			}
+
+			instCtx := ctx
+			if instance, exists := m.moduleInstances[name]; exists {
+				c := *ctx
+				c.TargetDetails = instance.target
+				c.Instance = instance.number
+				instCtx = &c
+			}
+
+			variant, err := selectVariant(i.LibraryName(), instCtx.TargetDetails)
+			if err != nil {
+				results <- loadResult{inst: i, err: err}
+				return
+			}
+			if variant != nil {
+				c := *instCtx
+				c.Variant = variant
+				instCtx = &c
+				log.Component(log.ComponentManager).V(0).Info("selected probe variant", "name", name, "constraints", variant.Constraints)
+			}
+
+			log.Component(log.ComponentManager).V(0).Info("loading instrumentor", "name", name)
+			attachSpan := diagnostics.StartOperation(span, "attach", libraryKey.String(name))
+			err = i.Load(instCtx)
+			diagnostics.EndOperation(attachSpan, err)
+			results <- loadResult{inst: i, err: err}
+		}(name, i)
+	}
+
+	start := time.Now()
+	var err error
+	for loaded := 0; loaded < total && err == nil; {
+		select {
+		case r := <-results:
+			loaded++
+			if m.loadOptions.Progress != nil {
+				m.loadOptions.Progress(LoadProgress{
+					Library: r.inst.LibraryName(),
+					Err:     r.err,
+					Loaded:  loaded,
+					Total:   total,
+					Elapsed: time.Since(start),
+				})
+			}
+
+			if r.err != nil {
+				log.Error(log.Component(log.ComponentManager), log.ErrProbeLoad, r.err, "error while loading instrumentors, cleaning up", "name", r.inst.LibraryName())
+				m.reportLoadFailure(r.inst, ctx.TargetDetails, r.err)
+				err = r.err
+			}
+		case <-timeout:
+			err = fmt.Errorf("%w: %d of %d loaded after %s", errors.ErrLoadTimeout, loaded, total, m.loadOptions.Timeout)
+			log.Error(log.Component(log.ComponentManager), log.ErrProbeLoad, err, "error while loading instrumentors, cleaning up")
+		case <-cancel:
+			err = errors.ErrInterrupted
+			log.Component(log.ComponentManager).V(0).Info("loading instrumentors interrupted, cleaning up", "loaded", loaded, "total", total)
+		}
+	}
+
+	close(stop)
+	wg.Wait()
+	return err
+}
+
+// selectVariant returns the variant of the probe of library for target, nil
+// if the probe has no variants.
+func selectVariant(library string, target *process.TargetDetails) (*registry.Variant, error) {
+	for _, p := range Probes() {
+		if p.ID == library {
+			return p.SelectVariant(target)
+		}
+	}
+
+	return nil, nil
+}
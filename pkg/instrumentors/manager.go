@@ -16,7 +16,10 @@ package instrumentors
 
 import (
 	"fmt"
+	"sync"
+	"sync/atomic"
 
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/inject"
 	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/allocator"
 	gorillaMux "github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/bpf/github.com/gorilla/mux"
 	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/bpf/google/golang/org/grpc"
@@ -34,18 +37,67 @@ type instrumentorsManager struct {
 	incomingEvents chan *events.Event
 	otelController *opentelemetry.Controller
 	allocator      *allocator.Allocator
+	watchdog       *probeWatchdog
+	dedup          *eventDeduplicator
+	limiter        *goroutineLimiter
+	target         *process.TargetDetails
+	injector       *inject.Injector
+	eventHandler   RawEventHandler
+	duplicatesMode duplicatesMode
+	duplicateOf    map[string]string
+	ownership      *targetOwnership
+	pinPath        string
+	loadOptions    LoadOptions
+	// stopOnTargetExit makes Wait return once the target exits.
+	stopOnTargetExit bool
+	factories        map[string]func() Instrumentor
+	// moduleInstances holds the instrumentors loaded once per module
+	// instance, keyed like instrumentors.
+	moduleInstances map[string]*moduleInstance
+	// loadedTarget is the target the probes are loaded into, nil until Load
+	// succeeds and after Shutdown.
+	loadedTarget *process.TargetDetails
+	// running tracks the Run goroutines of the instrumentors.
+	running sync.WaitGroup
+	// loopDone is closed once the events are handled, nil until Start.
+	loopDone chan struct{}
+	// targetGone is closed once the target exited and its events were read.
+	targetGone chan struct{}
 }
 
-func NewManager(otelController *opentelemetry.Controller) (*instrumentorsManager, error) {
+// RawEventHandler receives the events decoded from the probes of library.
+type RawEventHandler func(library string, event *events.Event)
+
+func NewManager() (*instrumentorsManager, error) {
+	if err := checkOS(); err != nil {
+		return nil, err
+	}
+
+	duplicates, err := parseDuplicatesMode()
+	if err != nil {
+		return nil, err
+	}
+
+	goroutineSpanLimit, err := parseGoroutineSpanLimit()
+	if err != nil {
+		return nil, err
+	}
+
 	m := &instrumentorsManager{
 		instrumentors:  make(map[string]Instrumentor),
 		done:           make(chan bool, 1),
+		targetGone:     make(chan struct{}),
 		incomingEvents: make(chan *events.Event),
-		otelController: otelController,
 		allocator:      allocator.New(),
+		watchdog:       newProbeWatchdog(),
+		dedup:          newEventDeduplicator(),
+		limiter:        newGoroutineLimiter(goroutineSpanLimit),
+		duplicatesMode: duplicates,
+		duplicateOf:    make(map[string]string),
+		factories:      make(map[string]func() Instrumentor),
 	}
 
-	err := registerInstrumentors(m)
+	err = registerInstrumentors(m)
 	if err != nil {
 		return nil, err
 	}
@@ -62,18 +114,64 @@ func (m *instrumentorsManager) registerInstrumentor(instrumentor Instrumentor) e
 	return nil
 }
 
+// SetRawEventHandler registers h to receive every decoded probe event, in
+// addition to the span pipeline. Load with a nil controller to only deliver
+// events to h. It must be called before Start.
+func (m *instrumentorsManager) SetRawEventHandler(h RawEventHandler) {
+	m.eventHandler = h
+}
+
+// ProbeHealthWarnings returns a channel reporting instrumentors that went
+// silent while the target process is still active.
+func (m *instrumentorsManager) ProbeHealthWarnings() <-chan ProbeHealthWarning {
+	return m.watchdog.warnings
+}
+
+// DuplicateEventsTotal returns the number of duplicate probe events dropped
+// so far.
+func (m *instrumentorsManager) DuplicateEventsTotal() uint64 {
+	return atomic.LoadUint64(&m.dedup.duplicatesTotal)
+}
+
+// GoroutineLimitedEventsTotal returns the number of probe events dropped so
+// far because their goroutine reached the span limit.
+func (m *instrumentorsManager) GoroutineLimitedEventsTotal() uint64 {
+	return atomic.LoadUint64(&m.limiter.droppedTotal)
+}
+
+// SilentProbesTotal returns the number of probe health warnings raised so far.
+func (m *instrumentorsManager) SilentProbesTotal() uint64 {
+	return atomic.LoadUint64(&m.watchdog.silentProbesTotal)
+}
+
+// UnsupportedLibraries returns the instrumented libraries found in the target
+// at a version without tracked offsets, mapped to that version.
+func (m *instrumentorsManager) UnsupportedLibraries() map[string]string {
+	if m.injector == nil {
+		return nil
+	}
+
+	return m.injector.UnsupportedLibraries()
+}
+
 func (m *instrumentorsManager) GetRelevantFuncs() map[string]interface{} {
 	funcsMap := make(map[string]interface{})
 	for _, i := range m.instrumentors {
 		for _, f := range i.FuncNames() {
 			funcsMap[f] = nil
 		}
+		if o, ok := i.(OptionalFuncsInstrumentor); ok {
+			for _, f := range o.OptionalFuncNames() {
+				funcsMap[f] = nil
+			}
+		}
 	}
 
 	return funcsMap
 }
 
 func (m *instrumentorsManager) FilterUnusedInstrumentors(target *process.TargetDetails) {
+	m.target = target
 	existingFuncMap := make(map[string]interface{})
 	for _, f := range target.Functions {
 		existingFuncMap[f.Name] = nil
@@ -89,25 +187,30 @@ func (m *instrumentorsManager) FilterUnusedInstrumentors(target *process.TargetD
 		}
 
 		if !allFuncExists {
-			log.Logger.V(1).Info("filtering unused instrumentation", "name", name)
+			log.Component(log.ComponentManager).V(1).Info("filtering unused instrumentation", "name", name)
 			delete(m.instrumentors, name)
 		}
 	}
+
+	m.filterDuplicateInstrumentors(target)
+	m.expandModuleInstances(target)
 }
 
 func registerInstrumentors(m *instrumentorsManager) error {
-	insts := []Instrumentor{
-		grpc.New(),
-		grpcServer.New(),
-		httpServer.New(),
-		gorillaMux.New(),
+	factories := []func() Instrumentor{
+		func() Instrumentor { return grpc.New() },
+		func() Instrumentor { return grpcServer.New() },
+		func() Instrumentor { return httpServer.New() },
+		func() Instrumentor { return gorillaMux.New() },
 	}
 
-	for _, i := range insts {
+	for _, f := range factories {
+		i := f()
 		err := m.registerInstrumentor(i)
 		if err != nil {
 			return err
 		}
+		m.factories[i.LibraryName()] = f
 	}
 
 	return nil
@@ -0,0 +1,296 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mutexwaits records the time the goroutines of request handlers
+// spend waiting for contended sync.Mutex locks, to annotate the spans of the
+// handlers with span events for the long waits.
+package mutexwaits
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+	"github.com/cilium/ebpf/perf"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/context"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/events"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/log"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/process"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// ThresholdEnvVar is the minimum duration, such as 10ms, of the mutex
+	// waits reported as span events. Waits are not recorded when it is not
+	// set, as it probes every contended lock of the target.
+	ThresholdEnvVar = "OTEL_GO_AUTO_MUTEX_WAIT_THRESHOLD"
+
+	// EventName is the name of the span events of mutex waits.
+	EventName = "mutex.wait"
+
+	lockSlowFuncName = "sync.(*Mutex).lockSlow"
+
+	// maxPendingSpans is the number of spans waits are kept for until the
+	// span ends.
+	maxPendingSpans = 1000
+)
+
+// FuncNames are the functions recording mutex waits attach to.
+var FuncNames = []string{lockSlowFuncName}
+
+// durationKey holds the duration of a wait in nanoseconds.
+var durationKey = attribute.Key("telemetry.auto.mutex.wait.duration")
+
+// Programs record mutex waits, they are shared by the probes of request
+// handlers through mutex_waits.h.
+type Programs struct {
+	LockSlow        *ebpf.Program
+	LockSlowReturns *ebpf.Program
+	Events          *ebpf.Map
+}
+
+// wait mirrors struct mutex_wait_t of mutex_waits.h.
+type wait struct {
+	SpanContext context.EbpfSpanContext
+	StartTime   uint64
+	EndTime     uint64
+}
+
+var (
+	mu       sync.Mutex
+	attached bool
+	pending  = make(map[trace.SpanID][]events.SpanEvent)
+	// order holds the spans of pending in the order of their first wait,
+	// including spans already annotated.
+	order []trace.SpanID
+)
+
+// threshold returns the minimum duration of reported waits set by
+// ThresholdEnvVar, 0 if waits are not recorded.
+func threshold() (time.Duration, error) {
+	val, exists := os.LookupEnv(ThresholdEnvVar)
+	if !exists {
+		return 0, nil
+	}
+
+	threshold, err := time.ParseDuration(val)
+	if err != nil || threshold < 0 {
+		return 0, fmt.Errorf("unsupported %s value %q", ThresholdEnvVar, val)
+	}
+
+	return threshold, nil
+}
+
+// OptionalFuncNames returns the functions probes resolve in the target to
+// record mutex waits, none unless they are turned on.
+func OptionalFuncNames() []string {
+	if t, err := threshold(); err != nil || t == 0 {
+		return nil
+	}
+
+	return FuncNames
+}
+
+// Threshold returns the configured minimum duration of reported waits, 0 if
+// waits are not recorded. Goroutines are identified through the register
+// based ABI of Go 1.17, and sync.(*Mutex).lockSlow only exists since Go
+// 1.14.
+func Threshold(target *process.TargetDetails) (time.Duration, error) {
+	threshold, err := threshold()
+	if err != nil || threshold == 0 {
+		return 0, err
+	}
+
+	if !target.IsRegistersABI() {
+		log.Component(log.ComponentProbe).V(0).Info("mutex waits require Go 1.17 or newer, disabling them",
+			"go_version", target.GoVersion.Original())
+		return 0, nil
+	}
+
+	if !target.HasFunctions(FuncNames...) {
+		log.Component(log.ComponentProbe).V(0).Info("mutex waits functions not found in target, disabling them",
+			"functions", FuncNames)
+		return 0, nil
+	}
+
+	return threshold, nil
+}
+
+// Constants returns the constants mutex_waits.h is injected with.
+func Constants(threshold time.Duration) map[string]interface{} {
+	return map[string]interface{}{
+		"min_mutex_wait_ns": uint64(threshold.Nanoseconds()),
+	}
+}
+
+// Recorder reads the mutex waits of the target until closed.
+type Recorder struct {
+	links  []link.Link
+	reader *perf.Reader
+}
+
+// Attach attaches progs to sync.(*Mutex).lockSlow in the target and records
+// the waits they report. Waits are recorded once per target, it returns nil
+// if the programs of another probe are already attached.
+func Attach(ctx *context.InstrumentorContext, progs Programs) (*Recorder, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if attached {
+		return nil, nil
+	}
+
+	r := &Recorder{}
+	offset, err := ctx.TargetDetails.GetFunctionOffset(lockSlowFuncName)
+	if err != nil {
+		return nil, err
+	}
+	l, err := ctx.ExecutableFor(lockSlowFuncName).Uprobe("", progs.LockSlow, &link.UprobeOptions{Offset: offset})
+	if err != nil {
+		return nil, err
+	}
+	r.links = append(r.links, l)
+
+	retOffsets, err := ctx.TargetDetails.GetFunctionReturns(lockSlowFuncName)
+	if err != nil {
+		r.closeLinks()
+		return nil, err
+	}
+	for _, ret := range retOffsets {
+		l, err := ctx.ExecutableFor(lockSlowFuncName).Uprobe("", progs.LockSlowReturns, &link.UprobeOptions{Offset: ret})
+		if err != nil {
+			r.closeLinks()
+			return nil, err
+		}
+		r.links = append(r.links, l)
+	}
+
+	rd, err := perf.NewReader(progs.Events, os.Getpagesize())
+	if err != nil {
+		r.closeLinks()
+		return nil, err
+	}
+	r.reader = rd
+
+	attached = true
+	go r.run()
+	return r, nil
+}
+
+func (r *Recorder) run() {
+	logger := log.Component(log.ComponentProbe)
+	for {
+		record, err := r.reader.Read()
+		if err != nil {
+			if errors.Is(err, perf.ErrClosed) {
+				return
+			}
+			log.Error(logger, log.ErrProbeRead, err, "error reading from mutex waits perf reader")
+			continue
+		}
+
+		if record.LostSamples != 0 {
+			logger.V(0).Info("mutex waits perf event ring buffer full", "dropped", record.LostSamples)
+			continue
+		}
+
+		var w wait
+		if err := binary.Read(bytes.NewBuffer(record.RawSample), binary.LittleEndian, &w); err != nil {
+			log.Error(logger, log.ErrEventDecode, err, "error parsing mutex wait event")
+			continue
+		}
+
+		mu.Lock()
+		addWait(w)
+		mu.Unlock()
+	}
+}
+
+// addWait adds w to the waits pending for its span. The waits of the oldest
+// spans are forgotten when maxPendingSpans spans are pending, as their span
+// may never be seen. mu must be held.
+func addWait(w wait) {
+	spanID := w.SpanContext.SpanID
+	if _, exists := pending[spanID]; !exists {
+		for len(pending) >= maxPendingSpans {
+			delete(pending, order[0])
+			order = order[1:]
+		}
+		if len(order) >= 2*maxPendingSpans {
+			order = compact(order)
+		}
+		order = append(order, spanID)
+	}
+
+	pending[spanID] = append(pending[spanID], events.SpanEvent{
+		Name:       EventName,
+		Time:       int64(w.StartTime),
+		Attributes: []attribute.KeyValue{durationKey.Int64(int64(w.EndTime - w.StartTime))},
+	})
+}
+
+// compact returns the spans of order still pending.
+func compact(order []trace.SpanID) []trace.SpanID {
+	result := make([]trace.SpanID, 0, len(pending))
+	for _, spanID := range order {
+		if _, exists := pending[spanID]; exists {
+			result = append(result, spanID)
+		}
+	}
+
+	return result
+}
+
+func (r *Recorder) closeLinks() {
+	for _, l := range r.links {
+		l.Close()
+	}
+}
+
+// Close stops recording mutex waits.
+func (r *Recorder) Close() {
+	r.closeLinks()
+	if r.reader != nil {
+		r.reader.Close()
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	attached = false
+	pending = make(map[trace.SpanID][]events.SpanEvent)
+	order = nil
+}
+
+// Annotate adds the span events of the waits recorded during the span of e.
+// Waits are read concurrently with spans, a wait read after its span is not
+// reported.
+func Annotate(e *events.Event) {
+	if e.SpanContext == nil {
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	spanID := e.SpanContext.SpanID()
+	if waits, exists := pending[spanID]; exists {
+		e.SpanEvents = append(e.SpanEvents, waits...)
+		delete(pending, spanID)
+	}
+}
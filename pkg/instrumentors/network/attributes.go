@@ -0,0 +1,90 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package network converts the peer addresses read by the probes to span
+// attributes.
+package network
+
+import (
+	"net"
+	"strconv"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+)
+
+// Transport is the transport protocol a peer is connected over.
+type Transport string
+
+const (
+	TransportTCP  Transport = "tcp"
+	TransportUDP  Transport = "udp"
+	TransportUnix Transport = "unix"
+)
+
+// Attributes of the network semantic conventions succeeding the net.*
+// attributes of semconv v1.7.0, which are still set alongside them.
+var (
+	peerAddressKey = attribute.Key("network.peer.address")
+	peerPortKey    = attribute.Key("network.peer.port")
+	transportKey   = attribute.Key("network.transport")
+	typeKey        = attribute.Key("network.type")
+)
+
+var legacyTransports = map[Transport]attribute.KeyValue{
+	TransportTCP:  semconv.NetTransportTCP,
+	TransportUDP:  semconv.NetTransportUDP,
+	TransportUnix: semconv.NetTransportUnix,
+}
+
+// PeerAttributes returns the attributes of the peer at addr, connected over
+// transport. addr is a host:port address or a bare host, or the socket path
+// for TransportUnix. network.type tells IPv4 from IPv6 peers, it is only set
+// when the host is an IP address.
+func PeerAttributes(addr string, transport Transport) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		transportKey.String(string(transport)),
+		legacyTransports[transport],
+	}
+	if addr == "" {
+		return attrs
+	}
+
+	if transport == TransportUnix {
+		return append(attrs, peerAddressKey.String(addr), semconv.NetPeerNameKey.String(addr))
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		host, port = addr, ""
+	}
+
+	attrs = append(attrs, peerAddressKey.String(host))
+	if ip := net.ParseIP(host); ip != nil {
+		attrs = append(attrs, semconv.NetPeerIPKey.String(host))
+		if ip.To4() != nil {
+			attrs = append(attrs, typeKey.String("ipv4"))
+		} else {
+			attrs = append(attrs, typeKey.String("ipv6"))
+		}
+	} else {
+		attrs = append(attrs, semconv.NetPeerNameKey.String(host))
+	}
+
+	if p, err := strconv.Atoi(port); err == nil && p > 0 && p <= 65535 {
+		attrs = append(attrs, peerPortKey.Int(p), semconv.NetPeerPortKey.Int(p))
+	}
+
+	return attrs
+}
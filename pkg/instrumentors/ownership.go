@@ -0,0 +1,134 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package instrumentors
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/errors"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/log"
+)
+
+const (
+	// ForceAttachEnvVar set to "true" attaches to the target even if another
+	// agent already instruments it.
+	ForceAttachEnvVar = "OTEL_GO_AUTO_FORCE_ATTACH"
+
+	ownershipDir = "/var/run/otel-go-instrumentation"
+)
+
+// targetOwnership is an exclusive lock on a target PID held by the agent
+// instrumenting it. The lock is released by the kernel if the agent dies.
+type targetOwnership struct {
+	file *os.File
+}
+
+// acquireOwnership locks the target pid, failing with
+// errors.ErrAlreadyInstrumented if another agent holds the lock unless
+// ForceAttachEnvVar is set.
+func acquireOwnership(pid int) (*targetOwnership, error) {
+	if err := os.MkdirAll(ownershipDir, 0755); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(ownershipDir, fmt.Sprintf("%d.lock", pid))
+	f, err := lockFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if f == nil {
+		owner, _ := os.ReadFile(path)
+		if force, _ := strconv.ParseBool(os.Getenv(ForceAttachEnvVar)); force {
+			log.Component(log.ComponentManager).V(0).Info("target already instrumented by another agent, attaching anyway",
+				"pid", pid, "agent_pid", strings.TrimSpace(string(owner)))
+			return &targetOwnership{}, nil
+		}
+
+		return nil, fmt.Errorf("%w: pid %d is instrumented by agent pid %s, set %s=true to attach anyway",
+			errors.ErrAlreadyInstrumented, pid, strings.TrimSpace(string(owner)), ForceAttachEnvVar)
+	}
+
+	if err := f.Truncate(0); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.WriteAt([]byte(strconv.Itoa(os.Getpid())), 0); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &targetOwnership{file: f}, nil
+}
+
+// lockFile opens and locks the file at path, returning nil if another agent
+// holds its lock. Owners remove the file before unlocking it, a file removed
+// between being opened and locked is opened again.
+func lockFile(path string) (*os.File, error) {
+	for {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, err
+		}
+
+		locked, err := tryLock(f)
+		if err != nil || !locked {
+			f.Close()
+			return nil, err
+		}
+
+		current, err := isFileAt(f, path)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if current {
+			return f, nil
+		}
+		f.Close()
+	}
+}
+
+// isFileAt reports whether f is still the file at path.
+func isFileAt(f *os.File, path string) (bool, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	pathInfo, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return os.SameFile(info, pathInfo), nil
+}
+
+// release removes the lock file and unlocks the target. Agents that opened
+// the file before its removal open a new one, see lockFile.
+func (o *targetOwnership) release() {
+	if o == nil || o.file == nil {
+		return
+	}
+
+	os.Remove(o.file.Name())
+	o.file.Close()
+}
@@ -0,0 +1,125 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package registry describes the probes of the agent: what they instrument,
+// the offsets they need and the telemetry they produce.
+package registry
+
+import (
+	"fmt"
+
+	"github.com/cilium/ebpf"
+	"github.com/hashicorp/go-version"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/inject"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/process"
+)
+
+// Signal is a kind of telemetry produced by a probe.
+type Signal string
+
+const (
+	SignalTraces Signal = "traces"
+)
+
+// Probe describes a probe, as declared by its package.
+type Probe struct {
+	// ID is the library name of the instrumentor, the instrumentation scope
+	// of its spans is go.opentelemetry.io/auto/<ID>.
+	ID string
+	// Package is the instrumented Go package.
+	Package string
+	// Functions are the functions the probe attaches to, all of them must
+	// be found in the target for the probe to load.
+	Functions []string
+	// OptionalFunctions are the functions of the optional and opt-in
+	// features of the probe. The feature is disabled when they are not found in the target.
+	OptionalFunctions []string
+	// Offsets are the struct field offsets injected into the probe.
+	Offsets []Offsets
+	Signals []Signal
+	// SchemaURL is the schema URL of the semantic conventions the probe
+	// follows.
+	SchemaURL string
+	// VariantsModule is the module ("go" for the standard library) the
+	// version of which selects the variant of the probe loaded.
+	VariantsModule string
+	// Variants are builds of the eBPF programs of the probe for ranges of
+	// versions of VariantsModule, in order of preference. The manager
+	// selects the first variant matching the version found in the target.
+	Variants []Variant
+}
+
+// Variant is a build of the eBPF programs of a probe for the versions of a
+// module matching Constraints.
+type Variant struct {
+	// Constraints are version constraints in hashicorp/go-version syntax,
+	// such as ">= v1.7.0". Empty constraints match every version, including
+	// versions that cannot be parsed.
+	Constraints string
+	// Load returns the collection spec of the programs of the variant.
+	Load func() (*ebpf.CollectionSpec, error)
+	// Offsets are the struct field offsets injected into the variant only.
+	Offsets []Offsets
+	// Constants are the other constants the variant is injected with.
+	Constants map[string]interface{}
+}
+
+// SelectVariant returns the variant of p for the version of its variants
+// module found in target, nil if p has no variants.
+func (p Probe) SelectVariant(target *process.TargetDetails) (*Variant, error) {
+	if len(p.Variants) == 0 {
+		return nil, nil
+	}
+
+	var v string
+	if p.VariantsModule == "go" {
+		if target.GoVersion != nil {
+			v = target.GoVersion.Original()
+		}
+	} else {
+		v = target.Libraries[p.VariantsModule]
+	}
+	parsed, parseErr := version.NewVersion(v)
+
+	for i := range p.Variants {
+		variant := &p.Variants[i]
+		if variant.Constraints == "" {
+			return variant, nil
+		}
+
+		constraints, err := version.NewConstraint(variant.Constraints)
+		if err != nil {
+			return nil, fmt.Errorf("invalid constraints %q of a variant of probe %s: %w", variant.Constraints, p.ID, err)
+		}
+		if parseErr == nil && constraints.Check(parsed) {
+			return variant, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no variant of probe %s for %s version %q", p.ID, p.VariantsModule, v)
+}
+
+// Offsets are struct field offsets looked up at the version of Module
+// ("go" for the standard library) found in the target.
+type Offsets struct {
+	Module string
+	Fields []*inject.InjectStructField
+	// Optional offsets only enable features of the probe, it loads without
+	// them.
+	Optional bool
+	// Forks are module path patterns, in path.Match syntax, of known forks
+	// of Module. A fork found in the target is instrumented as Module when
+	// its structs have the layout of Fields at a tracked version of Module.
+	Forks []string
+}
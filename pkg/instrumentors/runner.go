@@ -16,90 +16,221 @@ package instrumentors
 
 import (
 	"fmt"
+	"os"
+	"strconv"
+	"time"
 
 	"github.com/cilium/ebpf/link"
 	"github.com/cilium/ebpf/rlimit"
 	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/inject"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/bpffs"
 	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/context"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/diagnostics"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/events"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/gcpauses"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/mutexwaits"
 	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/log"
 	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/process"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func (m *instrumentorsManager) Run(target *process.TargetDetails) error {
-	if len(m.instrumentors) == 0 {
-		log.Logger.V(0).Info("there are no avilable instrumentations for target process")
-		return nil
-	}
+// handleEvents handles the events of the instrumentors until incomingEvents
+// is closed.
+func (m *instrumentorsManager) handleEvents(target *process.TargetDetails) {
+	defer close(m.loopDone)
+	m.watchdog.setTarget(target.PID)
+	watchdogTicker := time.NewTicker(watchdogCheckInterval)
+	defer watchdogTicker.Stop()
 
-	err := m.load(target)
-	if err != nil {
-		return err
-	}
-
-	for _, i := range m.instrumentors {
-		go i.Run(m.incomingEvents)
+	var exitCheck <-chan time.Time
+	if m.stopOnTargetExit {
+		exitTicker := time.NewTicker(targetExitPollInterval)
+		defer exitTicker.Stop()
+		exitCheck = exitTicker.C
 	}
+	var drainTimeout <-chan time.Time
 
 	for {
 		select {
-		case <-m.done:
-			log.Logger.V(0).Info("shutting down all instrumentors due to signal")
-			m.cleanup()
-			return nil
-		case e := <-m.incomingEvents:
-			m.otelController.Trace(e)
+		case <-exitCheck:
+			if targetExited(target.PID) {
+				log.Component(log.ComponentManager).V(0).Info("target process exited, reading remaining events")
+				exitCheck = nil
+				drainTimeout = time.After(exitDrainTimeout)
+			}
+		case <-drainTimeout:
+			drainTimeout = nil
+			close(m.targetGone)
+		case e, ok := <-m.incomingEvents:
+			if !ok {
+				return
+			}
+			if drainTimeout != nil {
+				// Wait for the probes to go quiet before stopping.
+				drainTimeout = time.After(exitDrainTimeout)
+			}
+			m.handleEvent(e)
+		case <-watchdogTicker.C:
+			m.watchdog.check()
 		}
 	}
 }
 
-func (m *instrumentorsManager) load(target *process.TargetDetails) error {
+func (m *instrumentorsManager) handleEvent(e *events.Event) {
+	m.watchdog.observe(e.Library)
+	if m.dedup.isDuplicate(e) || m.limiter.isOverLimit(e, time.Now()) {
+		return
+	}
+	if mod, exists := m.duplicateOf[e.Library]; exists {
+		e.Attributes = append(e.Attributes, duplicateOfKey.String(mod))
+	}
+	if e.Kind == trace.SpanKindServer {
+		gcpauses.Annotate(e)
+		mutexwaits.Annotate(e)
+	}
+	if m.eventHandler != nil {
+		m.eventHandler(e.Library, e)
+	}
+	if m.otelController != nil {
+		m.otelController.Trace(e)
+	}
+}
+
+// load loads the probes into target. Loading is abandoned when cancel is
+// closed.
+func (m *instrumentorsManager) load(target *process.TargetDetails, cancel <-chan struct{}) (err error) {
+	span := diagnostics.StartOperation(nil, "load", semconv.ProcessPIDKey.Int(target.PID))
+	defer func() { diagnostics.EndOperation(span, err) }()
+
 	// Allow the current process to lock memory for eBPF resources.
 	if err := rlimit.RemoveMemlock(); err != nil {
 		return err
 	}
 
+	ownership, err := acquireOwnership(target.PID)
+	if err != nil {
+		return err
+	}
+	m.ownership = ownership
+
 	injector, err := inject.New(target)
 	if err != nil {
+		m.ownership.release()
 		return err
 	}
+	m.injector = injector
 
 	exe, err := link.OpenExecutable(fmt.Sprintf("/proc/%d/exe", target.PID))
 	if err != nil {
+		m.ownership.release()
+		return err
+	}
+	sharedObjects := make(map[string]*link.Executable)
+	for _, f := range target.Functions {
+		if _, exists := sharedObjects[f.Path]; f.Path == "" || exists {
+			continue
+		}
+
+		so, err := link.OpenExecutable(f.Path)
+		if err != nil {
+			m.ownership.release()
+			return err
+		}
+		sharedObjects[f.Path] = so
+	}
+
+	requestLimits, err := context.ParseRequestLimits()
+	if err != nil {
+		m.ownership.release()
 		return err
 	}
+
+	readLimits, err := context.ParseReadLimits()
+	if err != nil {
+		m.ownership.release()
+		return err
+	}
+
 	ctx := &context.InstrumentorContext{
 		TargetDetails: target,
 		Executable:    exe,
 		Injector:      injector,
+		SharedObjects: sharedObjects,
+		RequestLimits: requestLimits,
+		ReadLimits:    readLimits,
 	}
 
 	if err := m.allocator.Load(ctx); err != nil {
-		log.Logger.Error(err, "failed to load allocator")
+		log.Error(log.Component(log.ComponentManager), log.ErrProbeLoad, err, "failed to load allocator")
+		m.ownership.release()
 		return err
 	}
 
-	// Load instrumentors
-	for name, i := range m.instrumentors {
-		log.Logger.V(0).Info("loading instrumentor", "name", name)
-		err := i.Load(ctx)
-		if err != nil {
-			log.Logger.Error(err, "error while loading instrumentors, cleaning up", "name", name)
-			m.cleanup()
+	if pin, _ := strconv.ParseBool(os.Getenv(bpffs.PinMapsEnvVar)); pin {
+		ctx.PinPath = bpffs.TargetPinPath(target.PID)
+		if _, err := os.Stat(ctx.PinPath); err == nil {
+			log.Component(log.ComponentManager).V(0).Info("reusing maps pinned by a previous agent", "path", ctx.PinPath)
+		} else if err := os.MkdirAll(ctx.PinPath, 0755); err != nil {
+			m.ownership.release()
 			return err
 		}
+		m.pinPath = ctx.PinPath
+	}
+
+	if err := m.loadInstrumentors(ctx, span, cancel); err != nil {
+		m.closeInstrumentors()
+		if err := m.release(); err != nil {
+			log.Error(log.Component(log.ComponentManager), log.ErrCleanup, err, "could not release target")
+		}
+		return err
 	}
 
-	log.Logger.V(0).Info("loaded instrumentors to memory", "total_instrumentors", len(m.instrumentors))
+	log.Component(log.ComponentManager).V(0).Info("loaded instrumentors to memory", "total_instrumentors", len(m.instrumentors))
 	return nil
 }
 
-func (m *instrumentorsManager) cleanup() {
-	close(m.incomingEvents)
+func (m *instrumentorsManager) reportLoadFailure(i Instrumentor, target *process.TargetDetails, err error) {
+	bundle := diagnostics.NewBundle(i.LibraryName(), i.FuncNames(), target, err)
+	log.Component(log.ComponentManager).V(0).Info("instrumentor load diagnostics", "library", bundle.Library,
+		"functions", bundle.Functions, "go_version", bundle.GoVersion, "kernel_version", bundle.KernelVersion,
+		"btf_available", bundle.BTFAvailable, "verifier_log_lines", len(bundle.VerifierLog))
+
+	dir, exists := os.LookupEnv(diagnostics.DirEnvVar)
+	if !exists {
+		return
+	}
+
+	path, err := bundle.WriteFile(dir)
+	if err != nil {
+		log.Error(log.Component(log.ComponentManager), log.ErrDiagnostics, err, "could not write diagnostic bundle", "dir", dir)
+		return
+	}
+	log.Component(log.ComponentManager).V(0).Info("wrote diagnostic bundle", "path", path)
+}
+
+func (m *instrumentorsManager) closeInstrumentors() {
 	for _, i := range m.instrumentors {
 		i.Close()
 	}
 }
 
+// release removes the pinned maps of the target and releases its ownership.
+func (m *instrumentorsManager) release() error {
+	defer m.ownership.release()
+
+	// Maps are only kept pinned to recover from a crash.
+	if m.pinPath != "" {
+		if err := os.RemoveAll(m.pinPath); err != nil {
+			return fmt.Errorf("could not remove pinned maps at %s: %w", m.pinPath, err)
+		}
+		m.pinPath = ""
+	}
+
+	return nil
+}
+
+// Close makes Wait, and Run, return.
 func (m *instrumentorsManager) Close() {
 	m.done <- true
 }
@@ -0,0 +1,161 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package schedlatency measures the scheduling latency of the goroutines of
+// request handlers: how long they spent runnable but not running during their
+// span. A high latency points at CPU saturation of the target rather than at
+// slow downstream calls.
+package schedlatency
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/context"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/log"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/process"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// EnvVar enables the scheduling latency attribute of server spans when
+	// set to true. It probes every goroutine switch of the target.
+	EnvVar = "OTEL_GO_AUTO_SCHED_LATENCY"
+
+	readyFuncName   = "runtime.ready"
+	goschedFuncName = "runtime.goschedImpl"
+	executeFuncName = "runtime.execute"
+)
+
+// FuncNames are the runtime functions measuring scheduling latency attach to.
+var FuncNames = []string{readyFuncName, goschedFuncName, executeFuncName}
+
+// latencyKey holds the scheduling latency of a span in nanoseconds.
+var latencyKey = attribute.Key("telemetry.auto.goroutine.sched_latency")
+
+// Programs measure scheduling latency, they are shared by the probes of
+// request handlers through sched_latency.h.
+type Programs struct {
+	Ready   *ebpf.Program
+	Gosched *ebpf.Program
+	Execute *ebpf.Program
+}
+
+var (
+	mu       sync.Mutex
+	attached bool
+)
+
+// enabled reports whether scheduling latency is turned on by EnvVar.
+func enabled() (bool, error) {
+	val, exists := os.LookupEnv(EnvVar)
+	if !exists {
+		return false, nil
+	}
+
+	enabled, err := strconv.ParseBool(val)
+	if err != nil {
+		return false, fmt.Errorf("unsupported %s value %q", EnvVar, val)
+	}
+
+	return enabled, nil
+}
+
+// OptionalFuncNames returns the functions probes resolve in the target to
+// measure scheduling latency, none unless it is turned on.
+func OptionalFuncNames() []string {
+	if on, err := enabled(); err != nil || !on {
+		return nil
+	}
+
+	return FuncNames
+}
+
+// Enabled reports whether scheduling latency is measured. Goroutines are
+// identified through the register based ABI of Go 1.17.
+func Enabled(target *process.TargetDetails) (bool, error) {
+	enabled, err := enabled()
+	if err != nil || !enabled {
+		return false, err
+	}
+
+	if !target.IsRegistersABI() {
+		log.Component(log.ComponentProbe).V(0).Info("scheduling latency requires Go 1.17 or newer, disabling it",
+			"go_version", target.GoVersion.Original())
+		return false, nil
+	}
+
+	if !target.HasFunctions(FuncNames...) {
+		log.Component(log.ComponentProbe).V(0).Info("scheduler functions not found in target, disabling scheduling latency",
+			"functions", FuncNames)
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// Constants returns the constants sched_latency.h is injected with.
+func Constants(enabled bool) map[string]interface{} {
+	return map[string]interface{}{
+		"track_sched_latency": enabled,
+	}
+}
+
+// Attribute returns the attribute recording latency, in nanoseconds.
+func Attribute(latency uint64) attribute.KeyValue {
+	return latencyKey.Int64(int64(latency))
+}
+
+// Attach attaches progs to the runtime functions of the target. Latency is
+// measured once per target, it returns no links if the programs of another
+// probe are already attached.
+func Attach(ctx *context.InstrumentorContext, progs Programs) ([]link.Link, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if attached {
+		return nil, nil
+	}
+
+	var links []link.Link
+	closeAll := func() {
+		for _, l := range links {
+			l.Close()
+		}
+	}
+
+	for funcName, prog := range map[string]*ebpf.Program{
+		readyFuncName:   progs.Ready,
+		goschedFuncName: progs.Gosched,
+		executeFuncName: progs.Execute,
+	} {
+		offset, err := ctx.TargetDetails.GetFunctionOffset(funcName)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		l, err := ctx.Executable.Uprobe("", prog, &link.UprobeOptions{Offset: offset})
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		links = append(links, l)
+	}
+
+	attached = true
+	return links, nil
+}
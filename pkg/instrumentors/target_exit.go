@@ -0,0 +1,50 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package instrumentors
+
+import (
+	"time"
+
+	"github.com/prometheus/procfs"
+)
+
+const (
+	targetExitPollInterval = 100 * time.Millisecond
+	// exitDrainTimeout is how long events are still read once the target
+	// exited, for the probes to deliver the events already produced.
+	exitDrainTimeout = 500 * time.Millisecond
+)
+
+// SetStopOnTargetExit makes Wait return once the target process exits,
+// instead of waiting for Close. It must be called before Start.
+func (m *instrumentorsManager) SetStopOnTargetExit(stop bool) {
+	m.stopOnTargetExit = stop
+}
+
+// targetExited reports whether the process is gone or a zombie waiting to be
+// reaped by its parent.
+func targetExited(pid int) bool {
+	proc, err := procfs.NewProc(pid)
+	if err != nil {
+		return true
+	}
+
+	stat, err := proc.Stat()
+	if err != nil {
+		return true
+	}
+
+	return stat.State == "Z" || stat.State == "X"
+}
@@ -0,0 +1,60 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package instrumentors
+
+import "sort"
+
+// TargetInfo describes the build of the instrumented target and the
+// instrumentors matching it.
+type TargetInfo struct {
+	PID       int
+	GoVersion string
+	// Dependencies maps the module path of each target dependency to its
+	// version.
+	Dependencies map[string]string
+	// CgoEnabled reports whether the target links C code.
+	CgoEnabled bool
+	// Instrumentors lists the libraries instrumented in the target.
+	Instrumentors []string
+}
+
+// TargetInfo returns the build info of the target, or nil if the target has
+// not been analyzed yet.
+func (m *instrumentorsManager) TargetInfo() *TargetInfo {
+	if m.target == nil {
+		return nil
+	}
+
+	info := &TargetInfo{
+		PID:          m.target.PID,
+		Dependencies: make(map[string]string, len(m.target.Libraries)),
+		CgoEnabled:   m.target.CgoEnabled,
+	}
+
+	if m.target.GoVersion != nil {
+		info.GoVersion = m.target.GoVersion.Original()
+	}
+
+	for mod, v := range m.target.Libraries {
+		info.Dependencies[mod] = v
+	}
+
+	for name := range m.instrumentors {
+		info.Instrumentors = append(info.Instrumentors, name)
+	}
+	sort.Strings(info.Instrumentors)
+
+	return info
+}
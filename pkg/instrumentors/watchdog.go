@@ -0,0 +1,132 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package instrumentors
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/log"
+	"github.com/prometheus/procfs"
+)
+
+const (
+	// probeSilenceThreshold is the duration a probe that previously produced
+	// events may stay silent before it is reported as unhealthy.
+	probeSilenceThreshold = time.Minute
+	watchdogCheckInterval = 10 * time.Second
+	healthWarningsBuffer  = 16
+)
+
+// ProbeHealthWarning is reported when an instrumentor that previously
+// produced events stops producing them while the target process is still
+// active. This is usually a sign the injected offsets no longer match the
+// target.
+type ProbeHealthWarning struct {
+	Library   string
+	LastEvent time.Time
+	SilentFor time.Duration
+}
+
+type probeWatchdog struct {
+	pid        int
+	mu         sync.Mutex
+	lastEvents map[string]time.Time
+	silent     map[string]bool
+	lastCPU    float64
+	warnings   chan ProbeHealthWarning
+
+	// silentProbesTotal counts the number of health warnings raised.
+	silentProbesTotal uint64
+}
+
+func newProbeWatchdog() *probeWatchdog {
+	return &probeWatchdog{
+		lastEvents: make(map[string]time.Time),
+		silent:     make(map[string]bool),
+		warnings:   make(chan ProbeHealthWarning, healthWarningsBuffer),
+	}
+}
+
+func (w *probeWatchdog) setTarget(pid int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pid = pid
+	w.lastCPU, _ = w.targetCPUTime()
+}
+
+func (w *probeWatchdog) observe(library string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.lastEvents[library] = time.Now()
+	if w.silent[library] {
+		log.Component(log.ComponentManager).V(0).Info("instrumentor resumed producing events", "library", library)
+		delete(w.silent, library)
+	}
+}
+
+func (w *probeWatchdog) check() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	cpu, err := w.targetCPUTime()
+	if err != nil {
+		log.Component(log.ComponentManager).V(1).Info("could not read target cpu time", "pid", w.pid, "error", err.Error())
+		return
+	}
+	active := cpu > w.lastCPU
+	w.lastCPU = cpu
+	if !active {
+		return
+	}
+
+	now := time.Now()
+	for library, last := range w.lastEvents {
+		silentFor := now.Sub(last)
+		if silentFor < probeSilenceThreshold || w.silent[library] {
+			continue
+		}
+
+		w.silent[library] = true
+		total := atomic.AddUint64(&w.silentProbesTotal, 1)
+		log.Component(log.ComponentManager).V(0).Info("instrumentor stopped producing events while target is active, offsets may be out of date",
+			"library", library, "last_event", last, "silent_for", silentFor.String(), "silent_probes_total", total)
+
+		warning := ProbeHealthWarning{
+			Library:   library,
+			LastEvent: last,
+			SilentFor: silentFor,
+		}
+		select {
+		case w.warnings <- warning:
+		default:
+		}
+	}
+}
+
+func (w *probeWatchdog) targetCPUTime() (float64, error) {
+	proc, err := procfs.NewProc(w.pid)
+	if err != nil {
+		return 0, err
+	}
+
+	stat, err := proc.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	return stat.CPUTime(), nil
+}
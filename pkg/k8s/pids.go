@@ -0,0 +1,119 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package k8s resolves Kubernetes pods and containers to the host PIDs of
+// their processes, so they can be selected as instrumentation targets.
+package k8s
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// procPath is the procfs mount point of the host PID namespace.
+const procPath = "/proc"
+
+// PIDsForPod returns the PIDs of all processes running in the pod with the
+// given UID, as found in the pod metadata.
+func PIDsForPod(podUID string) ([]int, error) {
+	if podUID == "" {
+		return nil, fmt.Errorf("empty pod UID")
+	}
+
+	// The systemd cgroup driver replaces the dashes of the UID with
+	// underscores, normalize both forms to match either.
+	needle := "pod" + strings.ReplaceAll(podUID, "_", "-")
+	return findPIDs(func(cgroup string) bool {
+		return strings.Contains(strings.ReplaceAll(cgroup, "_", "-"), needle)
+	})
+}
+
+// PIDsForContainer returns the PIDs of all processes running in the container
+// with the given ID. The ID may be given as reported in the pod status, with
+// its runtime prefix (e.g. containerd://<id>).
+func PIDsForContainer(containerID string) ([]int, error) {
+	if i := strings.Index(containerID, "://"); i >= 0 {
+		containerID = containerID[i+len("://"):]
+	}
+	if containerID == "" {
+		return nil, fmt.Errorf("empty container ID")
+	}
+
+	return findPIDs(func(cgroup string) bool {
+		return strings.Contains(cgroup, containerID)
+	})
+}
+
+// findPIDs returns the PIDs of the processes for which match returns true on
+// one of their cgroup paths.
+func findPIDs(match func(cgroup string) bool) ([]int, error) {
+	dirs, err := ioutil.ReadDir(procPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var pids []int
+	for _, di := range dirs {
+		if !di.IsDir() {
+			continue
+		}
+
+		pid, err := strconv.Atoi(di.Name())
+		if err != nil {
+			continue
+		}
+
+		cgroups, err := readCgroups(pid)
+		if err != nil {
+			// The process may have exited since listing /proc.
+			continue
+		}
+
+		for _, cg := range cgroups {
+			if match(cg) {
+				pids = append(pids, pid)
+				break
+			}
+		}
+	}
+
+	return pids, nil
+}
+
+// readCgroups returns the cgroup paths of the process, one per hierarchy.
+func readCgroups(pid int) ([]string, error) {
+	f, err := os.Open(path.Join(procPath, strconv.Itoa(pid), "cgroup"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var cgroups []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// Each line is formatted as hierarchy-ID:controller-list:cgroup-path.
+		parts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		cgroups = append(cgroups, parts[2])
+	}
+
+	return cgroups, scanner.Err()
+}
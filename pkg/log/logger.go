@@ -15,19 +15,120 @@
 package log
 
 import (
+	"sync"
+	"sync/atomic"
+
 	"github.com/go-logr/logr"
 	"github.com/go-logr/zapr"
 	"go.uber.org/zap"
 )
 
-var Logger logr.Logger
+// Keys of the fields added to log records.
+const (
+	// ComponentKey identifies the part of the agent emitting the record.
+	ComponentKey = "component"
+	// ProbeKey identifies the probe emitting the record, by the name of the
+	// library it instruments.
+	ProbeKey = "probe"
+	// TargetPIDKey is the PID of the instrumented process.
+	TargetPIDKey = "target_pid"
+	// ErrorCodeKey is the stable code of the failure class of error records.
+	ErrorCodeKey = "error_code"
+)
+
+// Components of the agent.
+const (
+	ComponentAgent       = "agent"
+	ComponentAnalyzer    = "analyzer"
+	ComponentManager     = "manager"
+	ComponentAllocator   = "allocator"
+	ComponentInjector    = "injector"
+	ComponentProbe       = "probe"
+	ComponentExporter    = "exporter"
+	ComponentDiagnostics = "diagnostics"
+)
+
+// ErrorCode identifies a class of failures, for log based alerting.
+type ErrorCode string
+
+// Error codes of the agent. They are part of the logging schema and must
+// not be changed.
+const (
+	ErrInvalidConfig   ErrorCode = "invalid_config"
+	ErrTargetDiscovery ErrorCode = "target_discovery"
+	ErrTargetAnalysis  ErrorCode = "target_analysis"
+	ErrProbeLoad       ErrorCode = "probe_load"
+	ErrProbeRead       ErrorCode = "probe_read"
+	ErrEventDecode     ErrorCode = "event_decode"
+	ErrExporterConnect ErrorCode = "exporter_connect"
+	ErrExport          ErrorCode = "export"
+	ErrDiagnostics     ErrorCode = "diagnostics"
+	ErrCleanup         ErrorCode = "cleanup"
+	ErrClockSync       ErrorCode = "clock_sync"
+)
+
+var (
+	// mu serializes the updates of base, which is read without locking.
+	mu   sync.Mutex
+	base atomic.Value // logr.Logger
+
+	// Logger emits the records of the agent component. It is only set by
+	// Init and InitWithSink, so its records lack the target PID: components
+	// log through Component or Probe.
+	Logger logr.Logger
+)
 
+// Init logs JSON records to stderr.
 func Init() error {
 	zapLog, err := zap.NewProduction()
 	if err != nil {
 		return err
 	}
 
-	Logger = zapr.NewLogger(zapLog)
+	InitWithSink(zapr.NewLogger(zapLog).GetSink())
 	return nil
 }
+
+// InitWithSink logs records to sink, e.g. to forward them to a custom
+// backend. Records keep the fields of the logging schema.
+func InitWithSink(sink logr.LogSink) {
+	mu.Lock()
+	defer mu.Unlock()
+	l := logr.New(sink)
+	base.Store(l)
+	Logger = l.WithValues(ComponentKey, ComponentAgent)
+}
+
+// SetTargetPID adds the PID of the instrumented process to the records
+// emitted by loggers obtained afterwards.
+func SetTargetPID(pid int) {
+	mu.Lock()
+	defer mu.Unlock()
+	base.Store(baseLogger().WithValues(TargetPIDKey, pid))
+}
+
+// baseLogger returns the logger components derive theirs from, discarding
+// records until Init is called.
+func baseLogger() logr.Logger {
+	l, ok := base.Load().(logr.Logger)
+	if !ok {
+		return logr.Discard()
+	}
+
+	return l
+}
+
+// Component returns the logger of component.
+func Component(component string) logr.Logger {
+	return baseLogger().WithValues(ComponentKey, component)
+}
+
+// Probe returns the logger of the probe instrumenting library.
+func Probe(library string) logr.Logger {
+	return Component(ComponentProbe).WithValues(ProbeKey, library)
+}
+
+// Error logs err as a failure of class code.
+func Error(logger logr.Logger, code ErrorCode, err error, msg string, keysAndValues ...interface{}) {
+	logger.WithCallDepth(1).Error(err, msg, append([]interface{}{ErrorCodeKey, string(code)}, keysAndValues...)...)
+}
@@ -0,0 +1,149 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opentelemetry
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const (
+	// MaxExportBatchBytesEnvVar limits the size of export requests in bytes,
+	// see WithMaxExportBatchBytes.
+	MaxExportBatchBytesEnvVar = "OTEL_GO_AUTO_MAX_EXPORT_BATCH_BYTES"
+
+	// defaultMaxExportBatchBytes keeps export requests below the 4 MiB
+	// default gRPC message size limit of collectors.
+	defaultMaxExportBatchBytes = 4 * 1024 * 1024
+
+	// spanOverheadBytes approximates the encoded size of the fixed span
+	// fields: ids, timestamps, kind and status.
+	spanOverheadBytes = 64
+)
+
+// WithMaxExportBatchBytes limits the estimated serialized size of each export
+// request. Batches above the limit are split into several requests.
+func WithMaxExportBatchBytes(n int) Option {
+	return func(c *config) {
+		c.maxExportBatchBytes = n
+	}
+}
+
+func maxExportBatchBytesFromEnv() (Option, error) {
+	val, exists := os.LookupEnv(MaxExportBatchBytesEnvVar)
+	if !exists {
+		return nil, nil
+	}
+
+	n, err := strconv.Atoi(val)
+	if err != nil || n <= 0 {
+		return nil, fmt.Errorf("unsupported %s value %q", MaxExportBatchBytesEnvVar, val)
+	}
+
+	return WithMaxExportBatchBytes(n), nil
+}
+
+// sizeLimitedExporter splits batches so that each export request stays below
+// maxBytes.
+type sizeLimitedExporter struct {
+	sdktrace.SpanExporter
+	maxBytes int
+}
+
+func newSizeLimitedExporter(exporter sdktrace.SpanExporter, maxBytes int) *sizeLimitedExporter {
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxExportBatchBytes
+	}
+
+	return &sizeLimitedExporter{
+		SpanExporter: exporter,
+		maxBytes:     maxBytes,
+	}
+}
+
+// partialExportError reports the spans of a batch that could not be
+// exported, the other requests of the batch succeeded.
+type partialExportError struct {
+	failedSpans int
+	err         error
+}
+
+func (e *partialExportError) Error() string {
+	return fmt.Sprintf("failed to export %d spans: %v", e.failedSpans, e.err)
+}
+
+func (e *partialExportError) Unwrap() error {
+	return e.err
+}
+
+// ExportSpans exports every request of the batch, even after one failed. It
+// returns a *partialExportError counting the spans of the failed requests.
+func (e *sizeLimitedExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	var failed int
+	var lastErr error
+	export := func(chunk []sdktrace.ReadOnlySpan) {
+		if err := e.SpanExporter.ExportSpans(ctx, chunk); err != nil {
+			failed += len(chunk)
+			lastErr = err
+		}
+	}
+
+	start, size := 0, 0
+	for i, s := range spans {
+		spanSize := estimateSpanSize(s)
+		if i > start && size+spanSize > e.maxBytes {
+			export(spans[start:i])
+			start, size = i, 0
+		}
+		size += spanSize
+	}
+	if start < len(spans) {
+		export(spans[start:])
+	}
+
+	if lastErr == nil {
+		return nil
+	}
+
+	return &partialExportError{failedSpans: failed, err: lastErr}
+}
+
+// estimateSpanSize approximates the OTLP encoded size of s.
+func estimateSpanSize(s sdktrace.ReadOnlySpan) int {
+	size := spanOverheadBytes + len(s.Name()) + len(s.Status().Description)
+	size += attributesSize(s.Attributes())
+	for _, e := range s.Events() {
+		size += spanOverheadBytes + len(e.Name) + attributesSize(e.Attributes)
+	}
+	for _, l := range s.Links() {
+		size += spanOverheadBytes + attributesSize(l.Attributes)
+	}
+
+	return size
+}
+
+func attributesSize(attrs []attribute.KeyValue) int {
+	var size int
+	for _, kv := range attrs {
+		size += len(kv.Key) + len(kv.Value.Emit()) + 8
+	}
+
+	return size
+}
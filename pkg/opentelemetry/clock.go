@@ -0,0 +1,130 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opentelemetry
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/log"
+)
+
+const (
+	defaultClockSyncInterval = time.Minute
+	defaultClockMaxDrift     = time.Millisecond
+
+	// maxClockSlewPPM is the highest rate, in parts per million, NTP slews
+	// the wall clock at. The wall clock changing faster was stepped.
+	maxClockSlewPPM = 500
+)
+
+// WithClockSync sets how often the offset converting the boot time stamps of
+// the probes to wall clock time is estimated again, and how far time stamps
+// may drift from the wall clock before the offset is updated. Steps of the
+// wall clock are only corrected by the next estimation. An interval of 0
+// estimates the offset once, on start.
+func WithClockSync(interval time.Duration, maxDrift time.Duration) Option {
+	return func(c *config) {
+		c.clockSyncInterval = interval
+		c.clockMaxDrift = maxDrift
+	}
+}
+
+// clockSync converts boot time stamps to wall clock time, estimating the
+// offset between both clocks again every interval.
+type clockSync struct {
+	// offset is accessed atomically.
+	offset   int64
+	interval time.Duration
+	maxDrift time.Duration
+	// synced is when offset was last updated.
+	synced time.Time
+
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func newClockSync(interval time.Duration, maxDrift time.Duration) (*clockSync, error) {
+	offset, err := estimateBootTimeOffset()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &clockSync{
+		offset:   offset,
+		interval: interval,
+		maxDrift: maxDrift,
+		synced:   time.Now(),
+		done:     make(chan struct{}),
+	}
+	if interval > 0 {
+		go c.run()
+	}
+
+	return c, nil
+}
+
+// convert returns the wall clock time of the boot time stamp t.
+func (c *clockSync) convert(t int64) time.Time {
+	return time.Unix(0, atomic.LoadInt64(&c.offset)+t)
+}
+
+func (c *clockSync) run() {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			c.sync()
+		}
+	}
+}
+
+// sync updates the offset if the wall clock drifted, or was stepped, by more
+// than maxDrift since it was last updated.
+func (c *clockSync) sync() {
+	logger := log.Component(log.ComponentExporter)
+	offset, err := estimateBootTimeOffset()
+	if err != nil {
+		log.Error(logger, log.ErrClockSync, err, "could not estimate boot time offset")
+		return
+	}
+
+	drift := time.Duration(offset - atomic.LoadInt64(&c.offset))
+	if drift < 0 {
+		drift = -drift
+	}
+	if drift <= c.maxDrift {
+		return
+	}
+
+	atomic.StoreInt64(&c.offset, offset)
+	elapsed := time.Since(c.synced)
+	c.synced = time.Now()
+	if drift > elapsed/1e6*maxClockSlewPPM {
+		logger.V(0).Info("wall clock was stepped, updated boot time offset", "step", drift.String())
+		return
+	}
+	logger.V(1).Info("wall clock drifted, updated boot time offset", "drift", drift.String(), "since", elapsed.String())
+}
+
+func (c *clockSync) stop() {
+	c.stopOnce.Do(func() {
+		close(c.done)
+	})
+}
@@ -16,32 +16,92 @@ package opentelemetry
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"net/url"
 	"os"
-	"runtime"
+	"path"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/events"
 	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/log"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/process"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/version"
 	"github.com/prometheus/procfs"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
 	"go.opentelemetry.io/otel/trace"
-	"golang.org/x/sys/unix"
-	"google.golang.org/grpc"
 )
 
 const (
+	// ServiceNameFromTargetEnvVar derives service.name from the target even
+	// when OTEL_SERVICE_NAME is set, see WithServiceNameFromTarget.
+	ServiceNameFromTargetEnvVar = "OTEL_GO_AUTO_SERVICE_NAME_FROM_TARGET"
+
 	otelEndpointEnvVar    = "OTEL_EXPORTER_OTLP_ENDPOINT"
 	otelServiceNameEnvVar = "OTEL_SERVICE_NAME"
+
+	// instrumentationScopePrefix is prepended to the instrumented library
+	// name to form the instrumentation scope name of each probe.
+	instrumentationScopePrefix = "go.opentelemetry.io/auto/"
 )
 
+// libraryVersionKey records the version of the instrumented library the
+// span was produced from.
+var libraryVersionKey = attribute.Key("telemetry.auto.library.version")
+
 type Controller struct {
-	tracerProvider trace.TracerProvider
+	tracerProvider *sdktrace.TracerProvider
 	tracersMap     map[string]trace.Tracer
-	bootTime       int64
+	clock          *clockSync
+	exporter       *monitoredExporter
+
+	idGenerator IDGenerator
+	digests     *digestRecorder
+
+	sampler            sdktrace.Sampler
+	alwaysSampleErrors bool
+
+	serverErrorStatusCodes []StatusCodeRange
+	clientErrorStatusCodes []StatusCodeRange
+}
+
+// ExportFailures returns a channel reporting spans that could not be
+// exported after retrying.
+func (c *Controller) ExportFailures() <-chan ExportFailure {
+	return c.exporter.failures
+}
+
+// ExportedSpans returns the number of spans successfully exported.
+func (c *Controller) ExportedSpans() uint64 {
+	return atomic.LoadUint64(&c.exporter.exportedSpans)
+}
+
+// FailedSpans returns the number of spans that could not be exported.
+func (c *Controller) FailedSpans() uint64 {
+	return atomic.LoadUint64(&c.exporter.failedSpans)
+}
+
+// Shutdown exports the spans not exported yet and stops the exporter. No
+// span is exported once it returns.
+func (c *Controller) Shutdown(ctx context.Context) error {
+	c.clock.stop()
+	digestsErr := c.digests.stop(ctx)
+	tpErr := c.tracerProvider.Shutdown(ctx)
+	if digestsErr != nil && tpErr != nil {
+		return fmt.Errorf("stopping trace digests: %v; shutting down tracer provider: %w", digestsErr, tpErr)
+	}
+	if digestsErr != nil {
+		return digestsErr
+	}
+
+	return tpErr
 }
 
 func (c *Controller) getTracer(libName string) trace.Tracer {
@@ -50,97 +110,302 @@ func (c *Controller) getTracer(libName string) trace.Tracer {
 		return t
 	}
 
-	newTracer := c.tracerProvider.Tracer(libName)
+	newTracer := c.tracerProvider.Tracer(instrumentationScopePrefix+libName,
+		trace.WithInstrumentationVersion(version.Version()),
+		trace.WithSchemaURL(semconv.SchemaURL))
 	c.tracersMap[libName] = newTracer
 	return newTracer
 }
 
 func (c *Controller) Trace(event *events.Event) {
-	log.Logger.V(0).Info("got event", "attrs", event.Attributes)
+	log.Component(log.ComponentExporter).V(1).Info("got event", "attrs", event.Attributes)
 	ctx := context.Background()
 
 	if event.SpanContext == nil {
-		log.Logger.V(0).Info("got event without context - dropping")
+		log.Component(log.ComponentExporter).V(1).Info("got event without context - dropping")
 		return
 	}
+	if c.idGenerator != nil {
+		event = mapIDs(c.idGenerator, event)
+	}
 
 	// TODO: handle remote parent
 	if event.ParentSpanContext != nil {
 		ctx = trace.ContextWithSpanContext(ctx, *event.ParentSpanContext)
 	}
 
+	attrs := event.Attributes
+	if event.LibraryVersion != "" {
+		attrs = append(attrs, libraryVersionKey.String(event.LibraryVersion))
+	}
+
 	ctx = ContextWithEbpfEvent(ctx, *event)
-	_, span := c.getTracer(event.Library).
-		Start(ctx, event.Name,
-			trace.WithAttributes(event.Attributes...),
-			trace.WithSpanKind(event.Kind),
-			trace.WithTimestamp(c.convertTime(event.StartTime)))
-	span.End(trace.WithTimestamp(c.convertTime(event.EndTime)))
+	isError := isErrorStatus(event.Kind, attrs, c.serverErrorStatusCodes, c.clientErrorStatusCodes)
+	digested := c.digests.covers(event.Library)
+	if digested {
+		if !c.digests.record(event, attrs, isError) {
+			return
+		}
+		attrs = append(attrs, digestExemplarKey.Bool(true))
+	}
+	startOpts := []trace.SpanStartOption{
+		trace.WithAttributes(attrs...),
+		trace.WithSpanKind(event.Kind),
+		trace.WithTimestamp(c.convertTime(event.StartTime)),
+	}
+	if isError && c.alwaysSampleErrors {
+		var exemptOpts []trace.SpanStartOption
+		ctx, exemptOpts = c.exemptFromSampling(ctx, event, attrs)
+		startOpts = append(startOpts, exemptOpts...)
+	}
+
+	_, span := c.getTracer(event.Library).Start(ctx, event.Name, startOpts...)
+	for _, e := range event.SpanEvents {
+		span.AddEvent(e.Name,
+			trace.WithAttributes(e.Attributes...),
+			trace.WithTimestamp(c.convertTime(e.Time)))
+	}
+	if isError {
+		span.SetStatus(codes.Error, event.StatusDescription)
+	}
+	endTime := c.convertTime(event.EndTime)
+	span.End(trace.WithTimestamp(endTime))
+	if digested {
+		c.digests.offerExemplar(event, attrs, isError, span.SpanContext(), endTime)
+	}
 }
 
 func (c *Controller) convertTime(t int64) time.Time {
-	return time.Unix(0, c.bootTime+t)
+	return c.clock.convert(t)
 }
 
-func NewController() (*Controller, error) {
-	endpoint, exists := os.LookupEnv(otelEndpointEnvVar)
+// Option configures a Controller.
+type Option func(*config)
+
+type config struct {
+	serviceNameFromTarget bool
+
+	resourceAttributesFiles []string
+
+	tlsConfig *tls.Config
+	certFile  string
+	keyFile   string
+	caFile    string
+
+	proxyURL *url.URL
+
+	compression string
+
+	tracesExporter string
+
+	maxExportBatchBytes int
+
+	priorityExport bool
+	longSpan       time.Duration
+
+	spanMetrics bool
+
+	traceDigests map[string]DigestConfig
+
+	recentTraces int
+
+	idGenerator IDGenerator
+
+	clockSyncInterval time.Duration
+	clockMaxDrift     time.Duration
+
+	sampler            sdktrace.Sampler
+	alwaysSampleErrors bool
+
+	serverErrorStatusCodes []StatusCodeRange
+	clientErrorStatusCodes []StatusCodeRange
+}
+
+// WithServiceNameFromTarget derives service.name from the target module path
+// or executable name, even when OTEL_SERVICE_NAME is set. The name is always
+// derived from the target when OTEL_SERVICE_NAME is not set.
+func WithServiceNameFromTarget() Option {
+	return func(c *config) {
+		c.serviceNameFromTarget = true
+	}
+}
+
+func serviceNameFromTargetFromEnv() (Option, error) {
+	val, exists := os.LookupEnv(ServiceNameFromTargetEnvVar)
 	if !exists {
-		return nil, fmt.Errorf("%s env var must be set", otelEndpointEnvVar)
+		return nil, nil
+	}
+
+	enabled, err := strconv.ParseBool(val)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported %s value %q", ServiceNameFromTargetEnvVar, val)
+	}
+	if !enabled {
+		return nil, nil
+	}
+
+	return WithServiceNameFromTarget(), nil
+}
+
+// newConfig returns the configuration set by opts.
+func newConfig(opts []Option) config {
+	cfg := config{
+		clockSyncInterval:      defaultClockSyncInterval,
+		clockMaxDrift:          defaultClockMaxDrift,
+		sampler:                sdktrace.AlwaysSample(),
+		serverErrorStatusCodes: defaultServerErrorStatusCodes,
+		clientErrorStatusCodes: defaultClientErrorStatusCodes,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
 	}
 
+	return cfg
+}
+
+// newResource returns the resource of the spans of target.
+func newResource(ctx context.Context, target *process.TargetDetails, cfg *config) (*resource.Resource, error) {
 	serviceName, exists := os.LookupEnv(otelServiceNameEnvVar)
-	if !exists {
-		return nil, fmt.Errorf("%s env var must be set", otelServiceNameEnvVar)
+	if !exists || cfg.serviceNameFromTarget {
+		serviceName = serviceNameFromTarget(target)
+		log.Component(log.ComponentExporter).V(0).Info("using service name derived from target", "service_name", serviceName)
 	}
 
-	ctx := context.Background()
-	res, err := resource.New(ctx,
+	fileAttrs, err := cfg.fileResourceAttributes()
+	if err != nil {
+		return nil, err
+	}
+
+	return resource.New(ctx,
+		resource.WithAttributes(fileAttrs...),
 		resource.WithAttributes(
 			semconv.ServiceNameKey.String(serviceName),
 			semconv.TelemetrySDKLanguageGo,
 		),
+		resource.WithAttributes(processAttributes(target)...),
+		resource.WithSchemaURL(semconv.SchemaURL),
 	)
-	if err != nil {
-		return nil, err
-	}
+}
 
-	log.Logger.V(0).Info("Establishing connection to OpenTelemetry collector ...")
-	timeoutContext, cancel := context.WithTimeout(ctx, time.Second*10)
-	defer cancel()
-	conn, err := grpc.DialContext(timeoutContext, endpoint, grpc.WithInsecure(), grpc.WithBlock())
+func NewController(target *process.TargetDetails, opts ...Option) (*Controller, error) {
+	cfg := newConfig(opts)
+	ctx := context.Background()
+	res, err := newResource(ctx, target, &cfg)
 	if err != nil {
-		log.Logger.Error(err, "unable to connect to OpenTelemetry collector", "addr", endpoint)
 		return nil, err
 	}
 
-	traceExporter, err := otlptracegrpc.New(ctx,
-		otlptracegrpc.WithGRPCConn(conn),
-	)
-
+	exporterName, err := cfg.tracesExporterName()
 	if err != nil {
 		return nil, err
 	}
 
-	bsp := sdktrace.NewBatchSpanProcessor(traceExporter)
-	tracerProvider := sdktrace.NewTracerProvider(
-		sdktrace.WithSampler(sdktrace.AlwaysSample()),
+	tpOpts := []sdktrace.TracerProviderOption{
 		sdktrace.WithResource(res),
-		sdktrace.WithSpanProcessor(bsp),
 		sdktrace.WithIDGenerator(NewEbpfSourceIDGenerator()),
-	)
+	}
+	// The processors created so far are shut down, with their exporters, if
+	// the controller cannot be created.
+	var processors []sdktrace.SpanProcessor
+	var digests *digestRecorder
+	shutdown := func() {
+		for _, p := range processors {
+			_ = p.Shutdown(ctx)
+		}
+		_ = digests.stop(ctx)
+	}
+	// Without trace export, the exporter is never called: it only reports
+	// no exported span.
+	exporter := newMonitoredExporter(nil)
+	if exporterName != NoTracesExporter {
+		spanExporter, err := newSpanExporter(ctx, &cfg, exporterName)
+		if err != nil {
+			return nil, err
+		}
 
-	bt, err := estimateBootTimeOffset()
+		exporter = newMonitoredExporter(spanExporter)
+		var bsp sdktrace.SpanProcessor
+		if exporterName == PrettyTracesExporter {
+			// Spans are printed as soon as their trace completes.
+			bsp = sdktrace.NewSimpleSpanProcessor(exporter)
+		} else if cfg.priorityExport {
+			bsp = newPrioritySpanProcessor(exporter, cfg.longSpan)
+		} else {
+			bsp = sdktrace.NewBatchSpanProcessor(exporter)
+		}
+		sampler := cfg.sampler
+		if cfg.alwaysSampleErrors {
+			sampler = exemptingSampler{sampler: cfg.sampler}
+		}
+		processors = append(processors, bsp)
+		tpOpts = append(tpOpts, sdktrace.WithSampler(sampler), sdktrace.WithSpanProcessor(bsp))
+	} else {
+		// Every span is recorded for the metrics, the head sampler only
+		// applies to exported spans.
+		log.Component(log.ComponentExporter).V(0).Info("trace export disabled, exporting span metrics only")
+		cfg.spanMetrics = true
+		cfg.alwaysSampleErrors = false
+		tpOpts = append(tpOpts, sdktrace.WithSampler(sdktrace.AlwaysSample()))
+	}
+	if cfg.spanMetrics {
+		smp, err := newSpanMetricsProcessor(ctx, &cfg, res)
+		if err != nil {
+			shutdown()
+			return nil, err
+		}
+		processors = append(processors, smp)
+		tpOpts = append(tpOpts, sdktrace.WithSpanProcessor(smp))
+	}
+	if cfg.recentTraces > 0 {
+		tpOpts = append(tpOpts, sdktrace.WithSpanProcessor(newRecentTracesProcessor(cfg.recentTraces)))
+	}
+	if len(cfg.traceDigests) > 0 {
+		digests, err = newDigestRecorder(ctx, &cfg, res)
+		if err != nil {
+			shutdown()
+			return nil, err
+		}
+	}
+	clock, err := newClockSync(cfg.clockSyncInterval, cfg.clockMaxDrift)
 	if err != nil {
+		shutdown()
 		return nil, err
 	}
+	tracerProvider := sdktrace.NewTracerProvider(tpOpts...)
 
 	return &Controller{
 		tracerProvider: tracerProvider,
 		tracersMap:     make(map[string]trace.Tracer),
-		bootTime:       bt,
+		clock:          clock,
+		exporter:       exporter,
+
+		idGenerator: cfg.idGenerator,
+		digests:     digests,
+
+		sampler:            cfg.sampler,
+		alwaysSampleErrors: cfg.alwaysSampleErrors,
+
+		serverErrorStatusCodes: cfg.serverErrorStatusCodes,
+		clientErrorStatusCodes: cfg.clientErrorStatusCodes,
 	}, nil
 }
 
+// serviceNameFromTarget returns the last element of the target main module
+// path, or the target executable name if the module is unknown. Programs
+// built from files rather than a module, as with go build main.go, have the
+// command-line-arguments main module.
+func serviceNameFromTarget(target *process.TargetDetails) string {
+	if target.MainModule != "" && target.MainModule != "command-line-arguments" {
+		return path.Base(target.MainModule)
+	}
+
+	if target.ExePath != "" {
+		return filepath.Base(target.ExePath)
+	}
+
+	return "unknown_service:go"
+}
+
 func getBootTime() (*time.Time, error) {
 	fs, err := procfs.NewDefaultFS()
 	if err != nil {
@@ -155,59 +420,3 @@ func getBootTime() (*time.Time, error) {
 	boot := time.Unix(int64(stat.BootTime), 0)
 	return &boot, nil
 }
-
-func getBootTimeSyscall() (int64, error) {
-	var ts unix.Timespec
-	err := unix.ClockGettime(unix.CLOCK_MONOTONIC, &ts)
-	now := time.Now().UnixNano()
-	if err != nil {
-		return 0, fmt.Errorf("could not get boot time: %s", err)
-	}
-
-	return now - unix.TimespecToNsec(ts), nil
-}
-
-func estimateBootTimeOffset() (bootTimeOffset int64, err error) {
-	// The datapath is currently using ktime_get_boot_ns for the pcap timestamp,
-	// which corresponds to CLOCK_BOOTTIME. To be able to convert the the
-	// CLOCK_BOOTTIME to CLOCK_REALTIME (i.e. a unix timestamp).
-
-	// There can be an arbitrary amount of time between the execution of
-	// time.Now() and unix.ClockGettime() below, especially under scheduler
-	// pressure during program startup. To reduce the error introduced by these
-	// delays, we pin the current Go routine to its OS thread and measure the
-	// clocks multiple times, taking only the smallest observed difference
-	// between the two values (which implies the smallest possible delay
-	// between the two snapshots).
-	var minDiff int64 = 1<<63 - 1
-	estimationRounds := 25
-	runtime.LockOSThread()
-	defer runtime.UnlockOSThread()
-	for round := 0; round < estimationRounds; round++ {
-		var bootTimespec unix.Timespec
-
-		// Ideally we would use __vdso_clock_gettime for both clocks here,
-		// to have as little overhead as possible.
-		// time.Now() will actually use VDSO on Go 1.9+, but calling
-		// unix.ClockGettime to obtain CLOCK_BOOTTIME is a regular system call
-		// for now.
-		unixTime := time.Now()
-		err = unix.ClockGettime(unix.CLOCK_BOOTTIME, &bootTimespec)
-		if err != nil {
-			return 0, err
-		}
-
-		offset := unixTime.UnixNano() - bootTimespec.Nano()
-		diff := offset
-		if diff < 0 {
-			diff = -diff
-		}
-
-		if diff < minDiff {
-			minDiff = diff
-			bootTimeOffset = offset
-		}
-	}
-
-	return bootTimeOffset, nil
-}
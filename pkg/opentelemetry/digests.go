@@ -0,0 +1,251 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opentelemetry
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/events"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TraceDigestsEnvVar is a comma separated list of the probes exported as
+// trace digests, each as library=window:exemplars, such as
+// "net/http=1m:3", see WithTraceDigests.
+const TraceDigestsEnvVar = "OTEL_GO_AUTO_TRACE_DIGESTS"
+
+// digestExemplarKey marks the spans exported as exemplars of a digest.
+var digestExemplarKey = attribute.Key("telemetry.auto.digest.exemplar")
+
+// DigestConfig configures the trace digests of a probe.
+type DigestConfig struct {
+	// Window is the period digests are exported at.
+	Window time.Duration
+	// Exemplars is the number of spans exported per window for each span
+	// name, kind, status and HTTP route of the probe.
+	Exemplars int
+}
+
+// WithTraceDigests exports the spans of the probes of the libraries in cfg
+// as trace digests: the RED metrics of their spans, per span name, kind,
+// status and HTTP route, exported every window, and a few exemplar spans per
+// window. The spans of other probes are exported as usual. It is meant for
+// services with a request rate too high for even sampled spans to be
+// exported.
+func WithTraceDigests(cfg map[string]DigestConfig) Option {
+	return func(c *config) {
+		c.traceDigests = cfg
+	}
+}
+
+func traceDigestsFromEnv() (Option, error) {
+	val, exists := os.LookupEnv(TraceDigestsEnvVar)
+	if !exists {
+		return nil, nil
+	}
+
+	cfg := make(map[string]DigestConfig)
+	for _, entry := range strings.Split(val, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		library, dc, ok := parseDigestConfig(entry)
+		if !ok {
+			return nil, fmt.Errorf("unsupported %s value %q", TraceDigestsEnvVar, val)
+		}
+		cfg[library] = dc
+	}
+	if len(cfg) == 0 {
+		return nil, nil
+	}
+
+	return WithTraceDigests(cfg), nil
+}
+
+// parseDigestConfig parses the library=window:exemplars entry of
+// TraceDigestsEnvVar.
+func parseDigestConfig(entry string) (string, DigestConfig, bool) {
+	parts := strings.SplitN(entry, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return "", DigestConfig{}, false
+	}
+
+	settings := strings.SplitN(parts[1], ":", 2)
+	if len(settings) != 2 {
+		return "", DigestConfig{}, false
+	}
+
+	window, err := time.ParseDuration(settings[0])
+	if err != nil || window <= 0 {
+		return "", DigestConfig{}, false
+	}
+
+	exemplars, err := strconv.Atoi(settings[1])
+	if err != nil || exemplars < 0 {
+		return "", DigestConfig{}, false
+	}
+
+	return parts[0], DigestConfig{Window: window, Exemplars: exemplars}, true
+}
+
+// digestKey identifies the digest a span is aggregated into.
+type digestKey struct {
+	library string
+	name    string
+	kind    string
+	status  string
+	route   string
+}
+
+// digest aggregates the spans of the probe of a library.
+type digest struct {
+	config   DigestConfig
+	pipeline *metricPipeline
+}
+
+// digestRecorder records the spans of the probes exported as digests.
+type digestRecorder struct {
+	digests map[string]*digest
+
+	mu          sync.Mutex
+	windowStart map[string]time.Time
+	exemplars   map[digestKey]int
+}
+
+func newDigestRecorder(ctx context.Context, cfg *config, res *resource.Resource) (*digestRecorder, error) {
+	r := &digestRecorder{
+		digests:     make(map[string]*digest, len(cfg.traceDigests)),
+		windowStart: make(map[string]time.Time, len(cfg.traceDigests)),
+		exemplars:   make(map[digestKey]int),
+	}
+	for library, dc := range cfg.traceDigests {
+		if dc.Window <= 0 || dc.Exemplars < 0 {
+			r.stop(ctx)
+			return nil, fmt.Errorf("invalid trace digest configuration of %s: %+v", library, dc)
+		}
+
+		pipeline, err := newMetricPipeline(ctx, cfg, res, dc.Window, "digests/"+library)
+		if err != nil {
+			r.stop(ctx)
+			return nil, err
+		}
+
+		r.digests[library] = &digest{config: dc, pipeline: pipeline}
+	}
+
+	return r, nil
+}
+
+// covers reports whether the spans of library are exported as digests.
+func (r *digestRecorder) covers(library string) bool {
+	if r == nil {
+		return false
+	}
+
+	_, exists := r.digests[library]
+	return exists
+}
+
+// record aggregates the span of event, with attrs, into its digest and
+// reports whether it is exported as an exemplar of the current window.
+func (r *digestRecorder) record(event *events.Event, attrs []attribute.KeyValue, isError bool) bool {
+	d := r.digests[event.Library]
+	key, metricAttrs := digestAttributes(event, attrs, isError)
+	d.pipeline.record(metricAttrs, time.Duration(event.EndTime-event.StartTime))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	if now.Sub(r.windowStart[event.Library]) >= d.config.Window {
+		r.windowStart[event.Library] = now
+		for k := range r.exemplars {
+			if k.library == event.Library {
+				delete(r.exemplars, k)
+			}
+		}
+	}
+
+	if r.exemplars[key] >= d.config.Exemplars {
+		return false
+	}
+	r.exemplars[key]++
+	return true
+}
+
+// offerExemplar offers the exported span of event, started with attrs and
+// with the span context sc, as an exemplar of the duration histogram of its
+// digest.
+func (r *digestRecorder) offerExemplar(event *events.Event, attrs []attribute.KeyValue, isError bool, sc trace.SpanContext, end time.Time) {
+	_, metricAttrs := digestAttributes(event, attrs, isError)
+	r.digests[event.Library].pipeline.exemplars.offer(metricAttrs, time.Duration(event.EndTime-event.StartTime), end, sc)
+}
+
+// digestAttributes returns the key of the digest of the span of event, and
+// the attributes of its metrics.
+func digestAttributes(event *events.Event, attrs []attribute.KeyValue, isError bool) (digestKey, []attribute.KeyValue) {
+	statusCode := codes.Unset
+	if isError {
+		statusCode = codes.Error
+	}
+
+	key := digestKey{
+		library: event.Library,
+		name:    event.Name,
+		kind:    event.Kind.String(),
+		status:  statusCode.String(),
+	}
+	metricAttrs := []attribute.KeyValue{
+		spanNameKey.String(key.name),
+		spanKindKey.String(key.kind),
+		statusCodeKey.String(key.status),
+	}
+	for _, kv := range attrs {
+		if kv.Key == semconv.HTTPRouteKey {
+			key.route = kv.Value.AsString()
+			metricAttrs = append(metricAttrs, kv)
+			break
+		}
+	}
+
+	return key, metricAttrs
+}
+
+// stop exports the digests of the current windows and stops exporting them.
+func (r *digestRecorder) stop(ctx context.Context) error {
+	if r == nil {
+		return nil
+	}
+
+	var firstErr error
+	for _, d := range r.digests {
+		if err := d.pipeline.stop(ctx); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
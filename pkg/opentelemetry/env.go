@@ -0,0 +1,48 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opentelemetry
+
+// envOptions read the options configured by OTEL_GO_AUTO_* environment
+// variables, returning a nil Option when their variable is not set.
+var envOptions = []func() (Option, error){
+	spanMetricsFromEnv,
+	httpServerErrorStatusCodesFromEnv,
+	httpClientErrorStatusCodesFromEnv,
+	samplerFromEnv,
+	alwaysSampleErrorsFromEnv,
+	maxExportBatchBytesFromEnv,
+	tlsConfigFromEnv,
+	serviceNameFromTargetFromEnv,
+	priorityExportFromEnv,
+	traceDigestsFromEnv,
+	proxyFromEnv,
+}
+
+// OptionsFromEnv returns the options configured by the OTEL_GO_AUTO_*
+// environment variables.
+func OptionsFromEnv() ([]Option, error) {
+	var opts []Option
+	for _, fromEnv := range envOptions {
+		opt, err := fromEnv()
+		if err != nil {
+			return nil, err
+		}
+		if opt != nil {
+			opts = append(opts, opt)
+		}
+	}
+
+	return opts, nil
+}
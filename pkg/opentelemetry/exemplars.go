@@ -0,0 +1,167 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opentelemetry
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric"
+	"go.opentelemetry.io/otel/trace"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+)
+
+// maxExemplarCandidates is the number of recent spans kept per attribute set
+// to pick the exemplars of a histogram data point from.
+const maxExemplarCandidates = 32
+
+// exemplar is a sampled span recorded in a duration histogram.
+type exemplar struct {
+	value       float64
+	time        time.Time
+	spanContext trace.SpanContext
+}
+
+// exemplarReservoir keeps the recent sampled spans recorded in the duration
+// histograms of a metric exporter, per attribute set, until the histograms
+// are exported. The metric SDK does not support exemplars, they are added to
+// the exported data points by exemplarClient.
+type exemplarReservoir struct {
+	mu         sync.Mutex
+	candidates map[attribute.Distinct][]exemplar
+}
+
+func newExemplarReservoir() *exemplarReservoir {
+	return &exemplarReservoir{candidates: make(map[attribute.Distinct][]exemplar)}
+}
+
+// offer keeps the span of sc, recorded with attrs, as an exemplar candidate
+// if it is sampled. Only the most recent candidates of an attribute set are
+// kept. A nil reservoir keeps no exemplar.
+func (r *exemplarReservoir) offer(attrs []attribute.KeyValue, elapsed time.Duration, end time.Time, sc trace.SpanContext) {
+	if r == nil || !sc.IsSampled() {
+		return
+	}
+
+	set := attribute.NewSet(attrs...)
+	key := set.Equivalent()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	candidates := r.candidates[key]
+	if len(candidates) >= maxExemplarCandidates {
+		candidates = append(candidates[:0], candidates[1:]...)
+	}
+	r.candidates[key] = append(candidates, exemplar{
+		value:       durationMillis(elapsed),
+		time:        end,
+		spanContext: sc,
+	})
+}
+
+// take returns and forgets the candidates of set.
+func (r *exemplarReservoir) take(set attribute.Set) []exemplar {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := set.Equivalent()
+	candidates := r.candidates[key]
+	delete(r.candidates, key)
+	return candidates
+}
+
+// annotate adds to each data point of the duration histograms of rm the most
+// recent candidate of each of its buckets.
+func (r *exemplarReservoir) annotate(rm *metricpb.ResourceMetrics) {
+	if r == nil {
+		return
+	}
+
+	for _, sm := range rm.GetScopeMetrics() {
+		for _, m := range sm.GetMetrics() {
+			if m.GetName() != "duration" || m.GetHistogram() == nil {
+				continue
+			}
+
+			for _, dp := range m.GetHistogram().GetDataPoints() {
+				candidates := r.take(dataPointSet(dp))
+				dp.Exemplars = append(dp.Exemplars, bucketExemplars(dp.GetExplicitBounds(), candidates)...)
+			}
+		}
+	}
+}
+
+// dataPointSet returns the attribute set of dp. Only the string attributes of
+// span metrics are kept.
+func dataPointSet(dp *metricpb.HistogramDataPoint) attribute.Set {
+	attrs := make([]attribute.KeyValue, 0, len(dp.GetAttributes()))
+	for _, kv := range dp.GetAttributes() {
+		if v, ok := kv.GetValue().GetValue().(*commonpb.AnyValue_StringValue); ok {
+			attrs = append(attrs, attribute.String(kv.GetKey(), v.StringValue))
+		}
+	}
+
+	return attribute.NewSet(attrs...)
+}
+
+// bucketExemplars returns the most recent of candidates of each bucket of the
+// histogram with bounds.
+func bucketExemplars(bounds []float64, candidates []exemplar) []*metricpb.Exemplar {
+	latest := make(map[int]exemplar)
+	for _, e := range candidates {
+		bucket := len(bounds)
+		for i, b := range bounds {
+			if e.value <= b {
+				bucket = i
+				break
+			}
+		}
+		latest[bucket] = e
+	}
+
+	result := make([]*metricpb.Exemplar, 0, len(latest))
+	for i := 0; i <= len(bounds); i++ {
+		e, exists := latest[i]
+		if !exists {
+			continue
+		}
+
+		traceID := e.spanContext.TraceID()
+		spanID := e.spanContext.SpanID()
+		result = append(result, &metricpb.Exemplar{
+			TimeUnixNano: uint64(e.time.UnixNano()),
+			Value:        &metricpb.Exemplar_AsDouble{AsDouble: e.value},
+			TraceId:      traceID[:],
+			SpanId:       spanID[:],
+		})
+	}
+
+	return result
+}
+
+// exemplarClient adds the exemplars of its reservoir to the metrics it
+// uploads.
+type exemplarClient struct {
+	otlpmetric.Client
+	exemplars *exemplarReservoir
+}
+
+var _ otlpmetric.Client = (*exemplarClient)(nil)
+
+func (c *exemplarClient) UploadMetrics(ctx context.Context, rm *metricpb.ResourceMetrics) error {
+	c.exemplars.annotate(rm)
+	return c.Client.UploadMetrics(ctx, rm)
+}
@@ -0,0 +1,90 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opentelemetry
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/diagnostics"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/log"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const exportFailuresBuffer = 16
+
+// exportedSpansKey records the batch size of self-diagnostics export spans.
+var exportedSpansKey = attribute.Key("telemetry.auto.export.spans")
+
+// ExportFailure reports spans dropped because exporting them still failed
+// after retrying.
+type ExportFailure struct {
+	Time  time.Time
+	Spans int
+	Err   error
+}
+
+// monitoredExporter counts exported and failed spans and reports export
+// failures.
+type monitoredExporter struct {
+	sdktrace.SpanExporter
+	failures chan ExportFailure
+
+	exportedSpans uint64
+	failedSpans   uint64
+}
+
+func newMonitoredExporter(exporter sdktrace.SpanExporter) *monitoredExporter {
+	return &monitoredExporter{
+		SpanExporter: exporter,
+		failures:     make(chan ExportFailure, exportFailuresBuffer),
+	}
+}
+
+func (e *monitoredExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	span := diagnostics.StartOperation(nil, "export", exportedSpansKey.Int(len(spans)))
+	err := e.SpanExporter.ExportSpans(ctx, spans)
+	diagnostics.EndOperation(span, err)
+	if err == nil {
+		atomic.AddUint64(&e.exportedSpans, uint64(len(spans)))
+		return nil
+	}
+
+	// Batches split into several requests may only partially fail.
+	failedSpans := len(spans)
+	var partialErr *partialExportError
+	if errors.As(err, &partialErr) {
+		failedSpans = partialErr.failedSpans
+		atomic.AddUint64(&e.exportedSpans, uint64(len(spans)-failedSpans))
+	}
+
+	failed := atomic.AddUint64(&e.failedSpans, uint64(failedSpans))
+	log.Error(log.Component(log.ComponentExporter), log.ErrExport, err, "failed to export spans", "spans", failedSpans, "failed_spans_total", failed)
+
+	failure := ExportFailure{
+		Time:  time.Now(),
+		Spans: failedSpans,
+		Err:   err,
+	}
+	select {
+	case e.failures <- failure:
+	default:
+	}
+
+	return err
+}
@@ -0,0 +1,222 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opentelemetry
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/log"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+const (
+	otelTracesEndpointEnvVar = "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"
+	otelTracesExporterEnvVar = "OTEL_TRACES_EXPORTER"
+	otelProtocolEnvVar       = "OTEL_EXPORTER_OTLP_PROTOCOL"
+	otelTracesProtocolEnvVar = "OTEL_EXPORTER_OTLP_TRACES_PROTOCOL"
+
+	otelCompressionEnvVar       = "OTEL_EXPORTER_OTLP_COMPRESSION"
+	otelTracesCompressionEnvVar = "OTEL_EXPORTER_OTLP_TRACES_COMPRESSION"
+
+	// grpcProtocol is the only OTLP protocol supported: there is no OTLP/HTTP
+	// exporter, so unix:// endpoints are only dialed over gRPC.
+	grpcProtocol = "grpc"
+
+	connectTimeout = 10 * time.Second
+
+	// Failed exports are retried with exponential backoff for up to
+	// retryMaxElapsedTime on retryable errors, as defined by the OTLP spec.
+	retryInitialInterval = 5 * time.Second
+	retryMaxInterval     = 30 * time.Second
+	retryMaxElapsedTime  = time.Minute
+	unixScheme           = "unix://"
+)
+
+// Compression algorithms supported by WithCompression.
+const (
+	GzipCompression = "gzip"
+	NoCompression   = "none"
+)
+
+// WithCompression sets the compression used to export spans, taking
+// precedence over OTEL_EXPORTER_OTLP_COMPRESSION. Only gzip is supported over
+// gRPC, zstd is not supported as there is no OTLP/HTTP exporter.
+func WithCompression(compression string) Option {
+	return func(c *config) {
+		c.compression = compression
+	}
+}
+
+// Span exporters supported by WithTracesExporter.
+const (
+	OTLPTracesExporter = "otlp"
+	NoTracesExporter   = "none"
+	// PrettyTracesExporter prints the span trees of completed traces to
+	// stdout, for local development.
+	PrettyTracesExporter = "pretty"
+)
+
+// WithTracesExporter sets the exporter of spans, taking precedence over
+// OTEL_TRACES_EXPORTER. With NoTracesExporter, no span is exported and span
+// metrics are enabled: the probes only feed the metrics, for users who only
+// need RED metrics.
+func WithTracesExporter(exporter string) Option {
+	return func(c *config) {
+		c.tracesExporter = exporter
+	}
+}
+
+// tracesExporterName returns the exporter of spans, as set by
+// WithTracesExporter or OTEL_TRACES_EXPORTER.
+func (c *config) tracesExporterName() (string, error) {
+	exporter := c.tracesExporter
+	if exporter == "" {
+		exporter = os.Getenv(otelTracesExporterEnvVar)
+	}
+
+	switch exporter {
+	case "":
+		return OTLPTracesExporter, nil
+	case OTLPTracesExporter, NoTracesExporter, PrettyTracesExporter:
+		return exporter, nil
+	default:
+		return "", fmt.Errorf("unsupported %s value %q", otelTracesExporterEnvVar, exporter)
+	}
+}
+
+// exportsTraces reports whether spans are exported, as set by
+// WithTracesExporter or OTEL_TRACES_EXPORTER.
+func (c *config) exportsTraces() (bool, error) {
+	exporter, err := c.tracesExporterName()
+	if err != nil {
+		return false, err
+	}
+
+	return exporter != NoTracesExporter, nil
+}
+
+// newSpanExporter creates the span exporter named exporter.
+func newSpanExporter(ctx context.Context, cfg *config, exporter string) (sdktrace.SpanExporter, error) {
+	if exporter == PrettyTracesExporter {
+		return newPrettyExporter(os.Stdout), nil
+	}
+
+	traceExporter, err := newTraceExporter(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return newSizeLimitedExporter(traceExporter, cfg.maxExportBatchBytes), nil
+}
+
+// newTraceExporter creates an OTLP/gRPC exporter configured from the
+// OTEL_EXPORTER_OTLP_* and OTEL_EXPORTER_OTLP_TRACES_* environment variables
+// (endpoint, headers, timeout, compression and certificate).
+func newTraceExporter(ctx context.Context, cfg *config) (*otlptrace.Exporter, error) {
+	endpoint, baseExists := os.LookupEnv(otelEndpointEnvVar)
+	tracesEndpoint, tracesExists := os.LookupEnv(otelTracesEndpointEnvVar)
+	if !baseExists && !tracesExists {
+		return nil, fmt.Errorf("%s or %s env var must be set", otelEndpointEnvVar, otelTracesEndpointEnvVar)
+	}
+	if tracesExists {
+		endpoint = tracesEndpoint
+	}
+
+	if protocol := lookupOTLPEnv(otelProtocolEnvVar, otelTracesProtocolEnvVar); protocol != "" && protocol != grpcProtocol {
+		return nil, fmt.Errorf("unsupported OTLP protocol %q, only %s is supported", protocol, grpcProtocol)
+	}
+
+	opts := []otlptracegrpc.Option{
+		otlptracegrpc.WithDialOption(grpc.WithBlock()),
+		otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{
+			Enabled:         true,
+			InitialInterval: retryInitialInterval,
+			MaxInterval:     retryMaxInterval,
+			MaxElapsedTime:  retryMaxElapsedTime,
+		}),
+	}
+
+	// The exporter reads OTEL_EXPORTER_OTLP_COMPRESSION itself, but silently
+	// exports uncompressed spans for the values it does not support.
+	if cfg.compression == "" {
+		switch compression := lookupOTLPEnv(otelCompressionEnvVar, otelTracesCompressionEnvVar); compression {
+		case "", GzipCompression, NoCompression:
+		default:
+			return nil, fmt.Errorf("unsupported compression %q, only %s is supported over OTLP/gRPC", compression, GzipCompression)
+		}
+	}
+
+	switch cfg.compression {
+	case "":
+	case GzipCompression:
+		opts = append(opts, otlptracegrpc.WithCompressor(GzipCompression))
+	case NoCompression:
+		opts = append(opts, otlptracegrpc.WithCompressor(""))
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", cfg.compression)
+	}
+
+	if cfg.proxyURL != nil && !strings.HasPrefix(endpoint, unixScheme) {
+		opts = append(opts, otlptracegrpc.WithDialOption(grpc.WithContextDialer(proxyDialer(cfg.proxyURL))))
+	}
+
+	tlsConfig, err := cfg.buildTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		opts = append(opts, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(tlsConfig)))
+	}
+
+	// Endpoints without a scheme (e.g. "collector:4317") are not valid URLs
+	// for the exporter, keep dialing them without TLS unless it is configured.
+	// Unix domain sockets (e.g. "unix:///var/run/otel.sock") have no host and
+	// are passed as is to the gRPC dialer, which resolves the unix scheme.
+	if !strings.Contains(endpoint, "://") || strings.HasPrefix(endpoint, unixScheme) {
+		opts = append(opts, otlptracegrpc.WithEndpoint(endpoint))
+		if tlsConfig == nil {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+	}
+
+	log.Component(log.ComponentExporter).V(0).Info("Establishing connection to OpenTelemetry collector ...")
+	timeoutContext, cancel := context.WithTimeout(ctx, connectTimeout)
+	defer cancel()
+	exporter, err := otlptracegrpc.New(timeoutContext, opts...)
+	if err != nil {
+		log.Error(log.Component(log.ComponentExporter), log.ErrExporterConnect, err, "unable to connect to OpenTelemetry collector", "addr", endpoint)
+		return nil, err
+	}
+
+	return exporter, nil
+}
+
+// lookupOTLPEnv returns the value of the traces specific variable
+// tracesEnvVar, or of envVar if it is not set.
+func lookupOTLPEnv(envVar string, tracesEnvVar string) string {
+	if val, exists := os.LookupEnv(tracesEnvVar); exists {
+		return val
+	}
+
+	return os.Getenv(envVar)
+}
@@ -0,0 +1,209 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opentelemetry
+
+import (
+	"context"
+
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/events"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/log"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/process"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/version"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// OTLPHandler serializes each probe event to an OTLP/protobuf
+// ExportTraceServiceRequest holding its span, and hands the bytes to a
+// callback. It lets embedders with their own transport skip the SDK and the
+// exporters of the Controller: register its Handle method with
+// SetRawEventHandler and Run the manager with a nil controller.
+//
+// Spans are neither sampled nor batched. The resource, ID generator, clock
+// synchronization and error status code options apply, the others are
+// ignored.
+type OTLPHandler struct {
+	send     func([]byte) error
+	resource *resourcepb.Resource
+	clock    *clockSync
+
+	idGenerator IDGenerator
+
+	serverErrorStatusCodes []StatusCodeRange
+	clientErrorStatusCodes []StatusCodeRange
+}
+
+// NewOTLPHandler returns an OTLPHandler for the events of target, handing
+// the serialized requests to send. Errors returned by send are logged, the
+// span is dropped.
+func NewOTLPHandler(target *process.TargetDetails, send func([]byte) error, opts ...Option) (*OTLPHandler, error) {
+	cfg := newConfig(opts)
+	res, err := newResource(context.Background(), target, &cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	clock, err := newClockSync(cfg.clockSyncInterval, cfg.clockMaxDrift)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OTLPHandler{
+		send:     send,
+		resource: &resourcepb.Resource{Attributes: keyValues(res.Attributes())},
+		clock:    clock,
+
+		idGenerator: cfg.idGenerator,
+
+		serverErrorStatusCodes: cfg.serverErrorStatusCodes,
+		clientErrorStatusCodes: cfg.clientErrorStatusCodes,
+	}, nil
+}
+
+// Handle serializes the span of event and hands it to the callback of h. It
+// has the signature of a raw event handler of the instrumentors manager.
+func (h *OTLPHandler) Handle(library string, event *events.Event) {
+	logger := log.Component(log.ComponentExporter)
+	if event.SpanContext == nil {
+		logger.V(0).Info("got event without context - dropping")
+		return
+	}
+	if h.idGenerator != nil {
+		event = mapIDs(h.idGenerator, event)
+	}
+
+	data, err := proto.Marshal(h.request(library, event))
+	if err != nil {
+		log.Error(logger, log.ErrExport, err, "could not serialize span", "library", library)
+		return
+	}
+
+	if err := h.send(data); err != nil {
+		log.Error(logger, log.ErrExport, err, "could not send span", "library", library)
+	}
+}
+
+// Close stops the clock synchronization of h.
+func (h *OTLPHandler) Close() {
+	h.clock.stop()
+}
+
+func (h *OTLPHandler) request(library string, event *events.Event) *collectortrace.ExportTraceServiceRequest {
+	attrs := event.Attributes
+	if event.LibraryVersion != "" {
+		attrs = append(attrs, libraryVersionKey.String(event.LibraryVersion))
+	}
+
+	traceID := event.SpanContext.TraceID()
+	spanID := event.SpanContext.SpanID()
+	span := &tracepb.Span{
+		TraceId:           traceID[:],
+		SpanId:            spanID[:],
+		TraceState:        event.SpanContext.TraceState().String(),
+		Name:              event.Name,
+		Kind:              tracepb.Span_SpanKind(event.Kind),
+		StartTimeUnixNano: uint64(h.clock.convert(event.StartTime).UnixNano()),
+		EndTimeUnixNano:   uint64(h.clock.convert(event.EndTime).UnixNano()),
+		Attributes:        keyValues(attrs),
+	}
+	if event.ParentSpanContext != nil {
+		parentID := event.ParentSpanContext.SpanID()
+		span.ParentSpanId = parentID[:]
+	}
+	for _, e := range event.SpanEvents {
+		span.Events = append(span.Events, &tracepb.Span_Event{
+			TimeUnixNano: uint64(h.clock.convert(e.Time).UnixNano()),
+			Name:         e.Name,
+			Attributes:   keyValues(e.Attributes),
+		})
+	}
+	if isErrorStatus(event.Kind, attrs, h.serverErrorStatusCodes, h.clientErrorStatusCodes) {
+		span.Status = &tracepb.Status{Code: tracepb.Status_STATUS_CODE_ERROR, Message: event.StatusDescription}
+	}
+
+	return &collectortrace.ExportTraceServiceRequest{
+		ResourceSpans: []*tracepb.ResourceSpans{{
+			Resource: h.resource,
+			ScopeSpans: []*tracepb.ScopeSpans{{
+				Scope: &commonpb.InstrumentationScope{
+					Name:    instrumentationScopePrefix + library,
+					Version: version.Version(),
+				},
+				Spans:     []*tracepb.Span{span},
+				SchemaUrl: semconv.SchemaURL,
+			}},
+			SchemaUrl: semconv.SchemaURL,
+		}},
+	}
+}
+
+// keyValues converts attrs to their OTLP representation.
+func keyValues(attrs []attribute.KeyValue) []*commonpb.KeyValue {
+	result := make([]*commonpb.KeyValue, 0, len(attrs))
+	for _, kv := range attrs {
+		result = append(result, &commonpb.KeyValue{
+			Key:   string(kv.Key),
+			Value: anyValue(kv.Value),
+		})
+	}
+
+	return result
+}
+
+func anyValue(v attribute.Value) *commonpb.AnyValue {
+	switch v.Type() {
+	case attribute.BOOL:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: v.AsBool()}}
+	case attribute.INT64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: v.AsInt64()}}
+	case attribute.FLOAT64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: v.AsFloat64()}}
+	case attribute.BOOLSLICE:
+		var values []*commonpb.AnyValue
+		for _, b := range v.AsBoolSlice() {
+			values = append(values, anyValue(attribute.BoolValue(b)))
+		}
+		return arrayValue(values)
+	case attribute.INT64SLICE:
+		var values []*commonpb.AnyValue
+		for _, i := range v.AsInt64Slice() {
+			values = append(values, anyValue(attribute.Int64Value(i)))
+		}
+		return arrayValue(values)
+	case attribute.FLOAT64SLICE:
+		var values []*commonpb.AnyValue
+		for _, f := range v.AsFloat64Slice() {
+			values = append(values, anyValue(attribute.Float64Value(f)))
+		}
+		return arrayValue(values)
+	case attribute.STRINGSLICE:
+		var values []*commonpb.AnyValue
+		for _, s := range v.AsStringSlice() {
+			values = append(values, anyValue(attribute.StringValue(s)))
+		}
+		return arrayValue(values)
+	default:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v.Emit()}}
+	}
+}
+
+func arrayValue(values []*commonpb.AnyValue) *commonpb.AnyValue {
+	return &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{ArrayValue: &commonpb.ArrayValue{Values: values}}}
+}
@@ -0,0 +1,145 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opentelemetry
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ansiReset = "\x1b[0m"
+	ansiBold  = "\x1b[1m"
+	ansiDim   = "\x1b[2m"
+	ansiRed   = "\x1b[31m"
+	ansiCyan  = "\x1b[36m"
+)
+
+// prettyAttributeKeys are the attributes printed with spans.
+var prettyAttributeKeys = []attribute.Key{
+	semconv.HTTPMethodKey,
+	semconv.HTTPTargetKey,
+	semconv.HTTPRouteKey,
+	semconv.HTTPStatusCodeKey,
+	semconv.RPCServiceKey,
+	semconv.NetPeerNameKey,
+}
+
+// prettyExporter prints the spans of a trace as an indented tree once its
+// local root span ends.
+type prettyExporter struct {
+	out   io.Writer
+	color bool
+
+	traces *traceAssembler
+}
+
+var _ sdktrace.SpanExporter = (*prettyExporter)(nil)
+
+// newPrettyExporter prints to out, colored if it is a terminal and NO_COLOR
+// is not set.
+func newPrettyExporter(out *os.File) *prettyExporter {
+	color := false
+	if _, noColor := os.LookupEnv("NO_COLOR"); !noColor {
+		if info, err := out.Stat(); err == nil {
+			color = info.Mode()&os.ModeCharDevice != 0
+		}
+	}
+
+	return &prettyExporter{
+		out:    out,
+		color:  color,
+		traces: newTraceAssembler(),
+	}
+}
+
+func (e *prettyExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	for _, s := range spans {
+		if err := e.traces.add(s, e.print); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Shutdown prints the traces whose root span did not end.
+func (e *prettyExporter) Shutdown(ctx context.Context) error {
+	return e.traces.flush(e.print)
+}
+
+// print prints the spans of a trace.
+func (e *prettyExporter) print(spans []sdktrace.ReadOnlySpan) error {
+	roots, children := spanTree(spans)
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s\n", e.style(ansiDim, "trace "+spans[0].SpanContext().TraceID().String()))
+	for _, s := range roots {
+		e.printSpan(&buf, s, children, "", "")
+	}
+
+	_, err := e.out.Write(buf.Bytes())
+	return err
+}
+
+// printSpan prints s after prefix, and its children below it after indent.
+func (e *prettyExporter) printSpan(buf *bytes.Buffer, s sdktrace.ReadOnlySpan, children map[trace.SpanID][]sdktrace.ReadOnlySpan, prefix, indent string) {
+	name := e.style(ansiBold, s.Name())
+	if s.Status().Code == codes.Error {
+		name = e.style(ansiRed, s.Name())
+	}
+	duration := s.EndTime().Sub(s.StartTime()).Round(time.Microsecond)
+	fmt.Fprintf(buf, "%s%s %s %s", prefix, name, e.style(ansiDim, s.SpanKind().String()), e.style(ansiCyan, duration.String()))
+
+	for _, key := range prettyAttributeKeys {
+		for _, kv := range s.Attributes() {
+			if kv.Key == key {
+				fmt.Fprintf(buf, " %s=%s", kv.Key, kv.Value.Emit())
+				break
+			}
+		}
+	}
+	if s.Status().Code == codes.Error && s.Status().Description != "" {
+		fmt.Fprintf(buf, " %s", e.style(ansiRed, s.Status().Description))
+	}
+	buf.WriteByte('\n')
+
+	kids := children[s.SpanContext().SpanID()]
+	for i, child := range kids {
+		if i == len(kids)-1 {
+			e.printSpan(buf, child, children, indent+"└─ ", indent+"   ")
+		} else {
+			e.printSpan(buf, child, children, indent+"├─ ", indent+"│  ")
+		}
+	}
+}
+
+// style wraps s in the ANSI escape code, if output is colored.
+func (e *prettyExporter) style(code, s string) string {
+	if !e.color {
+		return s
+	}
+
+	return code + s + ansiReset
+}
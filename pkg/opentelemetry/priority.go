@@ -0,0 +1,121 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opentelemetry
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// PriorityExportEnvVar exports error spans and spans lasting at least its
+// duration, such as 1s, through a dedicated queue, see WithPriorityExport. A
+// 0 duration only gives priority to error spans.
+const PriorityExportEnvVar = "OTEL_GO_AUTO_PRIORITY_EXPORT"
+
+// WithPriorityExport exports spans with an error status or lasting at least
+// longSpan through a dedicated queue, so they are not dropped when the queue
+// of regular spans is saturated.
+func WithPriorityExport(longSpan time.Duration) Option {
+	return func(c *config) {
+		c.priorityExport = true
+		c.longSpan = longSpan
+	}
+}
+
+func priorityExportFromEnv() (Option, error) {
+	val, exists := os.LookupEnv(PriorityExportEnvVar)
+	if !exists {
+		return nil, nil
+	}
+
+	longSpan, err := time.ParseDuration(val)
+	if err != nil || longSpan < 0 {
+		return nil, fmt.Errorf("unsupported %s value %q", PriorityExportEnvVar, val)
+	}
+
+	return WithPriorityExport(longSpan), nil
+}
+
+// prioritySpanProcessor routes high value spans and bulk spans to separate
+// batch span processors sharing the same exporter.
+type prioritySpanProcessor struct {
+	priority sdktrace.SpanProcessor
+	bulk     sdktrace.SpanProcessor
+	exporter sdktrace.SpanExporter
+	longSpan time.Duration
+}
+
+var _ sdktrace.SpanProcessor = (*prioritySpanProcessor)(nil)
+
+func newPrioritySpanProcessor(exporter sdktrace.SpanExporter, longSpan time.Duration) *prioritySpanProcessor {
+	shared := sharedExporter{exporter}
+	return &prioritySpanProcessor{
+		priority: sdktrace.NewBatchSpanProcessor(shared),
+		bulk:     sdktrace.NewBatchSpanProcessor(shared),
+		exporter: exporter,
+		longSpan: longSpan,
+	}
+}
+
+// sharedExporter keeps the exporter of both batch span processors open when
+// one of them shuts down, the exporter is shut down once both have drained.
+type sharedExporter struct {
+	sdktrace.SpanExporter
+}
+
+func (sharedExporter) Shutdown(context.Context) error { return nil }
+
+func (p *prioritySpanProcessor) isPriority(s sdktrace.ReadOnlySpan) bool {
+	if s.Status().Code == codes.Error {
+		return true
+	}
+
+	return p.longSpan > 0 && s.EndTime().Sub(s.StartTime()) >= p.longSpan
+}
+
+func (p *prioritySpanProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {}
+
+func (p *prioritySpanProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if p.isPriority(s) {
+		p.priority.OnEnd(s)
+		return
+	}
+
+	p.bulk.OnEnd(s)
+}
+
+func (p *prioritySpanProcessor) Shutdown(ctx context.Context) error {
+	var firstErr error
+	for _, shutdown := range []func(context.Context) error{p.priority.Shutdown, p.bulk.Shutdown, p.exporter.Shutdown} {
+		if err := shutdown(ctx); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+func (p *prioritySpanProcessor) ForceFlush(ctx context.Context) error {
+	if err := p.priority.ForceFlush(ctx); err != nil {
+		return err
+	}
+
+	return p.bulk.ForceFlush(ctx)
+}
@@ -0,0 +1,135 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opentelemetry
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// ProxyEnvVar is the URL of the HTTP proxy used to connect to the
+// collector, see WithProxy.
+const ProxyEnvVar = "OTEL_GO_AUTO_PROXY"
+
+// WithProxy sets the HTTP proxy used to connect to the collector, taking
+// precedence over HTTPS_PROXY. Endpoints matching NO_PROXY are still dialed
+// directly. HTTPS_PROXY and NO_PROXY are honored without this option.
+func WithProxy(proxyURL *url.URL) Option {
+	return func(c *config) {
+		c.proxyURL = proxyURL
+	}
+}
+
+func proxyFromEnv() (Option, error) {
+	val, exists := os.LookupEnv(ProxyEnvVar)
+	if !exists {
+		return nil, nil
+	}
+
+	proxyURL, err := url.Parse(val)
+	if err != nil || proxyURL.Host == "" {
+		// The value is not logged, it may hold the proxy credentials.
+		return nil, fmt.Errorf("unsupported %s value, a URL such as http://proxy:3128 is expected", ProxyEnvVar)
+	}
+
+	return WithProxy(proxyURL), nil
+}
+
+// proxyDialer returns a dialer tunneling connections through proxyURL with
+// HTTP CONNECT.
+func proxyDialer(proxyURL *url.URL) func(context.Context, string) (net.Conn, error) {
+	proxyCfg := httpproxy.FromEnvironment()
+	proxyCfg.HTTPSProxy = proxyURL.String()
+	proxyFunc := proxyCfg.ProxyFunc()
+
+	return func(ctx context.Context, addr string) (net.Conn, error) {
+		var d net.Dialer
+		p, err := proxyFunc(&url.URL{Scheme: "https", Host: addr})
+		if err != nil {
+			return nil, err
+		}
+		if p == nil {
+			return d.DialContext(ctx, "tcp", addr)
+		}
+
+		conn, err := d.DialContext(ctx, "tcp", p.Host)
+		if err != nil {
+			return nil, err
+		}
+
+		tunnel, err := connectThroughProxy(conn, p, addr)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+
+		return tunnel, nil
+	}
+}
+
+// connectThroughProxy tunnels conn to addr and returns the connection to
+// use, which first returns the data the proxy sent past its response.
+func connectThroughProxy(conn net.Conn, proxyURL *url.URL, addr string) (net.Conn, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Host: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if u := proxyURL.User; u != nil {
+		password, _ := u.Password()
+		auth := base64.StdEncoding.EncodeToString([]byte(u.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("proxy %s refused connection to %s: %s", proxyURL.Host, addr, resp.Status)
+	}
+
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+
+	return conn, nil
+}
+
+// bufferedConn reads from r, holding the data already read from Conn.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
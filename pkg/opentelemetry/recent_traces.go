@@ -0,0 +1,112 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opentelemetry
+
+import (
+	"context"
+	"time"
+
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/diagnostics"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// WithRecentTraces keeps the last n completed traces for the /debug/traces
+// endpoint of the diagnostics server, to check the probes produce spans
+// before a backend is configured.
+func WithRecentTraces(n int) Option {
+	return func(c *config) {
+		c.recentTraces = n
+	}
+}
+
+// recentTracesProcessor records the completed traces to the diagnostics
+// server.
+type recentTracesProcessor struct {
+	traces *traceAssembler
+}
+
+var _ sdktrace.SpanProcessor = (*recentTracesProcessor)(nil)
+
+func newRecentTracesProcessor(n int) *recentTracesProcessor {
+	diagnostics.KeepRecentTraces(n)
+	return &recentTracesProcessor{traces: newTraceAssembler()}
+}
+
+func (p *recentTracesProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {}
+
+func (p *recentTracesProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	_ = p.traces.add(s, recordTrace)
+}
+
+func (p *recentTracesProcessor) Shutdown(ctx context.Context) error {
+	return p.traces.flush(recordTrace)
+}
+
+func (p *recentTracesProcessor) ForceFlush(ctx context.Context) error {
+	return nil
+}
+
+// recordTrace records the spans of a trace to the diagnostics server.
+func recordTrace(spans []sdktrace.ReadOnlySpan) error {
+	roots, children := spanTree(spans)
+	t := diagnostics.Trace{
+		TraceID: spans[0].SpanContext().TraceID().String(),
+		Spans:   make([]diagnostics.Span, 0, len(spans)),
+	}
+
+	var walk func(s sdktrace.ReadOnlySpan, depth int)
+	walk = func(s sdktrace.ReadOnlySpan, depth int) {
+		t.Spans = append(t.Spans, diagnosticsSpan(s, depth))
+		for _, child := range children[s.SpanContext().SpanID()] {
+			walk(child, depth+1)
+		}
+	}
+	for _, s := range roots {
+		walk(s, 0)
+	}
+
+	diagnostics.RecordTrace(t)
+	return nil
+}
+
+func diagnosticsSpan(s sdktrace.ReadOnlySpan, depth int) diagnostics.Span {
+	status := s.Status().Code.String()
+	if s.Status().Code == codes.Error && s.Status().Description != "" {
+		status += ": " + s.Status().Description
+	}
+
+	var parentID string
+	if s.Parent().IsValid() {
+		parentID = s.Parent().SpanID().String()
+	}
+
+	attrs := make(map[string]string, len(s.Attributes()))
+	for _, kv := range s.Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+
+	return diagnostics.Span{
+		SpanID:       s.SpanContext().SpanID().String(),
+		ParentSpanID: parentID,
+		Depth:        depth,
+		Name:         s.Name(),
+		Kind:         s.SpanKind().String(),
+		Start:        s.StartTime(),
+		DurationMS:   float64(s.EndTime().Sub(s.StartTime())) / float64(time.Millisecond),
+		Status:       status,
+		Attributes:   attrs,
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opentelemetry
+
+import (
+	"os/user"
+	"path/filepath"
+	"strings"
+
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/log"
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/process"
+	"github.com/prometheus/procfs"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+)
+
+const redactedValue = "REDACTED"
+
+// processAttributes returns the process resource attributes of the target.
+func processAttributes(target *process.TargetDetails) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		semconv.ProcessPIDKey.Int(target.PID),
+		semconv.ProcessRuntimeNameKey.String("go"),
+	}
+
+	if target.GoVersion != nil {
+		attrs = append(attrs, semconv.ProcessRuntimeVersionKey.String(target.GoVersion.Original()))
+	}
+
+	if target.ExePath != "" {
+		attrs = append(attrs,
+			semconv.ProcessExecutablePathKey.String(target.ExePath),
+			semconv.ProcessExecutableNameKey.String(filepath.Base(target.ExePath)))
+	}
+
+	proc, err := procfs.NewProc(target.PID)
+	if err != nil {
+		log.Component(log.ComponentExporter).V(1).Info("could not read target process details", "pid", target.PID, "error", err.Error())
+		return attrs
+	}
+
+	if args, err := proc.CmdLine(); err == nil && len(args) > 0 {
+		attrs = append(attrs, semconv.ProcessCommandArgsKey.StringSlice(redactFlagValues(args)))
+	}
+
+	if status, err := proc.NewStatus(); err == nil {
+		attrs = append(attrs, semconv.ProcessOwnerKey.String(ownerName(status.UIDs[0])))
+	}
+
+	return attrs
+}
+
+// ownerName returns the name of the user with uid, or uid if it is unknown
+// to the agent, which may not share the users of the target, e.g. when the
+// target runs in a container.
+func ownerName(uid string) string {
+	u, err := user.LookupId(uid)
+	if err != nil {
+		return uid
+	}
+
+	return u.Username
+}
+
+// redactFlagValues hides the values of flags passed as -flag=value,
+// --flag=value or -flag value, which commonly carry credentials. Without the
+// flag definitions, the argument following a boolean flag is hidden too.
+// Arguments after "--" are kept.
+func redactFlagValues(args []string) []string {
+	result := make([]string, len(args))
+	copy(result, args)
+	for i := 1; i < len(result); i++ {
+		arg := result[i]
+		if arg == "--" {
+			break
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			continue
+		}
+
+		if eq := strings.Index(arg, "="); eq > 0 {
+			result[i] = arg[:eq+1] + redactedValue
+			continue
+		}
+
+		if i+1 < len(result) && !strings.HasPrefix(result[i+1], "-") {
+			result[i+1] = redactedValue
+			i++
+		}
+	}
+
+	return result
+}
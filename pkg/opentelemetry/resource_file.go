@@ -0,0 +1,135 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opentelemetry
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// otelResourceAttributesFileEnvVar lists files resource attributes are read
+// from, separated like PATH entries.
+const otelResourceAttributesFileEnvVar = "OTEL_GO_AUTO_RESOURCE_ATTRIBUTES_FILE"
+
+// WithResourceAttributesFile adds the resource attributes read from paths,
+// in addition to the files listed by OTEL_GO_AUTO_RESOURCE_ATTRIBUTES_FILE.
+// Files hold key=value pairs separated by commas, as OTEL_RESOURCE_ATTRIBUTES
+// does, or by new lines, as Kubernetes Downward API files do. Values may be
+// percent-encoded or double-quoted. Attributes detected by the agent, such as
+// service.name, take precedence over the ones read from files.
+func WithResourceAttributesFile(paths ...string) Option {
+	return func(c *config) {
+		c.resourceAttributesFiles = append(c.resourceAttributesFiles, paths...)
+	}
+}
+
+// fileResourceAttributes returns the attributes read from the configured
+// files, later files overriding earlier ones.
+func (c *config) fileResourceAttributes() ([]attribute.KeyValue, error) {
+	paths := c.resourceAttributesFiles
+	if val, exists := os.LookupEnv(otelResourceAttributesFileEnvVar); exists {
+		paths = append(filepath.SplitList(val), paths...)
+	}
+
+	var attrs []attribute.KeyValue
+	for _, path := range paths {
+		fileAttrs, err := readResourceAttributesFile(path)
+		if err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, fileAttrs...)
+	}
+
+	return attrs, nil
+}
+
+func readResourceAttributesFile(path string) ([]attribute.KeyValue, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var attrs []attribute.KeyValue
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		for _, pair := range splitResourceAttributes(scanner.Text()) {
+			pair = strings.TrimSpace(pair)
+			if pair == "" {
+				continue
+			}
+
+			kv, err := parseResourceAttribute(pair)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+			}
+			attrs = append(attrs, kv)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return attrs, nil
+}
+
+// splitResourceAttributes splits line on the commas outside of quoted values.
+func splitResourceAttributes(line string) []string {
+	var pairs []string
+	start, quoted := 0, false
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '"':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				pairs = append(pairs, line[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	return append(pairs, line[start:])
+}
+
+func parseResourceAttribute(pair string) (attribute.KeyValue, error) {
+	eq := strings.Index(pair, "=")
+	if eq <= 0 {
+		return attribute.KeyValue{}, fmt.Errorf("invalid resource attribute %q", pair)
+	}
+
+	key := strings.TrimSpace(pair[:eq])
+	val := strings.TrimSpace(pair[eq+1:])
+	var err error
+	if strings.HasPrefix(val, `"`) {
+		val, err = strconv.Unquote(val)
+	} else {
+		val, err = url.PathUnescape(val)
+	}
+	if err != nil {
+		return attribute.KeyValue{}, fmt.Errorf("invalid value of resource attribute %s: %w", key, err)
+	}
+
+	return attribute.String(key, val), nil
+}
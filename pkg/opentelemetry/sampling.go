@@ -0,0 +1,167 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opentelemetry
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/events"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// AlwaysSampleErrorsEnvVar exports the error spans of sampled out
+	// traces, see WithAlwaysSampleErrors.
+	AlwaysSampleErrorsEnvVar = "OTEL_GO_AUTO_ALWAYS_SAMPLE_ERRORS"
+
+	// The head sampler is configured as in the SDK, which ignores these
+	// variables when a sampler is passed to its tracer provider.
+	otelTracesSamplerEnvVar    = "OTEL_TRACES_SAMPLER"
+	otelTracesSamplerArgEnvVar = "OTEL_TRACES_SAMPLER_ARG"
+)
+
+// sampledOutParentKey marks the link from an error span exported in spite of
+// its trace being sampled out to its parent, which is not exported.
+var sampledOutParentKey = attribute.Key("telemetry.auto.sampled_out_parent")
+
+// WithSampler sets the head sampler deciding which spans are exported, all
+// of them by default.
+func WithSampler(sampler sdktrace.Sampler) Option {
+	return func(c *config) {
+		c.sampler = sampler
+	}
+}
+
+// WithAlwaysSampleErrors exports the error spans of traces sampled out by the
+// head sampler. Such a span is exported as the root of its trace, linked to
+// its parent, so failures are never lost to sampling.
+func WithAlwaysSampleErrors() Option {
+	return func(c *config) {
+		c.alwaysSampleErrors = true
+	}
+}
+
+func samplerFromEnv() (Option, error) {
+	val, exists := os.LookupEnv(otelTracesSamplerEnvVar)
+	if !exists {
+		return nil, nil
+	}
+
+	ratio := 1.0
+	if arg, exists := os.LookupEnv(otelTracesSamplerArgEnvVar); exists {
+		var err error
+		ratio, err = strconv.ParseFloat(strings.TrimSpace(arg), 64)
+		if err != nil || ratio < 0 || ratio > 1 {
+			return nil, fmt.Errorf("unsupported %s value %q", otelTracesSamplerArgEnvVar, arg)
+		}
+	}
+
+	var sampler sdktrace.Sampler
+	switch strings.ToLower(strings.TrimSpace(val)) {
+	case "always_on":
+		sampler = sdktrace.AlwaysSample()
+	case "always_off":
+		sampler = sdktrace.NeverSample()
+	case "traceidratio":
+		sampler = sdktrace.TraceIDRatioBased(ratio)
+	case "parentbased_always_on":
+		sampler = sdktrace.ParentBased(sdktrace.AlwaysSample())
+	case "parentbased_always_off":
+		sampler = sdktrace.ParentBased(sdktrace.NeverSample())
+	case "parentbased_traceidratio":
+		sampler = sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))
+	default:
+		return nil, fmt.Errorf("unsupported %s value %q", otelTracesSamplerEnvVar, val)
+	}
+
+	return WithSampler(sampler), nil
+}
+
+func alwaysSampleErrorsFromEnv() (Option, error) {
+	val, exists := os.LookupEnv(AlwaysSampleErrorsEnvVar)
+	if !exists {
+		return nil, nil
+	}
+
+	enabled, err := strconv.ParseBool(val)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported %s value %q", AlwaysSampleErrorsEnvVar, val)
+	}
+	if !enabled {
+		return nil, nil
+	}
+
+	return WithAlwaysSampleErrors(), nil
+}
+
+type samplingExemptionKey struct{}
+
+// exemptingSampler samples the spans started with a context holding a
+// sampling exemption, and defers to sampler for the others.
+type exemptingSampler struct {
+	sampler sdktrace.Sampler
+}
+
+var _ sdktrace.Sampler = exemptingSampler{}
+
+func (s exemptingSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	if exempted, _ := p.ParentContext.Value(samplingExemptionKey{}).(bool); exempted {
+		return sdktrace.SamplingResult{
+			Decision:   sdktrace.RecordAndSample,
+			Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+		}
+	}
+
+	return s.sampler.ShouldSample(p)
+}
+
+func (s exemptingSampler) Description() string {
+	return "AlwaysSampleErrors{" + s.sampler.Description() + "}"
+}
+
+// exemptFromSampling returns ctx exempting the error span of event from
+// sampling, and the options to start it with, if the head sampler drops it.
+// The span then starts a new trace linked to the sampled out parent.
+func (c *Controller) exemptFromSampling(ctx context.Context, event *events.Event, attrs []attribute.KeyValue) (context.Context, []trace.SpanStartOption) {
+	result := c.sampler.ShouldSample(sdktrace.SamplingParameters{
+		ParentContext: ctx,
+		TraceID:       event.SpanContext.TraceID(),
+		Name:          event.Name,
+		Kind:          event.Kind,
+		Attributes:    attrs,
+	})
+	if result.Decision != sdktrace.Drop {
+		return ctx, nil
+	}
+
+	ctx = context.WithValue(ctx, samplingExemptionKey{}, true)
+	if event.ParentSpanContext == nil {
+		return ctx, nil
+	}
+
+	return ctx, []trace.SpanStartOption{
+		trace.WithNewRoot(),
+		trace.WithLinks(trace.Link{
+			SpanContext: *event.ParentSpanContext,
+			Attributes:  []attribute.KeyValue{sampledOutParentKey.Bool(true)},
+		}),
+	}
+}
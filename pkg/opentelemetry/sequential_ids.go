@@ -0,0 +1,103 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opentelemetry
+
+import (
+	"encoding/binary"
+	"sync"
+
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/instrumentors/events"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// IDGenerator replaces the trace and span IDs generated by the probes with
+// the IDs spans are exported with.
+type IDGenerator interface {
+	// MapSpanContext returns sc with the IDs it is exported with. It is
+	// called with both the span contexts of spans and of their parents, and
+	// must return the same IDs for the same span context.
+	MapSpanContext(sc trace.SpanContext) trace.SpanContext
+}
+
+// WithIDGenerator exports spans with the IDs returned by gen instead of the
+// IDs generated by the probes. It is meant for tests, spans propagated to
+// processes not instrumented by the agent keep the IDs of the probes.
+func WithIDGenerator(gen IDGenerator) Option {
+	return func(c *config) {
+		c.idGenerator = gen
+	}
+}
+
+// sequentialIDGenerator assigns sequential IDs to traces and spans, in the
+// order they are first seen.
+type sequentialIDGenerator struct {
+	seed uint64
+
+	mu        sync.Mutex
+	traceIDs  map[trace.TraceID]trace.TraceID
+	spanIDs   map[trace.SpanID]trace.SpanID
+	nextTrace uint64
+	nextSpan  uint64
+}
+
+var _ IDGenerator = (*sequentialIDGenerator)(nil)
+
+// NewSequentialIDGenerator returns an IDGenerator assigning sequential IDs,
+// starting at 1, to traces and spans in the order they are first seen. The
+// high half of trace IDs is seed. IDs are deterministic as long as spans end
+// in a deterministic order, so test fixtures can hold exact IDs. The IDs
+// seen are never forgotten, it is not meant for long running agents.
+func NewSequentialIDGenerator(seed uint64) IDGenerator {
+	return &sequentialIDGenerator{
+		seed:     seed,
+		traceIDs: make(map[trace.TraceID]trace.TraceID),
+		spanIDs:  make(map[trace.SpanID]trace.SpanID),
+	}
+}
+
+func (g *sequentialIDGenerator) MapSpanContext(sc trace.SpanContext) trace.SpanContext {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	traceID, exists := g.traceIDs[sc.TraceID()]
+	if !exists {
+		g.nextTrace++
+		binary.BigEndian.PutUint64(traceID[:8], g.seed)
+		binary.BigEndian.PutUint64(traceID[8:], g.nextTrace)
+		g.traceIDs[sc.TraceID()] = traceID
+	}
+
+	spanID, exists := g.spanIDs[sc.SpanID()]
+	if !exists {
+		g.nextSpan++
+		binary.BigEndian.PutUint64(spanID[:], g.nextSpan)
+		g.spanIDs[sc.SpanID()] = spanID
+	}
+
+	return sc.WithTraceID(traceID).WithSpanID(spanID)
+}
+
+// mapIDs returns a copy of event with the span contexts returned by gen.
+func mapIDs(gen IDGenerator, event *events.Event) *events.Event {
+	mapped := *event
+	sc := gen.MapSpanContext(*event.SpanContext)
+	mapped.SpanContext = &sc
+	if event.ParentSpanContext != nil {
+		psc := gen.MapSpanContext(*event.ParentSpanContext)
+		mapped.ParentSpanContext = &psc
+	}
+
+	return &mapped
+}
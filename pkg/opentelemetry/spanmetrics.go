@@ -0,0 +1,291 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opentelemetry
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/metric/instrument"
+	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+	"go.opentelemetry.io/otel/metric/instrument/syncint64"
+	"go.opentelemetry.io/otel/metric/unit"
+	controller "go.opentelemetry.io/otel/sdk/metric/controller/basic"
+	processor "go.opentelemetry.io/otel/sdk/metric/processor/basic"
+	"go.opentelemetry.io/otel/sdk/metric/selector/simple"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+const (
+	// SpanMetricsEnvVar turns on span metrics, see WithSpanMetrics.
+	SpanMetricsEnvVar = "OTEL_GO_AUTO_SPAN_METRICS"
+
+	otelMetricsEndpointEnvVar = "OTEL_EXPORTER_OTLP_METRICS_ENDPOINT"
+
+	spanMetricsCollectPeriod = 30 * time.Second
+)
+
+var (
+	spanNameKey   = attribute.Key("span.name")
+	spanKindKey   = attribute.Key("span.kind")
+	statusCodeKey = attribute.Key("status.code")
+)
+
+// WithSpanMetrics aggregates request rate, errors and duration (RED metrics)
+// from the generated spans and exports them as OTLP metrics, per span name,
+// kind, status and HTTP route.
+func WithSpanMetrics() Option {
+	return func(c *config) {
+		c.spanMetrics = true
+	}
+}
+
+func spanMetricsFromEnv() (Option, error) {
+	val, exists := os.LookupEnv(SpanMetricsEnvVar)
+	if !exists {
+		return nil, nil
+	}
+
+	enabled, err := strconv.ParseBool(val)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported %s value %q", SpanMetricsEnvVar, val)
+	}
+	if !enabled {
+		return nil, nil
+	}
+
+	return WithSpanMetrics(), nil
+}
+
+// spanMetricsProcessor records RED metrics for every ended span.
+type spanMetricsProcessor struct {
+	pipeline *metricPipeline
+}
+
+var _ sdktrace.SpanProcessor = (*spanMetricsProcessor)(nil)
+
+func newSpanMetricsProcessor(ctx context.Context, cfg *config, res *resource.Resource) (*spanMetricsProcessor, error) {
+	pipeline, err := newMetricPipeline(ctx, cfg, res, spanMetricsCollectPeriod, "spanmetrics")
+	if err != nil {
+		return nil, err
+	}
+
+	return &spanMetricsProcessor{pipeline: pipeline}, nil
+}
+
+func (p *spanMetricsProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {}
+
+func (p *spanMetricsProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	statusCode := codes.Unset
+	if s.Status().Code == codes.Error {
+		statusCode = codes.Error
+	}
+
+	attrs := []attribute.KeyValue{
+		spanNameKey.String(s.Name()),
+		spanKindKey.String(s.SpanKind().String()),
+		statusCodeKey.String(statusCode.String()),
+	}
+	for _, kv := range s.Attributes() {
+		if kv.Key == semconv.HTTPRouteKey {
+			attrs = append(attrs, kv)
+			break
+		}
+	}
+
+	elapsed := s.EndTime().Sub(s.StartTime())
+	p.pipeline.record(attrs, elapsed)
+	p.pipeline.exemplars.offer(attrs, elapsed, s.EndTime(), s.SpanContext())
+}
+
+func (p *spanMetricsProcessor) Shutdown(ctx context.Context) error {
+	return p.pipeline.stop(ctx)
+}
+
+func (p *spanMetricsProcessor) ForceFlush(ctx context.Context) error {
+	return p.pipeline.flush(ctx)
+}
+
+// metricPipeline collects the RED metrics of spans and exports them through
+// an OTLP/gRPC exporter.
+type metricPipeline struct {
+	controller *controller.Controller
+	exporter   *otlpmetric.Exporter
+	exemplars  *exemplarReservoir
+	calls      syncint64.Counter
+	duration   syncfloat64.Histogram
+
+	// mu serializes flushes with stop, so that a flush does not restart a
+	// stopped controller.
+	mu      sync.Mutex
+	stopped bool
+}
+
+// newMetricPipeline starts a pipeline exporting the metrics of the meter of
+// scope every period.
+func newMetricPipeline(ctx context.Context, cfg *config, res *resource.Resource, period time.Duration, scope string) (*metricPipeline, error) {
+	// Exemplars would refer to spans that are not exported.
+	var exemplars *exemplarReservoir
+	if exportTraces, _ := cfg.exportsTraces(); exportTraces {
+		exemplars = newExemplarReservoir()
+	}
+	exporter, err := newMetricExporter(ctx, cfg, exemplars)
+	if err != nil {
+		return nil, err
+	}
+
+	cont := controller.New(
+		processor.NewFactory(simple.NewWithHistogramDistribution(), exporter),
+		controller.WithExporter(exporter),
+		controller.WithCollectPeriod(period),
+		controller.WithResource(res),
+	)
+
+	meter := cont.Meter(instrumentationScopePrefix + scope)
+	calls, err := meter.SyncInt64().Counter("calls",
+		instrument.WithDescription("Number of spans"))
+	if err != nil {
+		exporter.Shutdown(ctx)
+		return nil, err
+	}
+
+	duration, err := meter.SyncFloat64().Histogram("duration",
+		instrument.WithDescription("Duration of spans"),
+		instrument.WithUnit(unit.Milliseconds))
+	if err != nil {
+		exporter.Shutdown(ctx)
+		return nil, err
+	}
+
+	if err := cont.Start(ctx); err != nil {
+		exporter.Shutdown(ctx)
+		return nil, err
+	}
+
+	return &metricPipeline{
+		controller: cont,
+		exporter:   exporter,
+		exemplars:  exemplars,
+		calls:      calls,
+		duration:   duration,
+	}, nil
+}
+
+// record records a span of duration elapsed with the metric attributes attrs.
+func (p *metricPipeline) record(attrs []attribute.KeyValue, elapsed time.Duration) {
+	ctx := context.Background()
+	p.calls.Add(ctx, 1, attrs...)
+	p.duration.Record(ctx, durationMillis(elapsed), attrs...)
+}
+
+// flush exports the metrics not exported yet. The controller only exports
+// them on its ticks and when stopped, it is stopped and started again.
+func (p *metricPipeline) flush(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopped {
+		return nil
+	}
+
+	err := p.controller.Stop(ctx)
+	// ctx only bounds the flush, the restarted ticker outlives it.
+	if startErr := p.controller.Start(context.Background()); startErr != nil && err == nil {
+		err = startErr
+	}
+
+	return err
+}
+
+// stop exports the metrics not exported yet and stops the exporter.
+func (p *metricPipeline) stop(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stopped = true
+
+	if err := p.controller.Stop(ctx); err != nil {
+		return err
+	}
+
+	return p.exporter.Shutdown(ctx)
+}
+
+// durationMillis returns d in the unit of the duration histograms.
+func durationMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// newMetricExporter creates an OTLP/gRPC metric exporter sharing the
+// connection settings of the trace exporter. The duration histograms it
+// exports hold the exemplars offered to exemplars.
+func newMetricExporter(ctx context.Context, cfg *config, exemplars *exemplarReservoir) (*otlpmetric.Exporter, error) {
+	endpoint, baseExists := os.LookupEnv(otelEndpointEnvVar)
+	metricsEndpoint, metricsExists := os.LookupEnv(otelMetricsEndpointEnvVar)
+	if !baseExists && !metricsExists {
+		return nil, fmt.Errorf("%s or %s env var must be set", otelEndpointEnvVar, otelMetricsEndpointEnvVar)
+	}
+	if metricsExists {
+		endpoint = metricsEndpoint
+	}
+
+	opts := []otlpmetricgrpc.Option{
+		otlpmetricgrpc.WithRetry(otlpmetricgrpc.RetryConfig{
+			Enabled:         true,
+			InitialInterval: retryInitialInterval,
+			MaxInterval:     retryMaxInterval,
+			MaxElapsedTime:  retryMaxElapsedTime,
+		}),
+	}
+
+	if cfg.compression == GzipCompression {
+		opts = append(opts, otlpmetricgrpc.WithCompressor(GzipCompression))
+	}
+
+	if cfg.proxyURL != nil && !strings.HasPrefix(endpoint, unixScheme) {
+		opts = append(opts, otlpmetricgrpc.WithDialOption(grpc.WithContextDialer(proxyDialer(cfg.proxyURL))))
+	}
+
+	tlsConfig, err := cfg.buildTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		opts = append(opts, otlpmetricgrpc.WithTLSCredentials(credentials.NewTLS(tlsConfig)))
+	}
+
+	if !strings.Contains(endpoint, "://") || strings.HasPrefix(endpoint, unixScheme) {
+		opts = append(opts, otlpmetricgrpc.WithEndpoint(endpoint))
+		if tlsConfig == nil {
+			opts = append(opts, otlpmetricgrpc.WithInsecure())
+		}
+	}
+
+	return otlpmetric.New(ctx, &exemplarClient{
+		Client:    otlpmetricgrpc.NewClient(opts...),
+		exemplars: exemplars,
+	})
+}
@@ -0,0 +1,149 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opentelemetry
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// HTTPServerErrorStatusCodesEnvVar is a comma separated list of the
+	// status codes or ranges, such as "500-599", marking server spans as
+	// error, see WithHTTPServerErrorStatusCodes. An empty list never marks
+	// them as error.
+	HTTPServerErrorStatusCodesEnvVar = "OTEL_GO_AUTO_HTTP_SERVER_ERROR_STATUS_CODES"
+	// HTTPClientErrorStatusCodesEnvVar is the list of the status codes
+	// marking client spans as error, see WithHTTPClientErrorStatusCodes.
+	HTTPClientErrorStatusCodesEnvVar = "OTEL_GO_AUTO_HTTP_CLIENT_ERROR_STATUS_CODES"
+)
+
+// StatusCodeRange is an inclusive range of HTTP status codes.
+type StatusCodeRange struct {
+	Min int
+	Max int
+}
+
+var (
+	// defaultServerErrorStatusCodes and defaultClientErrorStatusCodes follow
+	// the HTTP semantic conventions.
+	defaultServerErrorStatusCodes = []StatusCodeRange{{Min: 500, Max: 599}}
+	defaultClientErrorStatusCodes = []StatusCodeRange{{Min: 400, Max: 599}}
+)
+
+// WithHTTPServerErrorStatusCodes sets the HTTP status codes marking server
+// spans as error, 5xx by default. Pass no range to never mark them as error.
+func WithHTTPServerErrorStatusCodes(ranges ...StatusCodeRange) Option {
+	return func(c *config) {
+		c.serverErrorStatusCodes = append([]StatusCodeRange{}, ranges...)
+	}
+}
+
+// WithHTTPClientErrorStatusCodes sets the HTTP status codes marking client
+// spans as error, 4xx and 5xx by default. Pass no range to never mark them
+// as error.
+func WithHTTPClientErrorStatusCodes(ranges ...StatusCodeRange) Option {
+	return func(c *config) {
+		c.clientErrorStatusCodes = append([]StatusCodeRange{}, ranges...)
+	}
+}
+
+func httpServerErrorStatusCodesFromEnv() (Option, error) {
+	ranges, exists, err := statusCodeRangesFromEnv(HTTPServerErrorStatusCodesEnvVar)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	return WithHTTPServerErrorStatusCodes(ranges...), nil
+}
+
+func httpClientErrorStatusCodesFromEnv() (Option, error) {
+	ranges, exists, err := statusCodeRangesFromEnv(HTTPClientErrorStatusCodesEnvVar)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	return WithHTTPClientErrorStatusCodes(ranges...), nil
+}
+
+// statusCodeRangesFromEnv returns the status code ranges listed by envVar,
+// and whether it is set.
+func statusCodeRangesFromEnv(envVar string) ([]StatusCodeRange, bool, error) {
+	val, exists := os.LookupEnv(envVar)
+	if !exists {
+		return nil, false, nil
+	}
+
+	var ranges []StatusCodeRange
+	for _, entry := range strings.Split(val, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, "-", 2)
+		min, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+		if err != nil {
+			return nil, false, fmt.Errorf("unsupported %s value %q", envVar, val)
+		}
+		max := min
+		if len(parts) == 2 {
+			max, err = strconv.Atoi(strings.TrimSpace(parts[1]))
+			if err != nil || max < min {
+				return nil, false, fmt.Errorf("unsupported %s value %q", envVar, val)
+			}
+		}
+		ranges = append(ranges, StatusCodeRange{Min: min, Max: max})
+	}
+
+	return ranges, true, nil
+}
+
+// isErrorStatus reports whether the span of kind with the attributes attrs
+// holds an HTTP status code in the server or client error ranges.
+func isErrorStatus(kind trace.SpanKind, attrs []attribute.KeyValue, serverRanges []StatusCodeRange, clientRanges []StatusCodeRange) bool {
+	var ranges []StatusCodeRange
+	switch kind {
+	case trace.SpanKindServer:
+		ranges = serverRanges
+	case trace.SpanKindClient:
+		ranges = clientRanges
+	default:
+		return false
+	}
+
+	for _, kv := range attrs {
+		if kv.Key != semconv.HTTPStatusCodeKey {
+			continue
+		}
+
+		code := int(kv.Value.AsInt64())
+		for _, r := range ranges {
+			if code >= r.Min && code <= r.Max {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return false
+}
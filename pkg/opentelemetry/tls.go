@@ -0,0 +1,128 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opentelemetry
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+)
+
+const (
+	// TLSEnvVar connects to the collector with TLS, verifying it with the
+	// system CAs unless OTEL_EXPORTER_OTLP_CERTIFICATE is set, see
+	// WithTLSConfig.
+	TLSEnvVar = "OTEL_GO_AUTO_TLS"
+
+	otelCertificateEnvVar       = "OTEL_EXPORTER_OTLP_CERTIFICATE"
+	otelClientCertificateEnvVar = "OTEL_EXPORTER_OTLP_CLIENT_CERTIFICATE"
+	otelClientKeyEnvVar         = "OTEL_EXPORTER_OTLP_CLIENT_KEY"
+)
+
+// WithTLSConfig sets the TLS configuration used to connect to the collector.
+// Files configured with WithTLSFiles are added to it.
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(c *config) {
+		c.tlsConfig = tlsConfig
+	}
+}
+
+func tlsConfigFromEnv() (Option, error) {
+	val, exists := os.LookupEnv(TLSEnvVar)
+	if !exists {
+		return nil, nil
+	}
+
+	enabled, err := strconv.ParseBool(val)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported %s value %q", TLSEnvVar, val)
+	}
+	if !enabled {
+		return nil, nil
+	}
+
+	return WithTLSConfig(&tls.Config{}), nil
+}
+
+// WithTLSFiles configures TLS to the collector from PEM encoded files. caFile
+// is the CA used to verify the collector, certFile and keyFile are the client
+// certificate and key used for mutual TLS. Empty paths are ignored.
+func WithTLSFiles(certFile, keyFile, caFile string) Option {
+	return func(c *config) {
+		c.certFile = certFile
+		c.keyFile = keyFile
+		c.caFile = caFile
+	}
+}
+
+// tlsFromEnv fills the TLS files not set by options from the
+// OTEL_EXPORTER_OTLP_CERTIFICATE, OTEL_EXPORTER_OTLP_CLIENT_CERTIFICATE and
+// OTEL_EXPORTER_OTLP_CLIENT_KEY env vars.
+func (c *config) tlsFromEnv() {
+	if val, exists := os.LookupEnv(otelCertificateEnvVar); exists && c.caFile == "" {
+		c.caFile = val
+	}
+	if val, exists := os.LookupEnv(otelClientCertificateEnvVar); exists && c.certFile == "" {
+		c.certFile = val
+	}
+	if val, exists := os.LookupEnv(otelClientKeyEnvVar); exists && c.keyFile == "" {
+		c.keyFile = val
+	}
+}
+
+// buildTLSConfig returns the TLS configuration to connect to the collector
+// with, or nil if none is configured.
+func (c *config) buildTLSConfig() (*tls.Config, error) {
+	c.tlsFromEnv()
+	if c.tlsConfig == nil && c.caFile == "" && c.certFile == "" && c.keyFile == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{}
+	if c.tlsConfig != nil {
+		tlsConfig = c.tlsConfig.Clone()
+	}
+
+	if c.caFile != "" {
+		caPEM, err := ioutil.ReadFile(c.caFile)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in %s", c.caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if (c.certFile == "") != (c.keyFile == "") {
+		return nil, errors.New("both client certificate and key must be set for mutual TLS")
+	}
+
+	if c.certFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = append(tlsConfig.Certificates, cert)
+	}
+
+	return tlsConfig, nil
+}
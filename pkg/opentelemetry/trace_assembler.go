@@ -0,0 +1,114 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opentelemetry
+
+import (
+	"sort"
+	"sync"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxPendingTraces is the number of traces kept until their local root span
+// ends. The oldest trace is completed as is once it is exceeded.
+const maxPendingTraces = 1000
+
+// traceAssembler groups the ended spans of traces until the local root span
+// of their trace ends.
+type traceAssembler struct {
+	mu      sync.Mutex
+	pending map[trace.TraceID][]sdktrace.ReadOnlySpan
+	order   []trace.TraceID
+}
+
+func newTraceAssembler() *traceAssembler {
+	return &traceAssembler{pending: make(map[trace.TraceID][]sdktrace.ReadOnlySpan)}
+}
+
+// add adds s to its trace, and passes the spans of the trace to complete if
+// s is its local root span.
+func (a *traceAssembler) add(s sdktrace.ReadOnlySpan, complete func([]sdktrace.ReadOnlySpan) error) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	traceID := s.SpanContext().TraceID()
+	if _, exists := a.pending[traceID]; !exists {
+		if len(a.order) >= maxPendingTraces {
+			if err := complete(a.take(a.order[0])); err != nil {
+				return err
+			}
+		}
+		a.order = append(a.order, traceID)
+	}
+	a.pending[traceID] = append(a.pending[traceID], s)
+
+	if !s.Parent().IsValid() || s.Parent().IsRemote() {
+		return complete(a.take(traceID))
+	}
+
+	return nil
+}
+
+// flush passes the spans of the traces whose root span did not end to
+// complete.
+func (a *traceAssembler) flush(complete func([]sdktrace.ReadOnlySpan) error) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for len(a.order) > 0 {
+		if err := complete(a.take(a.order[0])); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// take returns and forgets the spans of traceID. a.mu must be held.
+func (a *traceAssembler) take(traceID trace.TraceID) []sdktrace.ReadOnlySpan {
+	spans := a.pending[traceID]
+	delete(a.pending, traceID)
+	for i, id := range a.order {
+		if id == traceID {
+			a.order = append(a.order[:i], a.order[i+1:]...)
+			break
+		}
+	}
+
+	return spans
+}
+
+// spanTree returns the spans of a trace without a parent among spans, and
+// the children of each span, ordered by start time.
+func spanTree(spans []sdktrace.ReadOnlySpan) ([]sdktrace.ReadOnlySpan, map[trace.SpanID][]sdktrace.ReadOnlySpan) {
+	sort.Slice(spans, func(i, j int) bool {
+		return spans[i].StartTime().Before(spans[j].StartTime())
+	})
+	known := make(map[trace.SpanID]bool, len(spans))
+	for _, s := range spans {
+		known[s.SpanContext().SpanID()] = true
+	}
+
+	children := make(map[trace.SpanID][]sdktrace.ReadOnlySpan)
+	var roots []sdktrace.ReadOnlySpan
+	for _, s := range spans {
+		if parent := s.Parent().SpanID(); known[parent] {
+			children[parent] = append(children[parent], s)
+		} else {
+			roots = append(roots, s)
+		}
+	}
+
+	return roots, children
+}
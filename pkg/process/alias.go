@@ -0,0 +1,140 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package process
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/log"
+)
+
+// ModuleAlias maps a renamed module (e.g. an internal fork of gRPC) to the
+// upstream module it is structurally identical to, so probes written for the
+// upstream module can be applied to it.
+type ModuleAlias struct {
+	// Path is the module path used by the target.
+	Path string
+	// Original is the upstream module path the probes are written for.
+	Original string
+	// Version is the upstream version used to look up offsets. The version
+	// the target reports for Path is used when empty.
+	Version string
+}
+
+// parseModuleAliases parses a comma separated list of
+// "fork/path=upstream/path[@version]" entries.
+func parseModuleAliases(val string) ([]*ModuleAlias, error) {
+	var aliases []*ModuleAlias
+	for _, entry := range strings.Split(val, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid module alias %q, expected fork/path=upstream/path[@version]", entry)
+		}
+
+		alias := &ModuleAlias{Path: parts[0], Original: parts[1]}
+		if i := strings.LastIndex(alias.Original, "@"); i > 0 {
+			alias.Version = alias.Original[i+1:]
+			alias.Original = alias.Original[:i]
+		}
+
+		aliases = append(aliases, alias)
+	}
+
+	return aliases, nil
+}
+
+// resolveAlias returns the name of the symbol funcName as it would be
+// called in the upstream module, if it belongs to an aliased module.
+func resolveAlias(aliases []*ModuleAlias, funcName string) string {
+	for _, a := range aliases {
+		if !strings.HasPrefix(funcName, a.Path) {
+			continue
+		}
+
+		rest := funcName[len(a.Path):]
+		if strings.HasPrefix(rest, ".") || strings.HasPrefix(rest, "/") {
+			return a.Original + rest
+		}
+	}
+
+	return funcName
+}
+
+// applyAliases registers aliased modules found in the target under their
+// upstream path so offsets are looked up for the upstream module. The
+// upstream module keeps its own version when the target also depends on it.
+func applyAliases(aliases []*ModuleAlias, libraries map[string]string) {
+	for _, a := range aliases {
+		v, exists := libraries[a.Path]
+		if !exists {
+			continue
+		}
+		if _, exists := libraries[a.Original]; exists {
+			continue
+		}
+
+		if a.Version != "" {
+			v = a.Version
+		}
+
+		libraries[a.Original] = v
+	}
+}
+
+// funcModule returns the module symbol was found in, the version offsets
+// are looked up at and the module path name, the aliased name of symbol,
+// belongs to. The module is the longest module path of modules symbol
+// belongs to.
+func funcModule(symbol string, name string, modules map[string]string, aliases []*ModuleAlias) (string, string, string) {
+	var module string
+	for mod := range modules {
+		if len(mod) > len(module) && belongsTo(symbol, mod) {
+			module = mod
+		}
+	}
+	if module == "" {
+		return "", "", ""
+	}
+
+	version, library := modules[module], module
+	for _, a := range aliases {
+		if a.Path == module {
+			library = a.Original
+			if a.Version != "" {
+				version = a.Version
+			}
+			break
+		}
+	}
+
+	log.Component(log.ComponentAnalyzer).V(1).Info("resolved function module", "function", name, "module", module, "version", version)
+	return module, version, library
+}
+
+// belongsTo reports whether symbol is declared in a package of module.
+func belongsTo(symbol string, module string) bool {
+	if !strings.HasPrefix(symbol, module) {
+		return false
+	}
+
+	rest := symbol[len(module):]
+	return strings.HasPrefix(rest, ".") || strings.HasPrefix(rest, "/")
+}
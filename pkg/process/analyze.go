@@ -21,8 +21,6 @@ import (
 	"fmt"
 	"os"
 
-	"github.com/prometheus/procfs"
-
 	"github.com/hashicorp/go-version"
 	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/log"
 	"golang.org/x/arch/x86/x86asm"
@@ -30,10 +28,14 @@ import (
 
 type TargetDetails struct {
 	PID               int
+	ExePath           string
+	MainModule        string
 	Functions         []*Func
 	GoVersion         *version.Version
 	Libraries         map[string]string
 	AllocationDetails *AllocationDetails
+	// CgoEnabled reports whether the target executable links C code.
+	CgoEnabled bool
 }
 
 type AllocationDetails struct {
@@ -45,6 +47,16 @@ type Func struct {
 	Name          string
 	Offset        uint64
 	ReturnOffsets []uint64
+	// Path is the path of the shared object (Go plugin or c-shared library)
+	// containing the function, empty if it is part of the target executable.
+	Path string
+	// Module is the path of the module the function was found in and
+	// Version the version its offsets are looked up at, both empty for
+	// standard library functions. Library is the module path Name belongs
+	// to, which differs from Module for aliased modules.
+	Module  string
+	Version string
+	Library string
 }
 
 func (t *TargetDetails) IsRegistersABI() bool {
@@ -72,27 +84,34 @@ func (t *TargetDetails) GetFunctionReturns(name string) ([]uint64, error) {
 	return nil, fmt.Errorf("could not find returns for function %s", name)
 }
 
-func (a *processAnalyzer) findKeyvalMmap(pid int) (uintptr, uintptr) {
-	fs, err := procfs.NewProc(pid)
-	if err != nil {
-		panic(err)
+// HasFunctions reports whether all the functions of names were found in the
+// target.
+func (t *TargetDetails) HasFunctions(names ...string) bool {
+	for _, name := range names {
+		if _, err := t.GetFunctionOffset(name); err != nil {
+			return false
+		}
 	}
 
-	maps, err := fs.ProcMaps()
+	return true
+}
+
+func (a *processAnalyzer) findKeyvalMmap(pid int) (uintptr, uintptr) {
+	maps, err := processMaps(pid)
 	if err != nil {
 		panic(err)
 	}
 
 	for _, m := range maps {
-		if m.Perms != nil && m.Perms.Read && m.Perms.Write && m.Perms.Execute {
-			log.Logger.Info("found addr of keyval map", "addr", m.StartAddr)
+		if m.Read && m.Write && m.Execute {
+			log.Component(log.ComponentAnalyzer).Info("found addr of keyval map", "addr", m.StartAddr)
 			return m.StartAddr, m.EndAddr
 		}
 	}
 	panic(errors.New("cant find keyval map"))
 }
 
-func (a *processAnalyzer) Analyze(pid int, relevantFuncs map[string]interface{}) (*TargetDetails, error) {
+func (a *processAnalyzer) Analyze(pid int, relevantFuncs map[string]interface{}, aliases []*ModuleAlias) (*TargetDetails, error) {
 	result := &TargetDetails{
 		PID: pid,
 	}
@@ -108,12 +127,28 @@ func (a *processAnalyzer) Analyze(pid int, relevantFuncs map[string]interface{})
 		return nil, err
 	}
 
-	goVersion, modules, err := a.getModuleDetails(elfF)
-	if err != nil {
+	// A non Go executable may still load Go code from c-shared libraries,
+	// which is looked up in analyzeSharedObjects.
+	goVersion, modules, mainModule, err := getModuleDetails(elfF)
+	isGoExe := err != errNotGoExe
+	if err != nil && isGoExe {
 		return nil, err
 	}
+	if modules == nil {
+		modules = make(map[string]string)
+	}
+	if isGoExe {
+		if forkAliases := resolveForks(elfF, a.forks, modules, aliases); len(forkAliases) > 0 {
+			aliases = append(forkAliases, aliases...)
+		}
+	}
+	applyAliases(aliases, modules)
 	result.GoVersion = goVersion
 	result.Libraries = modules
+	result.MainModule = mainModule
+	if exePath, err := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid)); err == nil {
+		result.ExePath = exePath
+	}
 
 	start, end := a.findKeyvalMmap(pid)
 	result.AllocationDetails = &AllocationDetails{
@@ -121,48 +156,82 @@ func (a *processAnalyzer) Analyze(pid int, relevantFuncs map[string]interface{})
 		EndAddr: uint64(end),
 	}
 
-	var pclndat []byte
-	if sec := elfF.Section(".gopclntab"); sec != nil {
-		pclndat, err = sec.Data()
+	if isGoExe {
+		funcs, err := findFunctions(elfF, relevantFuncs, modules, aliases)
 		if err != nil {
 			return nil, err
 		}
+		result.Functions = funcs
+
+		result.CgoEnabled = isCgoBinary(elfF)
+		if result.CgoEnabled {
+			log.Component(log.ComponentAnalyzer).V(0).Info("target links C code through cgo")
+			result.Functions = withoutCgoTransitions(result.Functions)
+		}
 	}
 
-	sec := elfF.Section(".gosymtab")
-	if sec == nil {
-		return nil, fmt.Errorf("%s section not found in target binary, make sure this is a Go application", ".gosymtab")
+	if err := a.analyzeSharedObjects(result, relevantFuncs, aliases); err != nil {
+		return nil, err
 	}
-	symTabRaw, err := sec.Data()
-	pcln := gosym.NewLineTable(pclndat, elfF.Section(".text").Addr)
+
+	if result.GoVersion == nil {
+		return nil, errNotGoExe
+	}
+
+	return result, nil
+}
+
+func findFunctions(elfF *elf.File, relevantFuncs map[string]interface{}, modules map[string]string, aliases []*ModuleAlias) ([]*Func, error) {
+	pclndat, err := findPclntab(elfF)
+	if err != nil {
+		return nil, err
+	}
+
+	// .gosymtab is empty since Go 1.3 and missing from stripped binaries,
+	// function addresses are resolved from the pclntab.
+	var symTabRaw []byte
+	if sec := elfF.Section(".gosymtab"); sec != nil {
+		symTabRaw, err = sec.Data()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var textAddr uint64
+	if sec := elfF.Section(".text"); sec != nil {
+		textAddr = sec.Addr
+	}
+	pcln := gosym.NewLineTable(pclndat, textAddr)
 	symTab, err := gosym.NewTable(symTabRaw, pcln)
 	if err != nil {
 		return nil, err
 	}
 
+	var result []*Func
 	for _, f := range symTab.Funcs {
-
-		if _, exists := relevantFuncs[f.Name]; exists {
-			start, returns, err := a.findFuncOffset(&f, elfF)
+		name := resolveAlias(aliases, f.Name)
+		if _, exists := relevantFuncs[name]; exists {
+			start, returns, err := findFuncOffset(&f, elfF)
 			if err != nil {
 				return nil, err
 			}
 
-			log.Logger.V(0).Info("found relevant function for instrumentation", "function", f.Name, "returns", len(returns))
+			log.Component(log.ComponentAnalyzer).V(0).Info("found relevant function for instrumentation", "function", name, "symbol", f.Name, "returns", len(returns))
 			function := &Func{
-				Name:          f.Name,
+				Name:          name,
 				Offset:        start,
 				ReturnOffsets: returns,
 			}
+			function.Module, function.Version, function.Library = funcModule(f.Name, name, modules, aliases)
 
-			result.Functions = append(result.Functions, function)
+			result = append(result, function)
 		}
 	}
 
 	return result, nil
 }
 
-func (a *processAnalyzer) findFuncOffset(f *gosym.Func, elfF *elf.File) (uint64, []uint64, error) {
+func findFuncOffset(f *gosym.Func, elfF *elf.File) (uint64, []uint64, error) {
 	off := f.Value
 	for _, prog := range elfF.Progs {
 		if prog.Type != elf.PT_LOAD || (prog.Flags&elf.PF_X) == 0 {
@@ -177,7 +246,7 @@ func (a *processAnalyzer) findFuncOffset(f *gosym.Func, elfF *elf.File) (uint64,
 			data := make([]byte, funcLen)
 			_, err := prog.ReadAt(data, int64(f.Value-prog.Vaddr))
 			if err != nil {
-				log.Logger.Error(err, "error while finding function return")
+				log.Error(log.Component(log.ComponentAnalyzer), log.ErrTargetAnalysis, err, "error while finding function return")
 				return 0, nil, err
 			}
 
@@ -185,7 +254,7 @@ func (a *processAnalyzer) findFuncOffset(f *gosym.Func, elfF *elf.File) (uint64,
 			for i := 0; i < int(funcLen); {
 				inst, err := x86asm.Decode(data[i:], 64)
 				if err != nil {
-					log.Logger.Error(err, "error while finding function return")
+					log.Error(log.Component(log.ComponentAnalyzer), log.ErrTargetAnalysis, err, "error while finding function return")
 					return 0, nil, err
 				}
 
@@ -16,15 +16,28 @@ package process
 
 import (
 	"errors"
+	"fmt"
 	"os"
+	"strconv"
 )
 
 const (
-	ExePathEnvVar = "OTEL_TARGET_EXE"
+	ExePathEnvVar       = "OTEL_TARGET_EXE"
+	ModuleAliasesEnvVar = "OTEL_GO_AUTO_MODULE_ALIASES"
+	// ShortLivedEnvVar enables the mode for short-lived targets, such as
+	// batch jobs and CLIs: the target is looked for continuously and
+	// instrumented as soon as it runs, and the agent exits once the spans
+	// produced before the target exited are exported.
+	ShortLivedEnvVar = "OTEL_GO_AUTO_SHORT_LIVED"
 )
 
 type TargetArgs struct {
-	ExePath string
+	ExePath       string
+	ModuleAliases []*ModuleAlias
+	ShortLived    bool
+
+	aliasesErr    error
+	shortLivedErr error
 }
 
 func (t *TargetArgs) Validate() error {
@@ -32,6 +45,14 @@ func (t *TargetArgs) Validate() error {
 		return errors.New("target binary path not specified")
 	}
 
+	if t.aliasesErr != nil {
+		return t.aliasesErr
+	}
+
+	if t.shortLivedErr != nil {
+		return t.shortLivedErr
+	}
+
 	return nil
 }
 
@@ -43,5 +64,19 @@ func ParseTargetArgs() *TargetArgs {
 		result.ExePath = val
 	}
 
+	val, exists = os.LookupEnv(ModuleAliasesEnvVar)
+	if exists {
+		result.ModuleAliases, result.aliasesErr = parseModuleAliases(val)
+	}
+
+	val, exists = os.LookupEnv(ShortLivedEnvVar)
+	if exists {
+		shortLived, err := strconv.ParseBool(val)
+		if err != nil {
+			result.shortLivedErr = fmt.Errorf("unsupported %s value %q", ShortLivedEnvVar, val)
+		}
+		result.ShortLived = shortLived
+	}
+
 	return result
 }
@@ -0,0 +1,68 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package process
+
+import (
+	"debug/elf"
+
+	"github.com/hashicorp/go-version"
+)
+
+// BinaryDetails describes a Go executable independently of any running
+// process.
+type BinaryDetails struct {
+	GoVersion  *version.Version
+	MainModule string
+	// Libraries maps the module path of each dependency to its version.
+	Libraries map[string]string
+	Functions []*Func
+	// CgoEnabled reports whether the executable links C code.
+	CgoEnabled bool
+}
+
+// AnalyzeBinary reads the Go version, the dependencies and the offsets of the
+// functions named in relevantFuncs from the Go executable at path. Unlike
+// Analyze, it does not require the executable to be running.
+func AnalyzeBinary(path string, relevantFuncs map[string]interface{}, aliases []*ModuleAlias) (*BinaryDetails, error) {
+	elfF, err := elf.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer elfF.Close()
+
+	goVersion, modules, mainModule, err := getModuleDetails(elfF)
+	if err != nil {
+		return nil, err
+	}
+	applyAliases(aliases, modules)
+
+	funcs, err := findFunctions(elfF, relevantFuncs, modules, aliases)
+	if err != nil {
+		return nil, err
+	}
+
+	cgoEnabled := isCgoBinary(elfF)
+	if cgoEnabled {
+		funcs = withoutCgoTransitions(funcs)
+	}
+
+	return &BinaryDetails{
+		GoVersion:  goVersion,
+		MainModule: mainModule,
+		Libraries:  modules,
+		Functions:  funcs,
+		CgoEnabled: cgoEnabled,
+	}, nil
+}
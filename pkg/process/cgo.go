@@ -0,0 +1,83 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package process
+
+import (
+	"debug/elf"
+	"strings"
+
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/log"
+)
+
+const (
+	cgoBuildSetting = "build\tCGO_ENABLED=1"
+	// cgoInitSymbol is defined by runtime/cgo, only linked in cgo binaries.
+	cgoInitSymbol = "x_cgo_init"
+)
+
+// cgoTransitionFuncs switch between the goroutine and the system stacks to
+// call C code or be called from it. Their frames are not laid out like the
+// ones of regular Go functions, probes are never attached to them in cgo
+// binaries.
+var cgoTransitionFuncs = map[string]interface{}{
+	"runtime.cgocall":        nil,
+	"runtime.asmcgocall":     nil,
+	"runtime.cgocallback":    nil,
+	"runtime.cgocallbackg":   nil,
+	"runtime.cgocallbackg1":  nil,
+	"runtime.asmcgocall_gcc": nil,
+	"crosscall2":             nil,
+}
+
+// isCgoBinary reports whether f links C code through cgo, from the build
+// settings recorded since Go 1.18 or else from the runtime/cgo symbols.
+func isCgoBinary(f *elf.File) bool {
+	if _, modules, err := getGoDetails(f); err == nil {
+		for _, line := range strings.Split(modules, "\n") {
+			if line == cgoBuildSetting {
+				return true
+			}
+		}
+	}
+
+	for _, symbols := range []func() ([]elf.Symbol, error){f.Symbols, f.DynamicSymbols} {
+		syms, err := symbols()
+		if err != nil {
+			continue
+		}
+
+		for _, s := range syms {
+			if s.Name == cgoInitSymbol {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// withoutCgoTransitions returns funcs without the cgo transition functions.
+func withoutCgoTransitions(funcs []*Func) []*Func {
+	var result []*Func
+	for _, f := range funcs {
+		if _, exists := cgoTransitionFuncs[f.Name]; exists {
+			log.Component(log.ComponentAnalyzer).V(0).Info("not instrumenting cgo transition function", "function", f.Name)
+			continue
+		}
+		result = append(result, f)
+	}
+
+	return result
+}
@@ -0,0 +1,101 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package process
+
+import (
+	"debug/dwarf"
+	"debug/elf"
+	"fmt"
+)
+
+// DebugFieldOffsets reads the offsets of fields from the debug info of the
+// target executable. It is meant for offsets not tracked per version, which
+// are only available in targets built with debug info.
+func (t *TargetDetails) DebugFieldOffsets(fields []StructField) ([]FieldOffset, error) {
+	elfF, err := elf.Open(fmt.Sprintf("/proc/%d/exe", t.PID))
+	if err != nil {
+		return nil, err
+	}
+	defer elfF.Close()
+
+	data, err := elfF.DWARF()
+	if err != nil {
+		return nil, err
+	}
+
+	return readFieldOffsets(data, fields)
+}
+
+// readFieldOffsets reads the offsets of fields from data, in the order of
+// fields. Struct names are fully qualified, as in the debug info.
+func readFieldOffsets(data *dwarf.Data, fields []StructField) ([]FieldOffset, error) {
+	wanted := make(map[string]map[string]uint64)
+	for _, f := range fields {
+		wanted[f.Struct] = nil
+	}
+
+	r := data.Reader()
+	for {
+		entry, err := r.Next()
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			break
+		}
+		if entry.Tag != dwarf.TagStructType || !entry.Children {
+			continue
+		}
+
+		name, _ := entry.Val(dwarf.AttrName).(string)
+		members, isWanted := wanted[name]
+		if !isWanted || members != nil {
+			r.SkipChildren()
+			continue
+		}
+
+		members = make(map[string]uint64)
+		for {
+			member, err := r.Next()
+			if err != nil {
+				return nil, err
+			}
+			if member == nil || member.Tag == 0 {
+				break
+			}
+			if member.Tag != dwarf.TagMember {
+				continue
+			}
+
+			field, _ := member.Val(dwarf.AttrName).(string)
+			if offset, ok := member.Val(dwarf.AttrDataMemberLoc).(int64); ok {
+				members[field] = uint64(offset)
+			}
+		}
+		wanted[name] = members
+	}
+
+	result := make([]FieldOffset, 0, len(fields))
+	for _, f := range fields {
+		offset, found := wanted[f.Struct][f.Field]
+		if !found {
+			return nil, fmt.Errorf("field %s.%s not found", f.Struct, f.Field)
+		}
+
+		result = append(result, FieldOffset{StructField: f, Offset: offset})
+	}
+
+	return result, nil
+}
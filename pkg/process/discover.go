@@ -15,6 +15,8 @@
 package process
 
 import (
+	"context"
+	"debug/elf"
 	"io"
 	"io/ioutil"
 	"os"
@@ -27,41 +29,124 @@ import (
 	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/log"
 )
 
+const (
+	// pidPollInterval is the interval at which running processes are
+	// searched for the target.
+	pidPollInterval = 2 * time.Second
+	// shortLivedPollInterval replaces pidPollInterval for short-lived
+	// targets, which may exit before a slower poll finds them.
+	shortLivedPollInterval = 10 * time.Millisecond
+)
+
 type processAnalyzer struct {
-	done          chan bool
-	pidTickerChan <-chan time.Time
+	done  chan bool
+	forks []*ModuleFork
 }
 
 func NewAnalyzer() *processAnalyzer {
 	return &processAnalyzer{
-		done:          make(chan bool, 1),
-		pidTickerChan: time.NewTicker(2 * time.Second).C,
+		done: make(chan bool, 1),
 	}
 }
 
+// SetModuleForks sets the forks of instrumented modules aliased to them
+// when found in the target with the same layout.
+func (a *processAnalyzer) SetModuleForks(forks []*ModuleFork) {
+	a.forks = forks
+}
+
 func (a *processAnalyzer) DiscoverProcessID(target *TargetArgs) (int, error) {
-	for {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
 		select {
 		case <-a.done:
-			log.Logger.V(0).Info("stopping process id discovery due to kill signal")
+			log.Component(log.ComponentAnalyzer).V(0).Info("stopping process id discovery due to kill signal")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return WaitForTarget(ctx, target)
+}
+
+// WaitForTarget blocks until a process matching target is running and its
+// executable is fully written, and returns its PID. The executable is
+// considered fully written once it parses as ELF and its size did not change
+// since the previous poll, short-lived targets are returned as soon as it
+// parses as ELF. ErrInterrupted is returned if ctx is done first.
+func WaitForTarget(ctx context.Context, target *TargetArgs) (int, error) {
+	interval := pidPollInterval
+	if target.ShortLived {
+		interval = shortLivedPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	lastPID, lastSize := 0, int64(-1)
+	for {
+		select {
+		case <-ctx.Done():
 			return 0, errors.ErrInterrupted
-		case <-a.pidTickerChan:
-			pid, err := a.findProcessID(target)
+		case <-ticker.C:
+			pid, err := findProcessID(target)
 			if err != nil {
 				if err == errors.ErrProcessNotFound {
-					log.Logger.V(0).Info("process not found yet, trying again soon", "exe_path", target.ExePath)
+					if target.ShortLived {
+						// Logging every poll would flood the output.
+						continue
+					}
+					log.Component(log.ComponentAnalyzer).V(0).Info("process not found yet, trying again soon", "exe_path", target.ExePath)
 				} else {
-					log.Logger.Error(err, "error while searching for process", "exe_path", target.ExePath)
+					log.Error(log.Component(log.ComponentAnalyzer), log.ErrTargetDiscovery, err, "error while searching for process", "exe_path", target.ExePath)
 				}
-			} else {
-				log.Logger.V(0).Info("found process", "pid", pid)
-				return pid, nil
+				continue
+			}
+
+			size, err := executableSize(pid)
+			if err != nil {
+				log.Component(log.ComponentAnalyzer).V(0).Info("target executable not ready yet, trying again soon", "pid", pid, "reason", err.Error())
+				lastPID, lastSize = 0, -1
+				continue
 			}
+
+			if !target.ShortLived && (pid != lastPID || size != lastSize) {
+				lastPID, lastSize = pid, size
+				continue
+			}
+
+			log.Component(log.ComponentAnalyzer).V(0).Info("found process", "pid", pid)
+			return pid, nil
 		}
 	}
 }
 
-func (a *processAnalyzer) findProcessID(target *TargetArgs) (int, error) {
+// executableSize returns the size of the executable of the process, or an
+// error if it cannot be parsed as ELF yet.
+func executableSize(pid int) (int64, error) {
+	exePath := path.Join("/proc", strconv.Itoa(pid), "exe")
+	f, err := os.Open(exePath)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	elfF, err := elf.NewFile(f)
+	if err != nil {
+		return 0, err
+	}
+	defer elfF.Close()
+
+	return fi.Size(), nil
+}
+
+func findProcessID(target *TargetArgs) (int, error) {
 	proc, err := os.Open("/proc")
 	if err != nil {
 		return 0, err
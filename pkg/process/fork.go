@@ -0,0 +1,139 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package process
+
+import (
+	"crypto/sha256"
+	"debug/dwarf"
+	"debug/elf"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/log"
+)
+
+// ModuleFork declares the modules matching Pattern as possible forks of
+// Original. Unlike a ModuleAlias, a matching module is only aliased to
+// Original when the layout of its structs, read from the debug info of the
+// target, is the layout of Original at one of the versions in Shapes.
+type ModuleFork struct {
+	// Pattern is a module path pattern, in path.Match syntax.
+	Pattern string
+	// Original is the upstream module path the probes are written for.
+	Original string
+	// Fields are the struct fields of Original making up its layout.
+	Fields []StructField
+	// Shapes maps the shape hash of Fields to the version of Original
+	// offsets are looked up at for forks with that layout.
+	Shapes map[string]string
+}
+
+// StructField is a field of a struct declared in a package of a module.
+type StructField struct {
+	Struct string
+	Field  string
+}
+
+// FieldOffset is the offset of a struct field.
+type FieldOffset struct {
+	StructField
+	Offset uint64
+}
+
+// ShapeHash returns the hash of the layout of the structs of module the
+// offsets are of. Struct names are hashed relative to module so a fork has
+// the hash of the upstream module it has the layout of.
+func ShapeHash(module string, offsets []FieldOffset) string {
+	h := sha256.New()
+	for _, o := range offsets {
+		fmt.Fprintf(h, "%s.%s=%d;", strings.TrimPrefix(o.Struct, module), o.Field, o.Offset)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// resolveForks returns aliases for the modules of the executable matching a
+// fork in forks, and with the layout of its upstream module. Modules already
+// aliased, or matching their upstream module path, are skipped.
+func resolveForks(elfF *elf.File, forks []*ModuleFork, modules map[string]string, aliases []*ModuleAlias) []*ModuleAlias {
+	aliased := make(map[string]bool, len(aliases))
+	for _, a := range aliases {
+		aliased[a.Path] = true
+	}
+
+	var paths []string
+	for mod := range modules {
+		paths = append(paths, mod)
+	}
+	sort.Strings(paths)
+
+	var result []*ModuleAlias
+	var data *dwarf.Data
+	for _, mod := range paths {
+		for _, fork := range forks {
+			if mod == fork.Original || aliased[mod] {
+				continue
+			}
+			if matched, _ := path.Match(fork.Pattern, mod); !matched {
+				continue
+			}
+
+			if data == nil {
+				var err error
+				data, err = elfF.DWARF()
+				if err != nil {
+					log.Component(log.ComponentAnalyzer).V(0).Info("could not read debug info, forks of instrumented modules are not instrumented", "module", mod, "error", err.Error())
+					return nil
+				}
+			}
+
+			offsets, err := forkOffsets(data, mod, fork)
+			if err != nil {
+				log.Component(log.ComponentAnalyzer).V(0).Info("module matches a fork of an instrumented module but its structs could not be read", "module", mod, "original", fork.Original, "error", err.Error())
+				continue
+			}
+
+			hash := ShapeHash(mod, offsets)
+			version, exists := fork.Shapes[hash]
+			if !exists {
+				log.Component(log.ComponentAnalyzer).V(0).Info("module matches a fork of an instrumented module but has a different layout", "module", mod, "original", fork.Original, "shape", hash)
+				continue
+			}
+
+			log.Component(log.ComponentAnalyzer).V(0).Info("instrumenting fork of module", "module", mod, "original", fork.Original, "version", version)
+			aliased[mod] = true
+			result = append(result, &ModuleAlias{Path: mod, Original: fork.Original, Version: version})
+		}
+	}
+
+	return result
+}
+
+// forkOffsets reads the offsets of the fields of fork in module from the
+// debug info of the executable.
+func forkOffsets(data *dwarf.Data, module string, fork *ModuleFork) ([]FieldOffset, error) {
+	fields := make([]StructField, 0, len(fork.Fields))
+	for _, f := range fork.Fields {
+		fields = append(fields, StructField{
+			Struct: module + strings.TrimPrefix(f.Struct, fork.Original),
+			Field:  f.Field,
+		})
+	}
+
+	return readFieldOffsets(data, fields)
+}
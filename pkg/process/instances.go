@@ -0,0 +1,110 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package process
+
+import "github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/log"
+
+// instanceKey identifies the function within the module instance providing
+// it.
+func (f *Func) instanceKey() string {
+	return f.Name + "@" + f.moduleKey()
+}
+
+func (f *Func) moduleKey() string {
+	return f.Module + "@" + f.Version
+}
+
+// Instances returns a view of the target per module instance providing the
+// functions in funcNames, e.g. a module and a fork of it aliased to it, or
+// a module linked in both the executable and a shared object at different
+// versions. Each view only holds the functions of funcNames of its instance
+// and reports the instance version for the module they belong to. Instances
+// missing some of the functions are skipped. Functions outside modules, such
+// as the standard library ones, are shared by all instances. t itself is
+// returned if a single instance, or none, provides all of them.
+func (t *TargetDetails) Instances(funcNames []string) []*TargetDetails {
+	names := make(map[string]interface{}, len(funcNames))
+	for _, name := range funcNames {
+		names[name] = nil
+	}
+
+	var keys []string
+	var shared []*Func
+	instances := make(map[string][]*Func)
+	for _, f := range t.Functions {
+		if _, exists := names[f.Name]; !exists {
+			continue
+		}
+
+		if f.Module == "" {
+			shared = append(shared, f)
+			continue
+		}
+
+		key := f.moduleKey()
+		if _, exists := instances[key]; !exists {
+			keys = append(keys, key)
+		}
+		instances[key] = append(instances[key], f)
+	}
+
+	if len(keys) <= 1 {
+		return []*TargetDetails{t}
+	}
+
+	var result []*TargetDetails
+	for _, key := range keys {
+		funcs := instances[key]
+		if !providesAll(append(funcs, shared...), names) {
+			log.Component(log.ComponentAnalyzer).V(0).Info("skipping module instance missing instrumented functions", "instance", key)
+			continue
+		}
+
+		view := *t
+		view.Functions = nil
+		for _, f := range t.Functions {
+			if _, exists := names[f.Name]; !exists {
+				view.Functions = append(view.Functions, f)
+			}
+		}
+		view.Functions = append(view.Functions, funcs...)
+		view.Functions = append(view.Functions, shared...)
+
+		view.Libraries = make(map[string]string, len(t.Libraries))
+		for mod, v := range t.Libraries {
+			view.Libraries[mod] = v
+		}
+		if funcs[0].Library != "" {
+			view.Libraries[funcs[0].Library] = funcs[0].Version
+		}
+
+		result = append(result, &view)
+	}
+
+	if len(result) == 0 {
+		return []*TargetDetails{t}
+	}
+
+	return result
+}
+
+func providesAll(funcs []*Func, names map[string]interface{}) bool {
+	provided := make(map[string]interface{}, len(funcs))
+	for _, f := range funcs {
+		provided[f.Name] = nil
+	}
+
+	return len(provided) == len(names)
+}
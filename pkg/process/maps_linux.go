@@ -0,0 +1,44 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package process
+
+import "github.com/prometheus/procfs"
+
+// processMaps returns the memory mappings of the process pid.
+func processMaps(pid int) ([]*mapping, error) {
+	proc, err := procfs.NewProc(pid)
+	if err != nil {
+		return nil, err
+	}
+
+	maps, err := proc.ProcMaps()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*mapping, 0, len(maps))
+	for _, m := range maps {
+		result = append(result, &mapping{
+			StartAddr: m.StartAddr,
+			EndAddr:   m.EndAddr,
+			Read:      m.Perms != nil && m.Perms.Read,
+			Write:     m.Perms != nil && m.Perms.Write,
+			Execute:   m.Perms != nil && m.Perms.Execute,
+			Pathname:  m.Pathname,
+		})
+	}
+
+	return result, nil
+}
@@ -33,20 +33,20 @@ import (
 var buildInfoMagic = []byte("\xff Go buildinf:")
 var errNotGoExe = errors.New("not a Go executable")
 
-func (a *processAnalyzer) getModuleDetails(f *elf.File) (*version.Version, map[string]string, error) {
+func getModuleDetails(f *elf.File) (*version.Version, map[string]string, string, error) {
 	goVersion, modules, err := getGoDetails(f)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, "", err
 	}
 
 	v, err := parseGoVersion(goVersion)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, "", err
 	}
 
-	log.Logger.V(1).Info("go version detected", "version", goVersion)
+	log.Component(log.ComponentAnalyzer).V(1).Info("go version detected", "version", goVersion)
 	modsMap := parseModules(modules)
-	return v, modsMap, nil
+	return v, modsMap, parseMainModule(modules), nil
 }
 
 func parseGoVersion(vers string) (*version.Version, error) {
@@ -192,6 +192,18 @@ func parseModules(mod string) map[string]string {
 	return result
 }
 
+// parseMainModule returns the path of the main module of the binary.
+func parseMainModule(mod string) string {
+	for _, line := range strings.Split(mod, "\n") {
+		parts := strings.Fields(line)
+		if len(parts) > 1 && parts[0] == "mod" {
+			return parts[1]
+		}
+	}
+
+	return ""
+}
+
 func decodeString(data []byte) (s string, rest []byte) {
 	u, n := binary.Uvarint(data)
 	if n <= 0 || u >= uint64(len(data)-n) {
@@ -0,0 +1,108 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package process
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/gosym"
+	"encoding/binary"
+	"errors"
+
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/log"
+)
+
+// pclntabMagics are the little-endian magic numbers starting the pclntab
+// header of the supported Go versions (1.2, 1.16, 1.18 and 1.20).
+var pclntabMagics = [][]byte{
+	{0xfb, 0xff, 0xff, 0xff},
+	{0xfa, 0xff, 0xff, 0xff},
+	{0xf0, 0xff, 0xff, 0xff},
+	{0xf1, 0xff, 0xff, 0xff},
+}
+
+// pclntabSections are the sections the linker places the pclntab in when
+// the binary has no dedicated .gopclntab section (e.g. stripped PIE builds).
+var pclntabSections = []string{".data.rel.ro", ".rodata"}
+
+var errPclntabNotFound = errors.New("pclntab not found in target binary, make sure this is a Go application")
+
+// findPclntab returns the raw pclntab of f. Go binaries retain the pclntab
+// even when built with -s, so it can be used to locate functions once the
+// symbol table is stripped.
+func findPclntab(f *elf.File) ([]byte, error) {
+	if sec := f.Section(".gopclntab"); sec != nil {
+		return sec.Data()
+	}
+
+	var textAddr uint64
+	if text := f.Section(".text"); text != nil {
+		textAddr = text.Addr
+	}
+
+	for _, name := range pclntabSections {
+		sec := f.Section(name)
+		if sec == nil {
+			continue
+		}
+
+		data, err := sec.Data()
+		if err != nil {
+			return nil, err
+		}
+
+		if tab := scanPclntab(data, textAddr); tab != nil {
+			log.Component(log.ComponentAnalyzer).V(0).Info("found pclntab outside of .gopclntab", "section", name)
+			return tab, nil
+		}
+	}
+
+	return nil, errPclntabNotFound
+}
+
+func scanPclntab(data []byte, textAddr uint64) []byte {
+	for _, magic := range pclntabMagics {
+		for off := 0; off+8 <= len(data); {
+			i := bytes.Index(data[off:], magic)
+			if i < 0 {
+				break
+			}
+			off += i
+
+			if isPclntabHeader(data[off:]) {
+				candidate := data[off:]
+				symTab, err := gosym.NewTable(nil, gosym.NewLineTable(candidate, textAddr))
+				if err == nil && len(symTab.Funcs) > 0 {
+					return candidate
+				}
+			}
+
+			off += len(magic)
+		}
+	}
+
+	return nil
+}
+
+// isPclntabHeader validates the fields following the magic number: two zero
+// bytes, the instruction size quantum and the pointer size.
+func isPclntabHeader(h []byte) bool {
+	if len(h) < 8 || binary.LittleEndian.Uint16(h[4:6]) != 0 {
+		return false
+	}
+
+	quantum, ptrSize := h[6], h[7]
+	return (quantum == 1 || quantum == 2 || quantum == 4) && (ptrSize == 4 || ptrSize == 8)
+}
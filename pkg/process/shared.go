@@ -0,0 +1,113 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package process
+
+import (
+	"debug/elf"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/open-telemetry/opentelemetry-go-instrumentation/pkg/log"
+	"github.com/prometheus/procfs"
+)
+
+// analyzeSharedObjects looks up relevant functions in Go code mapped into
+// the target from files other than its executable, i.e. Go plugins
+// (-buildmode=plugin) and c-shared libraries (-buildmode=c-shared).
+// Only objects already loaded when the target is analyzed are found.
+func (a *processAnalyzer) analyzeSharedObjects(target *TargetDetails, relevantFuncs map[string]interface{}, aliases []*ModuleAlias) error {
+	proc, err := procfs.NewProc(target.PID)
+	if err != nil {
+		return err
+	}
+
+	maps, err := processMaps(target.PID)
+	if err != nil {
+		return err
+	}
+
+	exePath, err := proc.Executable()
+	if err != nil {
+		return err
+	}
+
+	// The same function may be provided by each module instance, e.g. a
+	// module and a fork of it, or two versions of a module.
+	found := make(map[string]interface{})
+	for _, f := range target.Functions {
+		found[f.instanceKey()] = nil
+	}
+
+	analyzed := make(map[string]interface{})
+	for _, m := range maps {
+		if !m.Execute || m.Pathname == "" || m.Pathname == exePath ||
+			strings.HasPrefix(m.Pathname, "[") {
+			continue
+		}
+		if _, exists := analyzed[m.Pathname]; exists {
+			continue
+		}
+		analyzed[m.Pathname] = nil
+
+		// Resolve the path through the target root to support targets
+		// running in a different mount namespace.
+		path := filepath.Join(fmt.Sprintf("/proc/%d/root", target.PID), m.Pathname)
+		funcs, err := a.analyzeSharedObject(path, target, relevantFuncs, aliases)
+		if err != nil {
+			log.Component(log.ComponentAnalyzer).V(1).Info("skipping shared object", "path", m.Pathname, "reason", err.Error())
+			continue
+		}
+
+		for _, f := range funcs {
+			if _, exists := found[f.instanceKey()]; exists {
+				continue
+			}
+
+			found[f.instanceKey()] = nil
+			f.Path = path
+			target.Functions = append(target.Functions, f)
+		}
+	}
+
+	return nil
+}
+
+func (a *processAnalyzer) analyzeSharedObject(path string, target *TargetDetails, relevantFuncs map[string]interface{}, aliases []*ModuleAlias) ([]*Func, error) {
+	elfF, err := elf.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer elfF.Close()
+
+	goVersion, modules, _, err := getModuleDetails(elfF)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Component(log.ComponentAnalyzer).V(0).Info("found Go shared object", "path", path, "go_version", goVersion)
+	if target.GoVersion == nil {
+		target.GoVersion = goVersion
+	}
+
+	for mod, v := range modules {
+		if _, exists := target.Libraries[mod]; !exists {
+			target.Libraries[mod] = v
+		}
+	}
+	applyAliases(aliases, target.Libraries)
+
+	return findFunctions(elfF, relevantFuncs, modules, aliases)
+}